@@ -0,0 +1,248 @@
+package awsssolib
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+)
+
+// AdminInput contains parameters for SSO administration operations.
+//
+// Admin operations call the IAM Identity Center admin APIs with regular AWS
+// credentials from the default credential chain (or Profile), not with an SSO
+// access token.
+type AdminInput struct {
+	// Region of the Identity Center instance
+	Region string
+	// Optional: instance ARN, discovered with ListInstances when empty
+	InstanceARN string
+	// Optional: AWS profile providing admin credentials
+	Profile string
+	// Optional configuration
+	Config *Config
+}
+
+// AccountAssignment represents a permission set assigned to a principal in an account
+type AccountAssignment struct {
+	InstanceARN       string `json:"instanceArn"`
+	PermissionSetARN  string `json:"permissionSetArn"`
+	PermissionSetName string `json:"permissionSetName"`
+	PrincipalType     string `json:"principalType"`
+	PrincipalID       string `json:"principalId"`
+	AccountID         string `json:"accountId"`
+}
+
+// ListAccountAssignments returns all account assignments of the Identity Center instance
+func ListAccountAssignments(ctx context.Context, input AdminInput) ([]AccountAssignment, error) {
+	logger := getLogger(input.Config)
+
+	client, instanceARN, err := newAdminClient(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	permissionSets, err := listPermissionSetARNs(ctx, client, instanceARN)
+	if err != nil {
+		return nil, err
+	}
+
+	var assignments []AccountAssignment
+
+	for _, permissionSetARN := range permissionSets {
+		describeResp, err := client.DescribePermissionSet(ctx, &ssoadmin.DescribePermissionSetInput{
+			InstanceArn:      aws.String(instanceARN),
+			PermissionSetArn: aws.String(permissionSetARN),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe permission set %s: %w", permissionSetARN, err)
+		}
+		permissionSetName := aws.ToString(describeResp.PermissionSet.Name)
+
+		logger.Debug("Listing accounts for permission set",
+			slog.String("permission_set", permissionSetName))
+
+		accountsPaginator := ssoadmin.NewListAccountsForProvisionedPermissionSetPaginator(client, &ssoadmin.ListAccountsForProvisionedPermissionSetInput{
+			InstanceArn:      aws.String(instanceARN),
+			PermissionSetArn: aws.String(permissionSetARN),
+		})
+		for accountsPaginator.HasMorePages() {
+			accountsPage, err := accountsPaginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list accounts for permission set %s: %w", permissionSetName, err)
+			}
+
+			for _, accountID := range accountsPage.AccountIds {
+				assignmentsPaginator := ssoadmin.NewListAccountAssignmentsPaginator(client, &ssoadmin.ListAccountAssignmentsInput{
+					InstanceArn:      aws.String(instanceARN),
+					PermissionSetArn: aws.String(permissionSetARN),
+					AccountId:        aws.String(accountID),
+				})
+				for assignmentsPaginator.HasMorePages() {
+					assignmentsPage, err := assignmentsPaginator.NextPage(ctx)
+					if err != nil {
+						return nil, fmt.Errorf("failed to list assignments for account %s: %w", accountID, err)
+					}
+
+					for _, assignment := range assignmentsPage.AccountAssignments {
+						assignments = append(assignments, AccountAssignment{
+							InstanceARN:       instanceARN,
+							PermissionSetARN:  permissionSetARN,
+							PermissionSetName: permissionSetName,
+							PrincipalType:     string(assignment.PrincipalType),
+							PrincipalID:       aws.ToString(assignment.PrincipalId),
+							AccountID:         aws.ToString(assignment.AccountId),
+						})
+					}
+				}
+			}
+		}
+	}
+
+	logger.Info("Listed account assignments", slog.Int("count", len(assignments)))
+	return assignments, nil
+}
+
+// newAdminClient creates an SSO admin client and resolves the instance ARN
+func newAdminClient(ctx context.Context, input AdminInput) (*ssoadmin.Client, string, error) {
+	if err := ValidateRegion(input.Region); err != nil {
+		return nil, "", err
+	}
+
+	var opts []func(*config.LoadOptions) error
+	if input.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(input.Profile))
+	}
+
+	cfg, err := loadSDKConfig(ctx, input.Region, input.Config, opts...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load config: %w", err)
+	}
+
+	client := ssoadmin.NewFromConfig(cfg)
+
+	instanceARN := input.InstanceARN
+	if instanceARN == "" {
+		instanceARN, err = findInstanceARN(ctx, client)
+		if err != nil {
+			return nil, "", err
+		}
+		getLogger(input.Config).Debug("Discovered Identity Center instance",
+			slog.String("instance_arn", instanceARN))
+	}
+
+	return client, instanceARN, nil
+}
+
+// findInstanceARN returns the ARN of the Identity Center instance visible to the caller
+func findInstanceARN(ctx context.Context, client *ssoadmin.Client) (string, error) {
+	resp, err := client.ListInstances(ctx, &ssoadmin.ListInstancesInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list Identity Center instances: %w", err)
+	}
+
+	switch len(resp.Instances) {
+	case 0:
+		return "", fmt.Errorf("no Identity Center instance found")
+	case 1:
+		return aws.ToString(resp.Instances[0].InstanceArn), nil
+	default:
+		return "", &InvalidConfigError{Message: "multiple Identity Center instances found; specify the instance ARN"}
+	}
+}
+
+// listPermissionSetARNs returns the ARNs of all permission sets in an instance
+func listPermissionSetARNs(ctx context.Context, client *ssoadmin.Client, instanceARN string) ([]string, error) {
+	var arns []string
+
+	paginator := ssoadmin.NewListPermissionSetsPaginator(client, &ssoadmin.ListPermissionSetsInput{
+		InstanceArn: aws.String(instanceARN),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list permission sets: %w", err)
+		}
+		arns = append(arns, page.PermissionSets...)
+	}
+
+	return arns, nil
+}
+
+// PermissionSetDetails describes a permission set and its attached policies
+type PermissionSetDetails struct {
+	ARN             string   `json:"arn"`
+	Name            string   `json:"name"`
+	Description     string   `json:"description,omitempty"`
+	SessionDuration string   `json:"sessionDuration,omitempty"`
+	ManagedPolicies []string `json:"managedPolicies"`
+	InlinePolicy    string   `json:"inlinePolicy,omitempty"`
+}
+
+// ListPermissionSetDetails returns the permission sets of the Identity Center
+// instance with their session duration and attached managed and inline policies
+func ListPermissionSetDetails(ctx context.Context, input AdminInput) ([]PermissionSetDetails, error) {
+	logger := getLogger(input.Config)
+
+	client, instanceARN, err := newAdminClient(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	permissionSets, err := listPermissionSetARNs(ctx, client, instanceARN)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]PermissionSetDetails, 0, len(permissionSets))
+
+	for _, permissionSetARN := range permissionSets {
+		describeResp, err := client.DescribePermissionSet(ctx, &ssoadmin.DescribePermissionSetInput{
+			InstanceArn:      aws.String(instanceARN),
+			PermissionSetArn: aws.String(permissionSetARN),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe permission set %s: %w", permissionSetARN, err)
+		}
+
+		permissionSet := describeResp.PermissionSet
+		detail := PermissionSetDetails{
+			ARN:             permissionSetARN,
+			Name:            aws.ToString(permissionSet.Name),
+			Description:     aws.ToString(permissionSet.Description),
+			SessionDuration: aws.ToString(permissionSet.SessionDuration),
+			ManagedPolicies: []string{},
+		}
+
+		policiesPaginator := ssoadmin.NewListManagedPoliciesInPermissionSetPaginator(client, &ssoadmin.ListManagedPoliciesInPermissionSetInput{
+			InstanceArn:      aws.String(instanceARN),
+			PermissionSetArn: aws.String(permissionSetARN),
+		})
+		for policiesPaginator.HasMorePages() {
+			page, err := policiesPaginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list managed policies for permission set %s: %w", detail.Name, err)
+			}
+			for _, policy := range page.AttachedManagedPolicies {
+				detail.ManagedPolicies = append(detail.ManagedPolicies, aws.ToString(policy.Arn))
+			}
+		}
+
+		inlineResp, err := client.GetInlinePolicyForPermissionSet(ctx, &ssoadmin.GetInlinePolicyForPermissionSetInput{
+			InstanceArn:      aws.String(instanceARN),
+			PermissionSetArn: aws.String(permissionSetARN),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get inline policy for permission set %s: %w", detail.Name, err)
+		}
+		detail.InlinePolicy = aws.ToString(inlineResp.InlinePolicy)
+
+		details = append(details, detail)
+	}
+
+	logger.Info("Listed permission sets", slog.Int("count", len(details)))
+	return details, nil
+}
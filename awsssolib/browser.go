@@ -3,6 +3,7 @@ package awsssolib
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
@@ -119,6 +120,39 @@ func DefaultAuthHandler(ctx context.Context, params AuthHandlerParams) error {
 	return nil
 }
 
+// stderrIsTerminal reports whether stderr is a terminal
+func stderrIsTerminal() bool {
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// startWaitingReminder writes a reminder to w every interval until the
+// returned function is called
+func startWaitingReminder(w io.Writer, interval time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	start := time.Now()
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				fmt.Fprintf(w, "Still waiting for authorization in the browser (%s elapsed)...\n", time.Since(start).Round(time.Second))
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
 // NonInteractiveAuthHandler returns an error indicating authentication is needed
 func NonInteractiveAuthHandler(ctx context.Context, params AuthHandlerParams) error {
 	return &AuthenticationNeededError{
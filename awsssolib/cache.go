@@ -1,20 +1,66 @@
 package awsssolib
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
 	"time"
 )
 
-// Default cache directories
+// Default cache directories, resolved when the package is initialized.
+//
+// Deprecated: use SSOCacheDir and CLICacheDir, which follow changes to the
+// home directory.
 var (
-	DefaultSSOCacheDir = filepath.Join(os.Getenv("HOME"), ".aws", "sso", "cache")
-	DefaultCLICacheDir = filepath.Join(os.Getenv("HOME"), ".aws", "cli", "cache")
+	DefaultSSOCacheDir = SSOCacheDir()
+	DefaultCLICacheDir = CLICacheDir()
 )
 
+// CLICacheDir returns the AWS CLI credential cache directory, resolving the
+// home directory each time it is called
+func CLICacheDir() string {
+	return filepath.Join(userHomeDir(), ".aws", "cli", "cache")
+}
+
+// userHomeDir returns the user's home directory: $HOME on Unix and
+// %USERPROFILE% on Windows
+func userHomeDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return home
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		return home
+	}
+	return os.Getenv("USERPROFILE")
+}
+
+// SSOCacheDir returns the default SSO token cache directory: AWS_SSO_CACHE_DIR
+// when set, otherwise the AWS CLI cache under the current home directory.
+// Config.SSOCacheDir takes precedence over both.
+func SSOCacheDir() string {
+	if dir := os.Getenv("AWS_SSO_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(userHomeDir(), ".aws", "sso", "cache")
+}
+
+// getSSOCacheDir returns the SSO token cache directory from config, or the
+// default directory if it isn't set
+func getSSOCacheDir(config *Config) string {
+	if config != nil && config.SSOCacheDir != "" {
+		return config.SSOCacheDir
+	}
+	return SSOCacheDir()
+}
+
 // FileCache implements the Cache interface using the filesystem
 type FileCache struct {
 	directory string
@@ -67,8 +113,9 @@ func (c *FileCache) getCacheFilename(key string) string {
 	return filepath.Join(c.directory, key+".json")
 }
 
-// MemoryCache implements an in-memory cache
+// MemoryCache implements an in-memory cache that is safe for concurrent use
 type MemoryCache struct {
+	mu   sync.RWMutex
 	data map[string][]byte
 }
 
@@ -81,6 +128,9 @@ func NewMemoryCache() *MemoryCache {
 
 // Get retrieves data from the cache
 func (c *MemoryCache) Get(key string) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	data, ok := c.data[key]
 	if !ok {
 		return nil, nil
@@ -90,12 +140,18 @@ func (c *MemoryCache) Get(key string) ([]byte, error) {
 
 // Put stores data in the cache
 func (c *MemoryCache) Put(key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.data[key] = data
 	return nil
 }
 
 // Delete removes data from the cache
 func (c *MemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	delete(c.data, key)
 	return nil
 }
@@ -107,35 +163,292 @@ type AWSCLIToken struct {
 	Region                string `json:"region"`
 	AccessToken           string `json:"accessToken"`
 	ExpiresAt             string `json:"expiresAt"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
 	ReceivedAt            string `json:"receivedAt,omitempty"`
 	ClientID              string `json:"clientId,omitempty"`
 	ClientSecret          string `json:"clientSecret,omitempty"`
 	RegistrationExpiresAt string `json:"registrationExpiresAt,omitempty"`
+	// Scopes isn't written by the AWS CLI, which ignores it
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 // GetSSOCacheFilePath returns the cache file path for the given start URL (AWS CLI compatible)
 func GetSSOCacheFilePath(startURL string) string {
+	return ssoCacheFilePath(SSOCacheDir(), startURL)
+}
+
+// ssoCacheFilePath returns the AWS CLI compatible token file for a start URL in dir
+func ssoCacheFilePath(dir, startURL string) string {
 	// Use SHA1 hashing like AWS CLI and aws-sso-util for compatibility
 	hash := sha1.Sum([]byte(startURL))
 	filename := fmt.Sprintf("%x.json", hash)
 
-	homeDir, err := os.UserHomeDir()
+	return filepath.Join(dir, filename)
+}
+
+// GetSSOCacheFilePathForSession returns the cache file path the AWS CLI v2
+// uses for the token of an sso-session, named by the SHA1 of the session name
+// rather than of the start URL
+func GetSSOCacheFilePathForSession(sessionName string) string {
+	return ssoSessionCacheFilePath(SSOCacheDir(), sessionName)
+}
+
+// ssoSessionCacheFilePath returns the token file the AWS CLI v2 uses in dir for
+// profiles with an sso_session, named by the SHA1 of the session name
+func ssoSessionCacheFilePath(dir, sessionName string) string {
+	hash := sha1.Sum([]byte(sessionName))
+	return filepath.Join(dir, fmt.Sprintf("%x.json", hash))
+}
+
+// ssoSessionsUsing returns the names of the sso-sessions of the AWS config
+// using startURL. The config can't always be read, so an error is no sessions.
+// Operations look them up once and pass them to the token cache helpers
+// rather than parsing the config on every token read.
+func ssoSessionsUsing(startURL string) []string {
+	config, err := LoadConfigFile("")
 	if err != nil {
-		// Fall back to HOME env var
-		homeDir = os.Getenv("HOME")
+		return nil
 	}
+	var names []string
+	for _, name := range config.ListSSOSessions() {
+		if config.GetSSOSession(name).StartURL == startURL {
+			names = append(names, name)
+		}
+	}
+	return names
+}
 
-	cacheDir := filepath.Join(homeDir, ".aws", "sso", "cache")
-	return filepath.Join(cacheDir, filename)
+// tokenCachePaths returns the token files for a start URL in dir: those of
+// sessions, the sso-sessions using the start URL, then the start URL file
+func tokenCachePaths(dir, startURL string, sessions []string) []string {
+	paths := make([]string, 0, len(sessions)+1)
+	for _, name := range sessions {
+		paths = append(paths, ssoSessionCacheFilePath(dir, name))
+	}
+	return append(paths, ssoCacheFilePath(dir, startURL))
 }
 
 // Token cache helpers
 
+// issuedTokens holds the access tokens cached by this process, which
+// Config.NoCache doesn't bypass, so a command logs in once rather than for
+// every operation
+var issuedTokens sync.Map
+
+// cachedByThisProcess reports whether token was cached by this process
+func cachedByThisProcess(token *Token) bool {
+	_, ok := issuedTokens.Load(token.AccessToken)
+	return ok
+}
+
+// TokenCache is the SSO token cache in the cache directory of a Config,
+// Config.SSOCacheDir or else SSOCacheDir, logging through the Config. Its
+// methods are the token cache functions of the same names for that directory.
+type TokenCache struct {
+	config *Config
+}
+
+// NewTokenCache returns the SSO token cache of cfg, which may be nil
+func NewTokenCache(cfg *Config) *TokenCache {
+	return &TokenCache{config: cfg}
+}
+
+// location returns the cache directory of the token of startURL and the
+// sso-sessions using it
+func (c *TokenCache) location(startURL string) (string, []string) {
+	return getSSOCacheDir(c.config), ssoSessionsUsing(startURL)
+}
+
 // GetCachedToken retrieves a cached SSO token (AWS CLI compatible)
 func GetCachedToken(cache Cache, startURL string) (*Token, error) {
-	// Always use file system for SSO tokens to ensure AWS CLI compatibility
-	cachePath := GetSSOCacheFilePath(startURL)
+	return NewTokenCache(nil).GetCachedToken(startURL)
+}
+
+// GetCachedToken retrieves a cached SSO token from the cache directory
+func (c *TokenCache) GetCachedToken(startURL string) (*Token, error) {
+	dir, sessions := c.location(startURL)
+	return getCachedToken(dir, startURL, "", sessions)
+}
 
+// getCachedToken retrieves an unexpired SSO token from the cache in dir. A
+// token issued in another SSO region than ssoRegion, when both are known, is
+// not returned, so the user logs in again in the right region.
+func getCachedToken(dir, startURL, ssoRegion string, sessions []string) (*Token, error) {
+	token, err := readCachedToken(dir, startURL, sessions)
+	if err != nil || token == nil {
+		return nil, err
+	}
+
+	// Check if token is expired (with a buffer)
+	if !tokenValidFor(token, 0, time.Now()) {
+		return nil, nil
+	}
+
+	if ssoRegion != "" && token.Region != "" && token.Region != ssoRegion {
+		return nil, nil
+	}
+
+	return token, nil
+}
+
+// NeedsLogin reports whether a login is required for the cached SSO token of
+// a start URL to stay valid for minValidity: there is no token, or it expires
+// within minValidity. Like GetCachedToken, it treats a token expiring within
+// a few minutes as expired. A token file that can't be read or parsed needs a
+// login too, which replaces it.
+func NeedsLogin(cache Cache, startURL string, minValidity time.Duration) (bool, error) {
+	return NewTokenCache(nil).NeedsLogin(startURL, minValidity)
+}
+
+// NeedsLogin reports whether a login is required for the cached SSO token of
+// startURL in the cache directory to stay valid for minValidity
+func (c *TokenCache) NeedsLogin(startURL string, minValidity time.Duration) (bool, error) {
+	dir, sessions := c.location(startURL)
+	return needsLogin(dir, startURL, sessions, minValidity, time.Now(), c.config)
+}
+
+// needsLogin reports whether the cached token in dir is missing, unreadable or
+// expires within minValidity of now
+func needsLogin(dir, startURL string, sessions []string, minValidity time.Duration, now time.Time, cfg *Config) (bool, error) {
+	token, err := readCachedToken(dir, startURL, sessions)
+	if err != nil {
+		getLogger(cfg).Debug("Cached SSO token is unreadable, a login is needed",
+			slog.String("start_url", startURL), slog.Any("error", err))
+		return true, nil
+	}
+	return token == nil || !tokenValidFor(token, minValidity, now), nil
+}
+
+// tokenValidFor reports whether token is still usable after d from now. A
+// token expiring within defaultExpiryWindow is never usable.
+func tokenValidFor(token *Token, d time.Duration, now time.Time) bool {
+	return now.Add(max(d, defaultExpiryWindow)).Before(token.ExpiresAt)
+}
+
+// InspectCachedToken reports the state of the cached SSO token for a start
+// URL without making network calls. Unlike GetCachedToken it returns expired
+// tokens, so "never logged in" can be told apart from "session expired".
+func InspectCachedToken(cache Cache, startURL string) (*TokenStatus, error) {
+	return NewTokenCache(nil).InspectCachedToken(startURL)
+}
+
+// InspectCachedToken reports the state of the cached SSO token in the cache
+// directory
+func (c *TokenCache) InspectCachedToken(startURL string) (*TokenStatus, error) {
+	dir, sessions := c.location(startURL)
+	return inspectCachedToken(dir, startURL, sessions, time.Now())
+}
+
+// inspectCachedToken reports the state of the cached token in dir at now
+func inspectCachedToken(dir, startURL string, sessions []string, now time.Time) (*TokenStatus, error) {
+	paths := tokenCachePaths(dir, startURL, sessions)
+	status := &TokenStatus{
+		State: TokenStateMissing,
+		Path:  paths[0],
+	}
+
+	token, path, err := readCachedTokenFrom(paths, startURL)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return status, nil
+	}
+
+	status.Path = path
+
+	status.Token = token
+	status.ExpiresAt = token.ExpiresAt
+	status.State = tokenState(token.ExpiresAt, now)
+	return status, nil
+}
+
+// tokenState returns the state at now of a token expiring at expiresAt
+func tokenState(expiresAt, now time.Time) TokenState {
+	switch {
+	case !now.Before(expiresAt):
+		return TokenStateExpired
+	case now.After(expiresAt.Add(-defaultExpiryWindow)):
+		return TokenStateExpiring
+	default:
+		return TokenStateValid
+	}
+}
+
+// ListCachedSessions lists the tokens of the SSO cache directory of cfg,
+// sorted by start URL, without making network calls. Files that hold no
+// token, such as the client registrations of the AWS CLI, are skipped, and
+// so are files that can't be read.
+func ListCachedSessions(cfg *Config) ([]CachedSession, error) {
+	return listCachedSessions(getSSOCacheDir(cfg), time.Now())
+}
+
+// listCachedSessions lists the tokens of dir with their state at now
+func listCachedSessions(dir string, now time.Time) ([]CachedSession, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []CachedSession
+	for _, path := range paths {
+		token, err := readCachedTokenFile(path)
+		if err != nil || token == nil || token.AccessToken == "" || token.StartURL == "" {
+			continue
+		}
+		sessions = append(sessions, CachedSession{
+			StartURL:  token.StartURL,
+			Region:    token.Region,
+			State:     tokenState(token.ExpiresAt, now),
+			ExpiresAt: token.ExpiresAt,
+			Path:      path,
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].StartURL != sessions[j].StartURL {
+			return sessions[i].StartURL < sessions[j].StartURL
+		}
+		return sessions[i].Path < sessions[j].Path
+	})
+	return sessions, nil
+}
+
+// readCachedToken reads the cached token for a start URL without checking its
+// expiry, so the client registration of an expired token can be reused
+func readCachedToken(dir, startURL string, sessions []string) (*Token, error) {
+	token, _, err := readCachedTokenFrom(tokenCachePaths(dir, startURL, sessions), startURL)
+	return token, err
+}
+
+// readCachedTokenFrom reads the token files of a start URL and returns the one
+// expiring last with its path, as the AWS CLI may have refreshed the file of an
+// sso-session since this library wrote the file of the start URL
+func readCachedTokenFrom(paths []string, startURL string) (*Token, string, error) {
+	var latest *Token
+	var latestPath string
+	for _, path := range paths {
+		token, err := readCachedTokenFile(path)
+		if err != nil {
+			var corrupt *CorruptTokenCacheError
+			if errors.As(err, &corrupt) {
+				corrupt.StartURL = startURL
+			}
+			return nil, "", err
+		}
+		// A session file may be left from before the session changed start URL
+		if token == nil || (token.StartURL != "" && token.StartURL != startURL) {
+			continue
+		}
+		if latest == nil || token.ExpiresAt.After(latest.ExpiresAt) {
+			latest, latestPath = token, path
+		}
+	}
+	return latest, latestPath, nil
+}
+
+// readCachedTokenFile reads a token file in the AWS CLI or this library's format
+func readCachedTokenFile(cachePath string) (*Token, error) {
 	data, err := os.ReadFile(cachePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -150,79 +463,123 @@ func GetCachedToken(cache Cache, startURL string) (*Token, error) {
 		// Fall back to our format
 		var token Token
 		if err := json.Unmarshal(data, &token); err != nil {
-			return nil, err
+			return nil, &CorruptTokenCacheError{Path: cachePath, Err: err}
 		}
-
-		// Check if token is expired
-		if time.Now().After(token.ExpiresAt) {
-			return nil, nil
-		}
-
 		return &token, nil
 	}
 
 	// Convert AWS CLI token to our format
-	expiresAt, err := time.Parse("2006-01-02T15:04:05Z", awsToken.ExpiresAt)
+	expiresAt, err := parseCacheTime(awsToken.ExpiresAt)
 	if err != nil {
-		// Try RFC3339 format as fallback
-		expiresAt, err = time.Parse(time.RFC3339, awsToken.ExpiresAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse token expiry: %w", err)
-		}
+		return nil, &CorruptTokenCacheError{Path: cachePath, Err: fmt.Errorf("failed to parse token expiry: %w", err)}
 	}
 
-	// Check if token is expired (with 5-minute buffer)
-	if time.Now().After(expiresAt.Add(-5 * time.Minute)) {
-		return nil, nil
-	}
-
-	// Convert to our Token format
 	token := &Token{
 		AccessToken:  awsToken.AccessToken,
 		ExpiresAt:    expiresAt,
+		RefreshToken: awsToken.RefreshToken,
 		ClientID:     awsToken.ClientID,
 		ClientSecret: awsToken.ClientSecret,
 		Region:       awsToken.Region,
 		StartURL:     awsToken.StartURL,
+		Scopes:       awsToken.Scopes,
 	}
 
 	// Handle ReceivedAt if present
 	if awsToken.ReceivedAt != "" {
-		if registrationTime, err := time.Parse("2006-01-02T15:04:05Z", awsToken.ReceivedAt); err == nil {
+		if registrationTime, err := parseCacheTime(awsToken.ReceivedAt); err == nil {
 			token.RegistrationTime = registrationTime
 		}
 	}
 
+	if awsToken.RegistrationExpiresAt != "" {
+		if registrationExpiresAt, err := parseCacheTime(awsToken.RegistrationExpiresAt); err == nil {
+			token.RegistrationExpiresAt = registrationExpiresAt
+		}
+	}
+
 	return token, nil
 }
 
+// removeCorruptToken removes the token file of a CorruptTokenCacheError, so
+// the next login isn't blocked by it, and reports whether err was one
+func removeCorruptToken(err error, logger *slog.Logger) bool {
+	var corrupt *CorruptTokenCacheError
+	if !errors.As(err, &corrupt) {
+		return false
+	}
+	logger.Warn("Removing corrupt cached SSO token",
+		slog.String("path", corrupt.Path), slog.Any("error", corrupt.Err))
+	if err := os.Remove(corrupt.Path); err != nil && !os.IsNotExist(err) {
+		logger.Warn("Failed to remove corrupt cached SSO token", slog.Any("error", err))
+	}
+	return true
+}
+
+// cacheTimeFormat is the timestamp format of the AWS CLI token cache, always in UTC
+const cacheTimeFormat = "2006-01-02T15:04:05Z"
+
+// formatCacheTime formats a timestamp for the AWS CLI token cache
+func formatCacheTime(t time.Time) string {
+	return t.UTC().Format(cacheTimeFormat)
+}
+
+// parseCacheTime parses a timestamp from the AWS CLI token cache
+func parseCacheTime(value string) (time.Time, error) {
+	t, err := time.Parse(cacheTimeFormat, value)
+	if err != nil {
+		// Try RFC3339 format as fallback
+		return time.Parse(time.RFC3339, value)
+	}
+	return t, nil
+}
+
 // PutCachedToken stores an SSO token in the cache (AWS CLI compatible format)
 func PutCachedToken(cache Cache, startURL string, token *Token) error {
-	// Always use file system for SSO tokens to ensure AWS CLI compatibility
-	cachePath := GetSSOCacheFilePath(startURL)
+	return NewTokenCache(nil).PutCachedToken(startURL, token)
+}
 
+// PutCachedToken stores an SSO token in the cache directory
+func (c *TokenCache) PutCachedToken(startURL string, token *Token) error {
+	dir, sessions := c.location(startURL)
+	return putCachedToken(dir, startURL, sessions, token, c.config)
+}
+
+// putCachedToken stores an SSO token in the cache in dir, logging through cfg.
+// It is written to the files of sessions, the sso-sessions using the start
+// URL, too, so both the AWS CLI and older tools hashing the start URL find it.
+func putCachedToken(dir, startURL string, sessions []string, token *Token, cfg *Config) error {
 	// Ensure cache directory exists
-	cacheDir := filepath.Dir(cachePath)
-	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+	if err := os.MkdirAll(dir, 0700); err != nil {
 		return fmt.Errorf("failed to create SSO cache directory: %w", err)
 	}
 
+	// A umask or another tool may have created the directory readable by
+	// others, which the AWS CLI and SDKs refuse
+	if err := restrictPermissions(dir, 0700, cfg); err != nil {
+		return fmt.Errorf("failed to restrict SSO cache directory permissions: %w", err)
+	}
+
 	// Convert to AWS CLI format
 	awsToken := AWSCLIToken{
 		StartURL:     startURL,
 		Region:       token.Region,
 		AccessToken:  token.AccessToken,
-		ExpiresAt:    token.ExpiresAt.Format("2006-01-02T15:04:05Z"),
-		ReceivedAt:   time.Now().Format("2006-01-02T15:04:05Z"),
+		ExpiresAt:    formatCacheTime(token.ExpiresAt),
+		RefreshToken: token.RefreshToken,
+		ReceivedAt:   formatCacheTime(time.Now()),
 		ClientID:     token.ClientID,
 		ClientSecret: token.ClientSecret,
+		Scopes:       token.Scopes,
 	}
 
 	// Set registration expiry if we have client credentials
 	if token.ClientID != "" && token.ClientSecret != "" {
-		// Client registration typically expires in 90 days
-		registrationExpiry := time.Now().Add(90 * 24 * time.Hour)
-		awsToken.RegistrationExpiresAt = registrationExpiry.Format("2006-01-02T15:04:05Z")
+		registrationExpiry := token.RegistrationExpiresAt
+		if registrationExpiry.IsZero() {
+			registrationExpiry = time.Now().Add(defaultRegistrationLifetime)
+		}
+		awsToken.RegistrationExpiresAt = formatCacheTime(registrationExpiry)
 	}
 
 	// Marshal with indentation to match AWS CLI format
@@ -231,20 +588,97 @@ func PutCachedToken(cache Cache, startURL string, token *Token) error {
 		return fmt.Errorf("failed to marshal token: %w", err)
 	}
 
-	// Write with proper permissions
-	if err := os.WriteFile(cachePath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write cached token: %w", err)
+	issuedTokens.Store(token.AccessToken, struct{}{})
+
+	// Always use file system for SSO tokens to ensure AWS CLI compatibility
+	for _, cachePath := range tokenCachePaths(dir, startURL, sessions) {
+		// Replace the file rather than write into it, which would expose the
+		// token through the permissions of an existing file until restricted
+		if err := writeFileAtomic(cachePath, data, 0600); err != nil {
+			return fmt.Errorf("failed to write cached token: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// restrictPermissions removes the permission bits of path that perm doesn't
+// allow, warning when it had to. Windows doesn't use Unix permissions, so
+// nothing is changed there.
+func restrictPermissions(path string, perm os.FileMode, cfg *Config) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	current := info.Mode().Perm()
+	if current&^perm == 0 {
+		return nil
 	}
 
+	getLogger(cfg).Warn("SSO cache permissions were too open, restricting them",
+		slog.String("path", path),
+		slog.String("permissions", fmt.Sprintf("%#o", current)),
+		slog.String("restricted_to", fmt.Sprintf("%#o", current&perm)))
+	return os.Chmod(path, current&perm)
+}
+
+// SetCachedToken validates a token obtained outside the library, such as by an
+// embedding application or a test, and stores it in the cache for startURL so
+// that later operations use it instead of logging in
+func SetCachedToken(cache Cache, startURL string, token *Token) error {
+	return NewTokenCache(nil).SetCachedToken(startURL, token)
+}
+
+// SetCachedToken validates a token obtained outside the library and stores it
+// in the cache directory
+func (c *TokenCache) SetCachedToken(startURL string, token *Token) error {
+	if err := validateToken(startURL, token); err != nil {
+		return err
+	}
+	return c.PutCachedToken(startURL, token)
+}
+
+// validateToken checks that a supplied token is usable for startURL
+func validateToken(startURL string, token *Token) error {
+	if err := ValidateStartURL(startURL); err != nil {
+		return err
+	}
+	if token == nil || token.AccessToken == "" {
+		return &InvalidConfigError{Message: "token has no access token"}
+	}
+	if token.ExpiresAt.IsZero() || !time.Now().Before(token.ExpiresAt) {
+		return &InvalidConfigError{Message: "token has expired"}
+	}
+	if token.StartURL != "" && token.StartURL != startURL {
+		return &InvalidConfigError{Message: fmt.Sprintf("token was issued for %s, not %s", token.StartURL, startURL)}
+	}
 	return nil
 }
 
 // DeleteCachedToken removes an SSO token from the cache
 func DeleteCachedToken(cache Cache, startURL string) error {
-	cachePath := GetSSOCacheFilePath(startURL)
-	err := os.Remove(cachePath)
-	if err != nil && !os.IsNotExist(err) {
-		return err
+	return NewTokenCache(nil).DeleteCachedToken(startURL)
+}
+
+// DeleteCachedToken removes an SSO token from the cache directory
+func (c *TokenCache) DeleteCachedToken(startURL string) error {
+	dir, sessions := c.location(startURL)
+	return deleteCachedToken(dir, startURL, sessions)
+}
+
+// deleteCachedToken removes an SSO token from the cache in dir, including the
+// files of sessions, the sso-sessions using the start URL
+func deleteCachedToken(dir, startURL string, sessions []string) error {
+	for _, cachePath := range tokenCachePaths(dir, startURL, sessions) {
+		err := os.Remove(cachePath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
 	}
 	return nil
 }
@@ -265,10 +699,16 @@ type CachedCredentials struct {
 	Expiration      time.Time `json:"Expiration"`
 }
 
-// GetCachedCredentials retrieves cached credentials
+// GetCachedCredentials retrieves cached credentials. A nil cache holds nothing.
 func GetCachedCredentials(cache Cache, cacheKey string) (*CachedCredentials, error) {
+	return getCachedCredentials(cache, cacheKey, 0)
+}
+
+// getCachedCredentials retrieves cached credentials valid for at least
+// minValidity
+func getCachedCredentials(cache Cache, cacheKey string, minValidity time.Duration) (*CachedCredentials, error) {
 	if cache == nil {
-		cache = NewMemoryCache()
+		return nil, nil
 	}
 
 	data, err := cache.Get(cacheKey)
@@ -281,18 +721,19 @@ func GetCachedCredentials(cache Cache, cacheKey string) (*CachedCredentials, err
 		return nil, err
 	}
 
-	// Check if credentials are expired
-	if time.Now().After(creds.Expiration) {
+	// Check if credentials are expired, or expire too soon to be used
+	if time.Now().Add(minValidity).After(creds.Expiration) {
 		return nil, nil
 	}
 
 	return &creds, nil
 }
 
-// PutCachedCredentials stores credentials in the cache
+// PutCachedCredentials stores credentials in the cache. With a nil cache the
+// credentials aren't stored anywhere.
 func PutCachedCredentials(cache Cache, cacheKey string, creds *CachedCredentials) error {
 	if cache == nil {
-		cache = NewMemoryCache()
+		return nil
 	}
 
 	data, err := json.Marshal(creds)
@@ -303,7 +744,38 @@ func PutCachedCredentials(cache Cache, cacheKey string, creds *CachedCredentials
 	return cache.Put(cacheKey, data)
 }
 
-// generateCredentialCacheKey creates a cache key for credentials
-func generateCredentialCacheKey(startURL, accountID, roleName string) string {
-	return fmt.Sprintf("aws-sso-creds-%s-%s-%s", startURL, accountID, roleName)
+// DeleteCachedCredentials removes cached credentials, for example after the
+// role's policies changed. A nil cache holds nothing.
+func DeleteCachedCredentials(cache Cache, cacheKey string) error {
+	if cache == nil {
+		return nil
+	}
+	return cache.Delete(cacheKey)
+}
+
+// CredentialCacheKey returns the key under which the role credentials of an
+// account and role are kept in GetAWSConfigInput.CredentialCache. The start
+// URL is hashed so the key is a valid file name for FileCache.
+func CredentialCacheKey(startURL, accountID, roleName string) string {
+	return fmt.Sprintf("aws-sso-creds-%x-%s-%s", sha1.Sum([]byte(startURL)), accountID, roleName)
+}
+
+// AWSCLICredentialCacheKey returns the name, without .json, of the file in
+// CLICacheDir where the AWS CLI caches the role credentials of an SSO profile.
+// sessionName is the profile's sso_session, or "" for a profile with its own
+// sso_start_url.
+func AWSCLICredentialCacheKey(startURL, sessionName, accountID, roleName string) string {
+	args := map[string]string{"accountId": accountID, "roleName": roleName}
+	if sessionName != "" {
+		args["sessionName"] = sessionName
+	} else {
+		args["startUrl"] = startURL
+	}
+
+	// The AWS CLI hashes the arguments as compact JSON with sorted keys
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(args)
+	return fmt.Sprintf("%x", sha1.Sum(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))))
 }
@@ -1,8 +1,10 @@
 package awsssolib
 
 import (
+	"context"
 	"os"
-	"strings"
+	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -87,7 +89,10 @@ func TestFileCache(t *testing.T) {
 }
 
 func TestTokenCaching(t *testing.T) {
-	// Test SSO token caching (uses real file paths for AWS CLI compatibility)
+	// Test SSO token caching in the AWS CLI format, in a temporary cache
+	// directory rather than the real ~/.aws/sso/cache
+	dir := t.TempDir()
+	t.Setenv("AWS_SSO_CACHE_DIR", dir)
 	startURL := "https://test.awsapps.com/start"
 
 	// Test token caching
@@ -98,13 +103,13 @@ func TestTokenCaching(t *testing.T) {
 		Region:      "us-east-1",
 	}
 
-	// Clean up any existing token first
-	DeleteCachedToken(nil, startURL)
-
 	err := PutCachedToken(nil, startURL, token)
 	if err != nil {
 		t.Fatalf("PutCachedToken failed: %v", err)
 	}
+	if _, err := os.Stat(ssoCacheFilePath(dir, startURL)); err != nil {
+		t.Errorf("Expected the token in the temporary cache directory: %v", err)
+	}
 
 	retrieved, err := GetCachedToken(nil, startURL)
 	if err != nil {
@@ -119,13 +124,17 @@ func TestTokenCaching(t *testing.T) {
 		t.Errorf("Expected access token %s, got %s", token.AccessToken, retrieved.AccessToken)
 	}
 
-	// Clean up the previous token before testing expired token
-	DeleteCachedToken(nil, startURL)
+	if err := DeleteCachedToken(nil, startURL); err != nil {
+		t.Fatalf("DeleteCachedToken failed: %v", err)
+	}
+	if _, err := os.Stat(ssoCacheFilePath(dir, startURL)); !os.IsNotExist(err) {
+		t.Errorf("Expected the token file to be removed, got %v", err)
+	}
 
 	// Test expired token (expired by more than 5-minute buffer)
 	expiredToken := &Token{
 		AccessToken: "expired-token",
-		ExpiresAt:   time.Now().UTC().Add(-10 * time.Minute), // Expired beyond buffer, ensure UTC
+		ExpiresAt:   time.Now().Add(-10 * time.Minute), // Expired beyond buffer
 		StartURL:    startURL,
 		Region:      "us-east-1",
 	}
@@ -143,26 +152,49 @@ func TestTokenCaching(t *testing.T) {
 	if retrieved != nil {
 		t.Errorf("Expected nil for expired token, but got token with expiry: %s (current time: %s)", retrieved.ExpiresAt, time.Now())
 	}
-
-	// Clean up
-	DeleteCachedToken(nil, startURL)
 }
 
 func TestAWSCLICompatibility(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_SSO_CACHE_DIR", dir)
 	startURL := "https://test.awsapps.com/start"
 
-	// Clean up first
-	DeleteCachedToken(nil, startURL)
-
 	// Test that our cache file path matches expected SHA1 format
 	cachePath := GetSSOCacheFilePath(startURL)
 	expectedHash := "bfe9e37c85cc299e34d8c03b631672483f78cd01" // SHA1 of the test URL
-	if !strings.Contains(cachePath, expectedHash) {
-		t.Errorf("Cache path %s doesn't contain expected hash %s", cachePath, expectedHash)
+	if cachePath != filepath.Join(dir, expectedHash+".json") {
+		t.Errorf("Cache path %s doesn't match expected hash %s in %s", cachePath, expectedHash, dir)
+	}
+}
+
+func TestCredentialCacheKeys(t *testing.T) {
+	// Hashes of the AWS CLI's sorted compact JSON arguments
+	if key := AWSCLICredentialCacheKey("https://my-sso.awsapps.com/start", "", "123456789012", "Admin"); key != "f848b46584e3d651a393065c9467194305a53133" {
+		t.Errorf("Unexpected key %s for a start URL", key)
+	}
+	if key := AWSCLICredentialCacheKey("https://my-sso.awsapps.com/start", "my-sso", "123456789012", "Admin"); key != "c30b99aca80d8f5b3178e353f05b2abcc9a4ccd3" {
+		t.Errorf("Unexpected key %s for an sso-session", key)
 	}
 
-	// Clean up
-	DeleteCachedToken(nil, startURL)
+	cache := NewMemoryCache()
+	creds := &CachedCredentials{AccessKeyID: "AKIA", Expiration: time.Now().Add(time.Hour)}
+	admin := CredentialCacheKey("https://my-sso.awsapps.com/start", "123456789012", "Admin")
+	readOnly := CredentialCacheKey("https://my-sso.awsapps.com/start", "123456789012", "ReadOnly")
+	PutCachedCredentials(cache, admin, creds)
+	PutCachedCredentials(cache, readOnly, creds)
+
+	if err := DeleteCachedCredentials(cache, admin); err != nil {
+		t.Fatalf("DeleteCachedCredentials failed: %v", err)
+	}
+	if cached, _ := GetCachedCredentials(cache, admin); cached != nil {
+		t.Error("Expected the deleted credentials to be gone")
+	}
+	if cached, _ := GetCachedCredentials(cache, readOnly); cached == nil {
+		t.Error("Expected the credentials of other roles to be kept")
+	}
+	if err := DeleteCachedCredentials(nil, admin); err != nil {
+		t.Errorf("Expected a nil cache to be a no-op, got %v", err)
+	}
 }
 
 func TestGenerateProfileName(t *testing.T) {
@@ -219,3 +251,387 @@ func TestFormatAccountID(t *testing.T) {
 		}
 	}
 }
+
+func TestSetCachedToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	startURL := "https://test.awsapps.com/start"
+
+	token := &Token{AccessToken: "seeded-token", ExpiresAt: time.Now().Add(time.Hour), Region: "us-east-1"}
+	if err := SetCachedToken(nil, startURL, token); err != nil {
+		t.Fatalf("SetCachedToken failed: %v", err)
+	}
+
+	retrieved, err := GetCachedToken(nil, startURL)
+	if err != nil || retrieved == nil || retrieved.AccessToken != "seeded-token" {
+		t.Fatalf("Expected the seeded token, got %+v (%v)", retrieved, err)
+	}
+
+	invalid := map[string]*Token{
+		"nil":          nil,
+		"empty":        {ExpiresAt: time.Now().Add(time.Hour)},
+		"expired":      {AccessToken: "token", ExpiresAt: time.Now().Add(-time.Minute)},
+		"no expiry":    {AccessToken: "token"},
+		"other portal": {AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour), StartURL: "https://other.awsapps.com/start"},
+	}
+	for name, token := range invalid {
+		if err := SetCachedToken(nil, startURL, token); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoginUseToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	startURL := "https://test.awsapps.com/start"
+
+	token := &Token{AccessToken: "supplied-token", ExpiresAt: time.Now().Add(time.Hour)}
+	output, err := Login(context.Background(), LoginInput{
+		StartURL:  startURL,
+		SSORegion: "us-east-1",
+		UseToken:  token,
+		UserAuthHandler: func(ctx context.Context, params AuthHandlerParams) error {
+			t.Error("Expected the device flow to be bypassed")
+			return ErrLoginCancelled
+		},
+	})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if output.Token.AccessToken != "supplied-token" {
+		t.Errorf("Expected the supplied token, got %s", output.Token.AccessToken)
+	}
+	if output.Source != LoginSourceSupplied {
+		t.Errorf("Expected source %s, got %s", LoginSourceSupplied, output.Source)
+	}
+
+	output, err = Login(context.Background(), LoginInput{StartURL: startURL, SSORegion: "us-east-1"})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if output.Source != LoginSourceCached || output.Registered {
+		t.Errorf("Expected the cached token without a registration, got %+v", output)
+	}
+
+	cached, err := GetCachedToken(nil, startURL)
+	if err != nil || cached == nil || cached.AccessToken != "supplied-token" {
+		t.Errorf("Expected the supplied token to be cached, got %+v (%v)", cached, err)
+	}
+
+	if _, err := Login(context.Background(), LoginInput{
+		StartURL:  startURL,
+		SSORegion: "us-east-1",
+		UseToken:  &Token{AccessToken: "old", ExpiresAt: time.Now().Add(-time.Hour)},
+	}); err == nil {
+		t.Error("Expected an error for an expired supplied token")
+	}
+}
+
+func TestSSOCacheDirFollowsHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	expected := filepath.Join(home, ".aws", "sso", "cache")
+	if dir := SSOCacheDir(); dir != expected {
+		t.Errorf("Expected cache dir %s, got %s", expected, dir)
+	}
+	if path := GetSSOCacheFilePath("https://test.awsapps.com/start"); filepath.Dir(path) != expected {
+		t.Errorf("Expected token file in %s, got %s", expected, path)
+	}
+}
+
+func TestSSOCacheDirOverride(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+	startURL := "https://test.awsapps.com/start"
+	dir := t.TempDir()
+
+	token := &Token{AccessToken: "sandboxed-token", ExpiresAt: time.Now().Add(time.Hour)}
+	if _, err := Login(context.Background(), LoginInput{
+		StartURL:  startURL,
+		SSORegion: "us-east-1",
+		UseToken:  token,
+		Config:    &Config{SSOCacheDir: dir},
+	}); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	if _, err := os.Stat(ssoCacheFilePath(dir, startURL)); err != nil {
+		t.Errorf("Expected the token in the configured cache directory: %v", err)
+	}
+	if cached, _ := GetCachedToken(nil, startURL); cached != nil {
+		t.Error("Expected the default cache directory to be untouched")
+	}
+
+	// The token cache functions read the configured directory too
+	tokenCache := NewTokenCache(&Config{SSOCacheDir: dir})
+	if cached, err := tokenCache.GetCachedToken(startURL); err != nil || cached == nil {
+		t.Errorf("Expected the token from the configured directory, got %+v (%v)", cached, err)
+	}
+	if status, err := tokenCache.InspectCachedToken(startURL); err != nil || status.State != TokenStateValid {
+		t.Errorf("Expected a valid token in the configured directory, got %+v (%v)", status, err)
+	}
+	if needed, err := tokenCache.NeedsLogin(startURL, 0); err != nil || needed {
+		t.Errorf("Expected no login to be needed, got %v (%v)", needed, err)
+	}
+	other := &Token{AccessToken: "other-token", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := tokenCache.SetCachedToken("https://other.awsapps.com/start", other); err != nil {
+		t.Fatalf("SetCachedToken failed: %v", err)
+	}
+	if _, err := os.Stat(ssoCacheFilePath(dir, "https://other.awsapps.com/start")); err != nil {
+		t.Errorf("Expected the set token in the configured cache directory: %v", err)
+	}
+	if err := tokenCache.DeleteCachedToken("https://other.awsapps.com/start"); err != nil {
+		t.Fatalf("DeleteCachedToken failed: %v", err)
+	}
+	if cached, _ := tokenCache.GetCachedToken("https://other.awsapps.com/start"); cached != nil {
+		t.Error("Expected the token to be deleted from the configured directory")
+	}
+
+	t.Setenv("AWS_SSO_CACHE_DIR", dir)
+	if cached, err := GetCachedToken(nil, startURL); err != nil || cached == nil || cached.AccessToken != "sandboxed-token" {
+		t.Errorf("Expected AWS_SSO_CACHE_DIR to select the cache directory, got %+v (%v)", cached, err)
+	}
+}
+
+func TestInspectCachedToken(t *testing.T) {
+	dir := t.TempDir()
+	startURL := "https://test.awsapps.com/start"
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	status, err := inspectCachedToken(dir, startURL, nil, time.Now())
+	if err != nil {
+		t.Fatalf("inspectCachedToken failed: %v", err)
+	}
+	if status.State != TokenStateMissing || status.Token != nil || status.Path != ssoCacheFilePath(dir, startURL) {
+		t.Errorf("Expected a missing token, got %+v", status)
+	}
+
+	if err := putCachedToken(dir, startURL, nil, &Token{AccessToken: "token", ExpiresAt: expiresAt, Region: "us-east-1"}, nil); err != nil {
+		t.Fatalf("putCachedToken failed: %v", err)
+	}
+
+	states := map[TokenState]time.Time{
+		TokenStateValid:    expiresAt.Add(-time.Hour),
+		TokenStateExpiring: expiresAt.Add(-time.Minute),
+		TokenStateExpired:  expiresAt.Add(time.Minute),
+	}
+	for want, now := range states {
+		status, err := inspectCachedToken(dir, startURL, nil, now)
+		if err != nil {
+			t.Fatalf("inspectCachedToken failed: %v", err)
+		}
+		if status.State != want {
+			t.Errorf("Expected %s at %s, got %s", want, now, status.State)
+		}
+		if status.Token == nil || status.Token.AccessToken != "token" || !status.ExpiresAt.Equal(expiresAt) {
+			t.Errorf("Expected the cached token with its expiry, got %+v", status)
+		}
+	}
+}
+
+func TestNeedsLogin(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	startURL := "https://test.awsapps.com/start"
+	now := time.Now()
+
+	if needed, err := needsLogin(dir, startURL, nil, time.Hour, now, nil); err != nil || !needed {
+		t.Errorf("Expected a login without a token, got %v (%v)", needed, err)
+	}
+
+	token := &Token{AccessToken: "token", ExpiresAt: now.Add(time.Hour), Region: "us-east-1"}
+	if err := putCachedToken(dir, startURL, nil, token, nil); err != nil {
+		t.Fatalf("putCachedToken failed: %v", err)
+	}
+
+	tests := []struct {
+		minValidity time.Duration
+		at          time.Time
+		want        bool
+	}{
+		{0, now, false},
+		{30 * time.Minute, now, false},
+		{2 * time.Hour, now, true},
+		// Tokens about to expire are never used
+		{0, now.Add(57 * time.Minute), true},
+		{0, now.Add(2 * time.Hour), true},
+	}
+	for _, tt := range tests {
+		needed, err := needsLogin(dir, startURL, nil, tt.minValidity, tt.at, nil)
+		if err != nil {
+			t.Fatalf("needsLogin failed: %v", err)
+		}
+		if needed != tt.want {
+			t.Errorf("Expected %v for %s of validity at %s, got %v", tt.want, tt.minValidity, tt.at.Sub(now), needed)
+		}
+	}
+
+	// A corrupt token file needs a login rather than failing
+	if err := os.WriteFile(ssoCacheFilePath(dir, startURL), []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if needed, err := needsLogin(dir, startURL, nil, 0, now, nil); err != nil || !needed {
+		t.Errorf("Expected a login for a corrupt token file, got %v (%v)", needed, err)
+	}
+}
+
+func TestListCachedSessions(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	now := time.Now().Truncate(time.Second)
+
+	if sessions, err := listCachedSessions(filepath.Join(dir, "missing"), now); err != nil || len(sessions) != 0 {
+		t.Errorf("Expected no sessions without a cache directory, got %+v (%v)", sessions, err)
+	}
+
+	tokens := map[string]time.Time{
+		"https://b.awsapps.com/start": now.Add(time.Hour),
+		"https://a.awsapps.com/start": now.Add(-time.Hour),
+	}
+	for startURL, expiresAt := range tokens {
+		if err := putCachedToken(dir, startURL, nil, &Token{AccessToken: "token", ExpiresAt: expiresAt, Region: "eu-west-1"}, nil); err != nil {
+			t.Fatalf("putCachedToken failed: %v", err)
+		}
+	}
+	// Client registrations of the AWS CLI and unreadable files are skipped
+	if err := os.WriteFile(filepath.Join(dir, "botocore-client-id-us-east-1.json"), []byte(`{"clientId":"id","clientSecret":"secret"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := listCachedSessions(dir, now)
+	if err != nil {
+		t.Fatalf("listCachedSessions failed: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %+v", sessions)
+	}
+	if sessions[0].StartURL != "https://a.awsapps.com/start" || sessions[0].State != TokenStateExpired {
+		t.Errorf("Expected the expired session first, got %+v", sessions[0])
+	}
+	if sessions[1].State != TokenStateValid || sessions[1].Region != "eu-west-1" ||
+		sessions[1].Path != ssoCacheFilePath(dir, "https://b.awsapps.com/start") {
+		t.Errorf("Expected the valid session with its file, got %+v", sessions[1])
+	}
+}
+
+func TestGetSSOCacheFilePathForSession(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_SSO_CACHE_DIR", dir)
+
+	for name, hash := range map[string]string{
+		"my-sso":     "0ad374308c5a4e22f723adf10145eafad7c4031c",
+		"my-session": "9e28173066ce536e277c5fb355efd9c64f398167",
+	} {
+		if path := GetSSOCacheFilePathForSession(name); path != filepath.Join(dir, hash+".json") {
+			t.Errorf("Expected %s.json for session %s, got %s", hash, name, path)
+		}
+	}
+}
+
+func TestSSOSessionTokenCache(t *testing.T) {
+	dir := t.TempDir()
+	startURL := "https://test.awsapps.com/start"
+	configFile := filepath.Join(t.TempDir(), "config")
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	config := "[sso-session my-sso]\nsso_start_url = " + startURL + "\nsso_region = us-east-1\n"
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	sessions := ssoSessionsUsing(startURL)
+	if len(sessions) != 1 || sessions[0] != "my-sso" {
+		t.Fatalf("Expected the my-sso session, got %v", sessions)
+	}
+	sessionPath := ssoSessionCacheFilePath(dir, "my-sso")
+	urlPath := ssoCacheFilePath(dir, startURL)
+
+	// A token of the AWS CLI is only written to the file of the session
+	expiresAt := time.Now().Add(time.Hour).Format(time.RFC3339)
+	awsToken := `{"startUrl": "` + startURL + `", "region": "us-east-1", "accessToken": "cli-token", "expiresAt": "` + expiresAt + `"}`
+	if err := os.WriteFile(sessionPath, []byte(awsToken), 0600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+	token, err := getCachedToken(dir, startURL, "us-east-1", sessions)
+	if err != nil {
+		t.Fatalf("getCachedToken failed: %v", err)
+	}
+	if token == nil || token.AccessToken != "cli-token" {
+		t.Fatalf("Expected the token of the session, got %+v", token)
+	}
+
+	// The newer of the two files wins
+	staleExpiresAt := time.Now().Add(10 * time.Minute).Format(time.RFC3339)
+	data := `{"startUrl": "` + startURL + `", "accessToken": "stale-token", "expiresAt": "` + staleExpiresAt + `"}`
+	if err := os.WriteFile(urlPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+	status, err := inspectCachedToken(dir, startURL, sessions, time.Now())
+	if err != nil {
+		t.Fatalf("inspectCachedToken failed: %v", err)
+	}
+	if status.Token.AccessToken != "cli-token" || status.Path != sessionPath {
+		t.Errorf("Expected the token of the session file, got %s from %s", status.Token.AccessToken, status.Path)
+	}
+
+	// Tokens of this library are written for both
+	if err := putCachedToken(dir, startURL, sessions, &Token{AccessToken: "lib-token", ExpiresAt: time.Now().Add(2 * time.Hour), Region: "us-east-1"}, nil); err != nil {
+		t.Fatalf("putCachedToken failed: %v", err)
+	}
+	for _, path := range []string{sessionPath, urlPath} {
+		token, err := readCachedTokenFile(path)
+		if err != nil || token == nil || token.AccessToken != "lib-token" {
+			t.Errorf("Expected the new token in %s, got %+v (%v)", path, token, err)
+		}
+	}
+
+	if err := deleteCachedToken(dir, startURL, sessions); err != nil {
+		t.Fatalf("deleteCachedToken failed: %v", err)
+	}
+	for _, path := range []string{sessionPath, urlPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be deleted, got %v", path, err)
+		}
+	}
+}
+
+func TestPutCachedTokenRestrictsPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permissions only")
+	}
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	startURL := "https://test.awsapps.com/start"
+	path := ssoCacheFilePath(dir, startURL)
+
+	// Simulate a loose umask for both the directory and an existing token file
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatalf("Failed to create cache dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("{}"), 0600); err != nil {
+		t.Fatalf("Failed to create token file: %v", err)
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatalf("Failed to chmod cache dir: %v", err)
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatalf("Failed to chmod token file: %v", err)
+	}
+
+	token := &Token{AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour), Region: "us-east-1"}
+	if err := putCachedToken(dir, startURL, nil, token, nil); err != nil {
+		t.Fatalf("putCachedToken failed: %v", err)
+	}
+
+	for path, want := range map[string]os.FileMode{dir: 0700, path: 0600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if perm := info.Mode().Perm(); perm != want {
+			t.Errorf("Expected %s to have permissions %#o, got %#o", path, want, perm)
+		}
+	}
+}
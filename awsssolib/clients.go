@@ -0,0 +1,60 @@
+package awsssolib
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Version is the library version reported in the user agent of SSO and OIDC requests
+const Version = "0.3.0"
+
+// userAgentKey identifies this library in the user agent of SSO and OIDC requests
+const userAgentKey = "aws-sso-lib-go"
+
+// loadSDKConfig loads the AWS SDK config used for SSO, OIDC and SSO admin clients.
+// Every request made with it carries an identifiable user agent and goes
+// through the configured proxy.
+func loadSDKConfig(ctx context.Context, region string, cfg *Config, extra ...func(*config.LoadOptions) error) (aws.Config, error) {
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		config.WithAPIOptions(userAgentAPIOptions()),
+		config.WithHTTPClient(newHTTPClient(cfg)),
+	}
+
+	if cfg != nil && cfg.AppName != "" {
+		opts = append(opts, config.WithAppID(cfg.AppName))
+	}
+	opts = append(opts, extra...)
+
+	return config.LoadDefaultConfig(ctx, opts...)
+}
+
+// userAgentAPIOptions returns the middleware adding the library to the user agent
+func userAgentAPIOptions() []func(*middleware.Stack) error {
+	return []func(*middleware.Stack) error{
+		awsmiddleware.AddUserAgentKeyValue(userAgentKey, Version),
+	}
+}
+
+// proxyFunc selects the proxy for SSO and OIDC requests: Config.Proxy when set,
+// otherwise HTTPS_PROXY, HTTP_PROXY and NO_PROXY from the environment
+func proxyFunc(cfg *Config) func(*http.Request) (*url.URL, error) {
+	if cfg != nil && cfg.Proxy != nil {
+		return http.ProxyURL(cfg.Proxy)
+	}
+	return http.ProxyFromEnvironment
+}
+
+// newHTTPClient returns the SDK HTTP client with the proxy selection of cfg
+func newHTTPClient(cfg *Config) *awshttp.BuildableClient {
+	return awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+		tr.Proxy = proxyFunc(cfg)
+	})
+}
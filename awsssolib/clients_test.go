@@ -0,0 +1,195 @@
+package awsssolib
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func TestLoadSDKConfigUserAgent(t *testing.T) {
+	cfg, err := loadSDKConfig(context.Background(), "us-east-1", &Config{AppName: "my-app"})
+	if err != nil {
+		t.Fatalf("loadSDKConfig failed: %v", err)
+	}
+
+	if cfg.AppID != "my-app" {
+		t.Errorf("Expected app ID %s, got %s", "my-app", cfg.AppID)
+	}
+
+	// Apply the API options to a stack and check the user agent middleware is attached
+	stack := middleware.NewStack("test", smithyhttp.NewStackRequest)
+	for _, fn := range cfg.APIOptions {
+		if err := fn(stack); err != nil {
+			t.Fatalf("Applying API option failed: %v", err)
+		}
+	}
+
+	m, ok := stack.Build.Get((*awsmiddleware.RequestUserAgent)(nil).ID())
+	if !ok {
+		t.Fatal("Expected user agent middleware to be attached")
+	}
+
+	req := smithyhttp.NewStackRequest().(*smithyhttp.Request)
+	next := middleware.BuildHandlerFunc(func(ctx context.Context, in middleware.BuildInput) (middleware.BuildOutput, middleware.Metadata, error) {
+		return middleware.BuildOutput{}, middleware.Metadata{}, nil
+	})
+	if _, _, err := m.HandleBuild(context.Background(), middleware.BuildInput{Request: req}, next); err != nil {
+		t.Fatalf("HandleBuild failed: %v", err)
+	}
+
+	userAgent := req.Header.Get("User-Agent")
+	if !strings.Contains(userAgent, userAgentKey+"/"+Version) {
+		t.Errorf("User agent %q doesn't contain %s/%s", userAgent, userAgentKey, Version)
+	}
+}
+
+func TestLoadSDKConfigWithoutAppName(t *testing.T) {
+	cfg, err := loadSDKConfig(context.Background(), "us-east-1", nil)
+	if err != nil {
+		t.Fatalf("loadSDKConfig failed: %v", err)
+	}
+
+	if cfg.Region != "us-east-1" {
+		t.Errorf("Expected region us-east-1, got %s", cfg.Region)
+	}
+	if len(cfg.APIOptions) == 0 {
+		t.Error("Expected user agent API options to be set")
+	}
+}
+
+// stubProxy records the targets of CONNECT requests and refuses them
+type stubProxy struct {
+	mu      sync.Mutex
+	targets []string
+}
+
+func (p *stubProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.targets = append(p.targets, r.Method+" "+r.Host)
+	p.mu.Unlock()
+	http.Error(w, "proxy says no", http.StatusBadGateway)
+}
+
+func TestLoadSDKConfigProxy(t *testing.T) {
+	proxy := &stubProxy{}
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	proxyURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadSDKConfig(context.Background(), "us-east-1", &Config{Proxy: proxyURL})
+	if err != nil {
+		t.Fatalf("loadSDKConfig failed: %v", err)
+	}
+
+	client := ssooidc.NewFromConfig(cfg, func(o *ssooidc.Options) {
+		o.Retryer = aws.NopRetryer{}
+	})
+	if _, err := client.RegisterClient(context.Background(), &ssooidc.RegisterClientInput{
+		ClientName: aws.String(defaultClientName),
+		ClientType: aws.String(defaultClientType),
+	}); err == nil {
+		t.Fatal("Expected the request to fail at the stub proxy")
+	}
+
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	if len(proxy.targets) != 1 || proxy.targets[0] != "CONNECT oidc.us-east-1.amazonaws.com:443" {
+		t.Errorf("Expected the OIDC request to go through the proxy, got %v", proxy.targets)
+	}
+}
+
+func TestProxyFunc(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://portal.sso.us-east-1.amazonaws.com", nil)
+
+	override, _ := url.Parse("http://proxy.example.com:3128")
+	got, err := proxyFunc(&Config{Proxy: override})(req)
+	if err != nil || got.String() != override.String() {
+		t.Errorf("Expected the Config.Proxy override, got %v (%v)", got, err)
+	}
+
+	if reflect.ValueOf(proxyFunc(nil)).Pointer() != reflect.ValueOf(http.ProxyFromEnvironment).Pointer() {
+		t.Error("Expected the proxy environment variables without an override")
+	}
+}
+
+func TestGetAWSConfigSeparateRegions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_ENDPOINT_URL_SSO", "")
+	// Don't retry the refused requests
+	t.Setenv("AWS_MAX_ATTEMPTS", "1")
+
+	startURL := "https://test.awsapps.com/start"
+	if err := PutCachedToken(nil, startURL, &Token{AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour), Region: "us-east-1"}); err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+
+	// The proxy shows which regional endpoint role credentials are requested from
+	proxy := &stubProxy{}
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+	proxyURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := GetAWSConfigInput{
+		StartURL:  startURL,
+		SSORegion: "us-east-1",
+		AccountID: "123456789012",
+		RoleName:  "Admin",
+		Region:    "eu-west-1",
+		Config:    &Config{Proxy: proxyURL, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+	}
+	cfg, err := GetAWSConfig(context.Background(), input)
+	if err != nil {
+		t.Fatalf("GetAWSConfig failed: %v", err)
+	}
+	if cfg.Region != "eu-west-1" {
+		t.Errorf("Expected the config to target eu-west-1, got %s", cfg.Region)
+	}
+
+	if _, err := cfg.Credentials.Retrieve(context.Background()); err == nil {
+		t.Fatal("Expected the request to fail at the stub proxy")
+	}
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	if len(proxy.targets) == 0 {
+		t.Fatal("Expected role credentials to be requested through the proxy")
+	}
+	for _, target := range proxy.targets {
+		if target != "CONNECT portal.sso.us-east-1.amazonaws.com:443" {
+			t.Errorf("Expected role credentials from the SSO region, got %s", target)
+		}
+	}
+
+	// Errors name the region that is wrong
+	input.Region = "europe"
+	if _, err := GetAWSConfig(context.Background(), input); err == nil || !strings.Contains(err.Error(), "workload region") {
+		t.Errorf("Expected an invalid workload region, got %v", err)
+	}
+	input.Region, input.SSORegion = "eu-west-1", ""
+	if _, err := GetAWSConfig(context.Background(), input); err == nil || !strings.Contains(err.Error(), "SSO region") {
+		t.Errorf("Expected a missing SSO region, got %v", err)
+	}
+}
@@ -2,20 +2,44 @@ package awsssolib
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
+	"os/exec"
+	"os/user"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
 )
 
-// Default configuration file paths
+// Default configuration file paths, resolved when the package is initialized.
+//
+// Deprecated: use DefaultAWSConfigFilePath and DefaultAWSCredentialsFilePath,
+// which follow changes to the home directory.
 var (
-	DefaultAWSConfigFile      = filepath.Join(os.Getenv("HOME"), ".aws", "config")
-	DefaultAWSCredentialsFile = filepath.Join(os.Getenv("HOME"), ".aws", "credentials")
+	DefaultAWSConfigFile      = DefaultAWSConfigFilePath()
+	DefaultAWSCredentialsFile = DefaultAWSCredentialsFilePath()
 )
 
+// DefaultAWSConfigFilePath returns ~/.aws/config for the current home directory
+func DefaultAWSConfigFilePath() string {
+	return filepath.Join(userHomeDir(), ".aws", "config")
+}
+
+// DefaultAWSCredentialsFilePath returns ~/.aws/credentials for the current home directory
+func DefaultAWSCredentialsFilePath() string {
+	return filepath.Join(userHomeDir(), ".aws", "credentials")
+}
+
 // Profile represents an AWS CLI profile
 type Profile struct {
 	Name         string
@@ -26,25 +50,50 @@ type Profile struct {
 	RoleName     string
 	CredProcess  string
 	OutputFormat string
+	// SSOSession names an [sso-session] section holding the start URL and SSO region
+	SSOSession string
+	// Extra holds the keys the library doesn't know, such as cli_pager, so
+	// they survive a load and save. Nested values keep their indented lines.
+	Extra map[string]string
+}
+
+// SSOSession represents an [sso-session] section of the AWS config
+type SSOSession struct {
+	Name               string
+	StartURL           string
+	SSORegion          string
+	RegistrationScopes string
 }
 
 // ConfigFile represents AWS configuration
 type ConfigFile struct {
 	profiles map[string]*Profile
+	sessions map[string]*SSOSession
 }
 
 // NewConfigFile creates a new config file
 func NewConfigFile() *ConfigFile {
 	return &ConfigFile{
 		profiles: make(map[string]*Profile),
+		sessions: make(map[string]*SSOSession),
+	}
+}
+
+// ResolveConfigFilePath returns filename if set, otherwise the AWS config file
+// named by AWS_CONFIG_FILE, falling back to DefaultAWSConfigFilePath
+func ResolveConfigFilePath(filename string) string {
+	if filename != "" {
+		return filename
 	}
+	if envFile := os.Getenv("AWS_CONFIG_FILE"); envFile != "" {
+		return envFile
+	}
+	return DefaultAWSConfigFilePath()
 }
 
 // LoadConfigFile loads AWS config from file
 func LoadConfigFile(filename string) (*ConfigFile, error) {
-	if filename == "" {
-		filename = DefaultAWSConfigFile
-	}
+	filename = ResolveConfigFilePath(filename)
 
 	file, err := os.Open(filename)
 	if err != nil {
@@ -59,12 +108,23 @@ func LoadConfigFile(filename string) (*ConfigFile, error) {
 	scanner := bufio.NewScanner(file)
 
 	var currentProfile *Profile
+	var currentSession *SSOSession
 	profileRegex := regexp.MustCompile(`^\[profile\s+(.+)\]$`)
 	defaultRegex := regexp.MustCompile(`^\[default\]$`)
-	keyValueRegex := regexp.MustCompile(`^\s*(\w+)\s*=\s*(.+)$`)
+	sessionRegex := regexp.MustCompile(`^\[sso-session\s+(.+)\]$`)
+	keyValueRegex := regexp.MustCompile(`^\s*(\w+)\s*=\s*(.*)$`)
+	var nestedKey string
 
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+
+		// Keep the indented lines of a nested value, such as s3 settings
+		if currentProfile != nil && nestedKey != "" && line != "" && raw != strings.TrimLeft(raw, " \t") {
+			currentProfile.Extra[nestedKey] += "\n" + strings.TrimRight(raw, " \t")
+			continue
+		}
+		nestedKey = ""
 
 		// Skip empty lines and comments
 		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
@@ -75,6 +135,7 @@ func LoadConfigFile(filename string) (*ConfigFile, error) {
 		if matches := profileRegex.FindStringSubmatch(line); matches != nil {
 			profileName := matches[1]
 			currentProfile = &Profile{Name: profileName}
+			currentSession = nil
 			config.profiles[profileName] = currentProfile
 			continue
 		}
@@ -82,10 +143,42 @@ func LoadConfigFile(filename string) (*ConfigFile, error) {
 		// Check for default profile
 		if defaultRegex.MatchString(line) {
 			currentProfile = &Profile{Name: "default"}
+			currentSession = nil
 			config.profiles["default"] = currentProfile
 			continue
 		}
 
+		// Check for sso-session header
+		if matches := sessionRegex.FindStringSubmatch(line); matches != nil {
+			currentSession = &SSOSession{Name: matches[1]}
+			currentProfile = nil
+			config.sessions[currentSession.Name] = currentSession
+			continue
+		}
+
+		// Ignore other sections, such as [services]
+		if strings.HasPrefix(line, "[") {
+			currentProfile = nil
+			currentSession = nil
+			continue
+		}
+
+		// Parse sso-session key-value pairs
+		if currentSession != nil && keyValueRegex.MatchString(line) {
+			matches := keyValueRegex.FindStringSubmatch(line)
+			value := strings.TrimSpace(matches[2])
+
+			switch matches[1] {
+			case "sso_start_url":
+				currentSession.StartURL = value
+			case "sso_region":
+				currentSession.SSORegion = value
+			case "sso_registration_scopes":
+				currentSession.RegistrationScopes = value
+			}
+			continue
+		}
+
 		// Parse key-value pairs
 		if currentProfile != nil && keyValueRegex.MatchString(line) {
 			matches := keyValueRegex.FindStringSubmatch(line)
@@ -107,6 +200,16 @@ func LoadConfigFile(filename string) (*ConfigFile, error) {
 				currentProfile.CredProcess = value
 			case "output":
 				currentProfile.OutputFormat = value
+			case "sso_session":
+				currentProfile.SSOSession = value
+			default:
+				if currentProfile.Extra == nil {
+					currentProfile.Extra = make(map[string]string)
+				}
+				currentProfile.Extra[key] = value
+				if value == "" {
+					nestedKey = key
+				}
 			}
 		}
 	}
@@ -118,11 +221,32 @@ func LoadConfigFile(filename string) (*ConfigFile, error) {
 	return config, nil
 }
 
-// SaveConfigFile saves the config to file
+// DefaultMaxConfigBackups is the number of config backups kept by SaveConfigFile
+const DefaultMaxConfigBackups = 5
+
+// configBackupTimeFormat sorts lexically in chronological order
+const configBackupTimeFormat = "20060102T150405.000000000"
+
+// SaveOptions controls how the config file is written
+type SaveOptions struct {
+	// NoBackup disables the backup of the existing file before it is replaced
+	NoBackup bool
+	// MaxBackups is the number of backups to keep (default: DefaultMaxConfigBackups)
+	MaxBackups int
+}
+
+// SaveConfigFile saves the config to file, backing up the existing file first
 func (c *ConfigFile) SaveConfigFile(filename string) error {
-	if filename == "" {
-		filename = DefaultAWSConfigFile
-	}
+	return c.SaveConfigFileWithOptions(filename, SaveOptions{})
+}
+
+// SaveConfigFileWithOptions saves the config to file.
+//
+// Unless disabled, the existing file is copied to a timestamped
+// <filename>.bak.<timestamp> backup before it is replaced, and only the most
+// recent MaxBackups backups are kept.
+func (c *ConfigFile) SaveConfigFileWithOptions(filename string, opts SaveOptions) error {
+	filename = ResolveConfigFilePath(filename)
 
 	// Ensure directory exists
 	dir := filepath.Dir(filename)
@@ -139,8 +263,9 @@ func (c *ConfigFile) SaveConfigFile(filename string) error {
 
 	writer := bufio.NewWriter(tempFile)
 
-	// Write profiles
-	for name, profile := range c.profiles {
+	// Write profiles in sorted order so diffs are stable
+	for _, name := range c.ListProfiles() {
+		profile := c.profiles[name]
 		if name == "default" {
 			_, err = writer.WriteString("[default]\n")
 		} else {
@@ -151,6 +276,12 @@ func (c *ConfigFile) SaveConfigFile(filename string) error {
 		}
 
 		// Write profile properties
+		if profile.SSOSession != "" {
+			_, err = writer.WriteString(fmt.Sprintf("sso_session = %s\n", profile.SSOSession))
+			if err != nil {
+				return err
+			}
+		}
 		if profile.StartURL != "" {
 			_, err = writer.WriteString(fmt.Sprintf("sso_start_url = %s\n", profile.StartURL))
 			if err != nil {
@@ -193,6 +324,18 @@ func (c *ConfigFile) SaveConfigFile(filename string) error {
 				return err
 			}
 		}
+		for _, key := range sortedKeys(profile.Extra) {
+			value := profile.Extra[key]
+			// Empty and nested values have nothing after the equals sign
+			if value == "" || strings.HasPrefix(value, "\n") {
+				_, err = writer.WriteString(fmt.Sprintf("%s =%s\n", key, value))
+			} else {
+				_, err = writer.WriteString(fmt.Sprintf("%s = %s\n", key, value))
+			}
+			if err != nil {
+				return err
+			}
+		}
 
 		_, err = writer.WriteString("\n")
 		if err != nil {
@@ -200,6 +343,32 @@ func (c *ConfigFile) SaveConfigFile(filename string) error {
 		}
 	}
 
+	// Write sso-session sections after the profiles
+	for _, name := range c.ListSSOSessions() {
+		session := c.sessions[name]
+		if _, err := writer.WriteString(fmt.Sprintf("[sso-session %s]\n", name)); err != nil {
+			return err
+		}
+		if session.StartURL != "" {
+			if _, err := writer.WriteString(fmt.Sprintf("sso_start_url = %s\n", session.StartURL)); err != nil {
+				return err
+			}
+		}
+		if session.SSORegion != "" {
+			if _, err := writer.WriteString(fmt.Sprintf("sso_region = %s\n", session.SSORegion)); err != nil {
+				return err
+			}
+		}
+		if session.RegistrationScopes != "" {
+			if _, err := writer.WriteString(fmt.Sprintf("sso_registration_scopes = %s\n", session.RegistrationScopes)); err != nil {
+				return err
+			}
+		}
+		if _, err := writer.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+
 	if err := writer.Flush(); err != nil {
 		return err
 	}
@@ -207,10 +376,67 @@ func (c *ConfigFile) SaveConfigFile(filename string) error {
 		return err
 	}
 
+	if !opts.NoBackup {
+		if err := backupConfigFile(filename, opts.MaxBackups); err != nil {
+			return fmt.Errorf("failed to back up config: %w", err)
+		}
+	}
+
 	// Rename temp file to actual file
 	return os.Rename(tempFile.Name(), filename)
 }
 
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// backupConfigFile copies filename to a timestamped backup and removes the
+// oldest backups beyond maxBackups. A missing file needs no backup.
+func backupConfigFile(filename string, maxBackups int) error {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	backup := filename + ".bak." + time.Now().UTC().Format(configBackupTimeFormat)
+	if err := os.WriteFile(backup, data, mode); err != nil {
+		return err
+	}
+
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxConfigBackups
+	}
+
+	backups, err := filepath.Glob(filename + ".bak.*")
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+
+	for len(backups) > maxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
 // GetProfile returns a profile by name
 func (c *ConfigFile) GetProfile(name string) *Profile {
 	return c.profiles[name]
@@ -226,19 +452,78 @@ func (c *ConfigFile) RemoveProfile(name string) {
 	delete(c.profiles, name)
 }
 
-// ListProfiles returns all profile names
+// ListProfiles returns all profile names, sorted
 func (c *ConfigFile) ListProfiles() []string {
 	names := make([]string, 0, len(c.profiles))
 	for name := range c.profiles {
 		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
+
+// GetSSOSession returns an sso-session by name
+func (c *ConfigFile) GetSSOSession(name string) *SSOSession {
+	return c.sessions[name]
+}
+
+// SetSSOSession adds or updates an sso-session
+func (c *ConfigFile) SetSSOSession(session *SSOSession) {
+	c.sessions[session.Name] = session
+}
+
+// ListSSOSessions returns all sso-session names, sorted
+func (c *ConfigFile) ListSSOSessions() []string {
+	names := make([]string, 0, len(c.sessions))
+	for name := range c.sessions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 	return names
 }
 
-// GetSSOProfiles returns all profiles with SSO configuration
+// ResolveProfile returns a copy of a profile with the start URL and SSO region
+// of its sso_session filled in. Values set on the profile itself take precedence.
+func (c *ConfigFile) ResolveProfile(name string) (*Profile, error) {
+	profile := c.GetProfile(name)
+	if profile == nil {
+		return nil, &InvalidConfigError{Message: fmt.Sprintf("profile %s not found", name)}
+	}
+	return c.resolveProfile(profile)
+}
+
+// resolveProfile returns a copy of profile with the start URL and SSO region
+// of its sso-session in c filled in
+func (c *ConfigFile) resolveProfile(profile *Profile) (*Profile, error) {
+	resolved := *profile
+	if profile.SSOSession == "" {
+		return &resolved, nil
+	}
+
+	session := c.GetSSOSession(profile.SSOSession)
+	if session == nil {
+		return nil, &InvalidConfigError{Message: fmt.Sprintf("profile %s references missing sso-session %s", profile.Name, profile.SSOSession)}
+	}
+	if resolved.StartURL == "" {
+		resolved.StartURL = session.StartURL
+	}
+	if resolved.SSORegion == "" {
+		resolved.SSORegion = session.SSORegion
+	}
+
+	return &resolved, nil
+}
+
+// GetSSOProfiles returns all profiles with SSO configuration, sorted by name.
+// The profiles are copies resolved with ResolveProfile, so those using an
+// sso-session are included with its start URL and SSO region.
 func (c *ConfigFile) GetSSOProfiles() []*Profile {
 	profiles := make([]*Profile, 0)
-	for _, profile := range c.profiles {
+	for _, name := range c.ListProfiles() {
+		profile, err := c.resolveProfile(c.profiles[name])
+		if err != nil {
+			continue
+		}
 		if profile.StartURL != "" && profile.SSORegion != "" {
 			profiles = append(profiles, profile)
 		}
@@ -246,41 +531,79 @@ func (c *ConfigFile) GetSSOProfiles() []*Profile {
 	return profiles
 }
 
-// FindInstance finds SSO instance configuration from environment or config
+// FindInstance finds SSO instance configuration from a profile, the environment or config
 func FindInstance(profileName string) (*SSOInstance, error) {
-	// Check environment variables first
-	startURL := os.Getenv("AWS_DEFAULT_SSO_START_URL")
-	region := os.Getenv("AWS_DEFAULT_SSO_REGION")
+	return FindInstanceInConfigFile(profileName, "")
+}
+
+// FindInstanceInConfigFile finds SSO instance configuration in the given config
+// file. An empty filename uses ResolveConfigFilePath.
+//
+// A named profile must exist and have SSO configuration. Without a profile, the
+// AWS_DEFAULT_SSO_* environment variables are used, or their AWS_SSO_START_URL
+// and AWS_SSO_REGION aliases when they aren't set, then a .aws-sso project
+// file in the current directory or its parents, then the remote config at
+// AWS_SSO_CONFIG_URL (see FindInstanceFromURL), then the first SSO profile.
+func FindInstanceInConfigFile(profileName, filename string) (*SSOInstance, error) {
+	// An explicit profile takes precedence
+	if profileName != "" {
+		config, err := LoadConfigFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		profile, err := config.ResolveProfile(profileName)
+		if err != nil {
+			return nil, err
+		}
+		if profile.StartURL == "" || profile.SSORegion == "" {
+			return nil, &InvalidConfigError{Message: fmt.Sprintf("profile %s has no SSO configuration (sso_start_url and sso_region)", profileName)}
+		}
+
+		return &SSOInstance{
+			StartURL:       profile.StartURL,
+			Region:         profile.SSORegion,
+			StartURLSource: InstanceSourceProfile,
+			RegionSource:   InstanceSourceProfile,
+		}, nil
+	}
+
+	// Check environment variables
+	startURL, startURLSource := instanceEnv("AWS_DEFAULT_SSO_START_URL", "AWS_SSO_START_URL")
+	region, regionSource := instanceEnv("AWS_DEFAULT_SSO_REGION", "AWS_SSO_REGION")
 
 	if startURL != "" && region != "" {
 		return &SSOInstance{
 			StartURL:       startURL,
 			Region:         region,
-			StartURLSource: "environment",
-			RegionSource:   "environment",
+			StartURLSource: startURLSource,
+			RegionSource:   regionSource,
 		}, nil
 	}
 
-	// Check profile if specified
-	if profileName != "" {
-		config, err := LoadConfigFile("")
-		if err != nil {
-			return nil, err
-		}
+	// Check for a project config file
+	project, err := FindProjectConfig("")
+	if err != nil {
+		return nil, err
+	}
+	if project != nil && project.StartURL != "" && project.SSORegion != "" {
+		return &SSOInstance{
+			StartURL:       project.StartURL,
+			Region:         project.SSORegion,
+			StartURLSource: InstanceSourceProject,
+			RegionSource:   InstanceSourceProject,
+			AccountID:      project.AccountID,
+			RoleName:       project.RoleName,
+		}, nil
+	}
 
-		profile := config.GetProfile(profileName)
-		if profile != nil && profile.StartURL != "" && profile.SSORegion != "" {
-			return &SSOInstance{
-				StartURL:       profile.StartURL,
-				Region:         profile.SSORegion,
-				StartURLSource: "profile",
-				RegionSource:   "profile",
-			}, nil
-		}
+	// Check for a remote config
+	if configURL := os.Getenv(RemoteConfigURLEnvVar); configURL != "" {
+		return FindInstanceFromURL(context.Background(), configURL)
 	}
 
 	// Check all profiles in config
-	config, err := LoadConfigFile("")
+	config, err := LoadConfigFile(filename)
 	if err != nil {
 		return nil, err
 	}
@@ -292,16 +615,41 @@ func FindInstance(profileName string) (*SSOInstance, error) {
 		return &SSOInstance{
 			StartURL:       profile.StartURL,
 			Region:         profile.SSORegion,
-			StartURLSource: "config",
-			RegionSource:   "config",
+			StartURLSource: InstanceSourceConfig,
+			RegionSource:   InstanceSourceConfig,
 		}, nil
 	}
 
 	return nil, fmt.Errorf("no SSO configuration found")
 }
 
-// GenerateProfileName generates a profile name based on a template
+// instanceEnv returns the value of the environment variable name, or of its
+// alias when name isn't set, with the source it came from
+func instanceEnv(name, alias string) (string, string) {
+	if value := os.Getenv(name); value != "" {
+		return value, InstanceSourceEnvironment
+	}
+	return os.Getenv(alias), InstanceSourceEnvironmentAlias
+}
+
+// ProfileNameOptions controls how profile names are generated
+type ProfileNameOptions struct {
+	// PreserveCase keeps the original letter case instead of lowercasing the name
+	PreserveCase bool
+}
+
+// GenerateProfileName generates a profile name based on a template.
+//
+// Supported placeholders are {account_id}, {account_id_short} (last 4 digits),
+// {account_name}, {account_email} (local part of the email address),
+// {account_alias} (falls back to the account name), {role_name} and {region}.
 func GenerateProfileName(template string, account *Account, role *Role, region string) string {
+	return GenerateProfileNameWithOptions(template, account, role, region, ProfileNameOptions{})
+}
+
+// GenerateProfileNameWithOptions generates a profile name based on a template
+// using the given options. See GenerateProfileName for supported placeholders.
+func GenerateProfileNameWithOptions(template string, account *Account, role *Role, region string, opts ProfileNameOptions) string {
 	// Default template if empty
 	if template == "" {
 		template = "{account_name}.{role_name}.{region}"
@@ -309,22 +657,58 @@ func GenerateProfileName(template string, account *Account, role *Role, region s
 
 	// Replace placeholders
 	name := template
+	name = strings.ReplaceAll(name, "{account_id_short}", shortAccountID(account.AccountID))
 	name = strings.ReplaceAll(name, "{account_id}", account.AccountID)
 	name = strings.ReplaceAll(name, "{account_name}", sanitizeName(account.AccountName))
+	name = strings.ReplaceAll(name, "{account_email}", sanitizeName(emailLocalPart(account.EmailAddress)))
+	name = strings.ReplaceAll(name, "{account_alias}", sanitizeName(accountAlias(account)))
 	name = strings.ReplaceAll(name, "{role_name}", sanitizeName(role.RoleName))
 	name = strings.ReplaceAll(name, "{region}", region)
 
 	// Clean up the name
-	name = strings.ToLower(name)
-	name = regexp.MustCompile(`[^a-z0-9._-]`).ReplaceAllString(name, "-")
+	if !opts.PreserveCase {
+		name = strings.ToLower(name)
+	}
+	name = profileNameInvalidChars.ReplaceAllString(name, "-")
 	name = regexp.MustCompile(`-+`).ReplaceAllString(name, "-")
 	name = strings.Trim(name, "-")
 
 	return name
 }
 
+// profileNameInvalidChars matches characters not allowed in generated profile names
+var profileNameInvalidChars = regexp.MustCompile(`[^\p{L}\p{N}._-]`)
+
+// shortAccountID returns the last 4 digits of an account ID
+func shortAccountID(accountID string) string {
+	id := formatAccountID(accountID)
+	if len(id) <= 4 {
+		return id
+	}
+	return id[len(id)-4:]
+}
+
+// emailLocalPart returns the part of an email address before the @
+func emailLocalPart(email string) string {
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		return email[:i]
+	}
+	return email
+}
+
+// accountAlias returns the account alias, falling back to the account name
+func accountAlias(account *Account) string {
+	if account.Alias != "" {
+		return account.Alias
+	}
+	return account.AccountName
+}
+
 // sanitizeName removes special characters from names
 func sanitizeName(name string) string {
+	// Replace accented characters with their ASCII equivalents
+	name = transliterate(name)
+
 	// Remove common special characters
 	name = strings.ReplaceAll(name, " ", "-")
 	name = strings.ReplaceAll(name, "_", "-")
@@ -339,6 +723,22 @@ func sanitizeName(name string) string {
 	return name
 }
 
+// transliterations maps letters that don't decompose into a base letter and a diacritic
+var transliterations = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE",
+	"ø", "o", "Ø", "O", "ł", "l", "Ł", "L", "đ", "d", "Đ", "D", "þ", "th", "Þ", "TH",
+)
+
+// transliterate strips diacritics so that, for example, "São Paulo" becomes "Sao Paulo"
+func transliterate(name string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	result, _, err := transform.String(t, name)
+	if err != nil {
+		return name
+	}
+	return transliterations.Replace(result)
+}
+
 // Validation constants and functions
 var (
 	// AWS Account ID regex (12 digits)
@@ -347,6 +747,10 @@ var (
 	regionRegex = regexp.MustCompile(`^[a-z]{2}-[a-z]+-\d+$`)
 	// Role name regex (alphanumeric, plus =,.@_- characters)
 	roleNameRegex = regexp.MustCompile(`^[\w+=,.@_-]+$`)
+	// Role session name regex (2 to 64 of the characters STS allows)
+	roleSessionNameRegex = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+	// Characters STS doesn't allow in a role session name
+	invalidSessionNameChars = regexp.MustCompile(`[^\w+=,.@-]+`)
 )
 
 // ValidateStartURL validates an SSO start URL
@@ -376,6 +780,37 @@ func ValidateStartURL(startURL string) error {
 	return nil
 }
 
+// DefaultRegion is the workload region when no other source sets one
+const DefaultRegion = "us-east-1"
+
+// RegionSources are the places a workload region is taken from by ResolveRegion
+type RegionSources struct {
+	// Flag is a region given explicitly, e.g. with --region
+	Flag string
+	// Profile is the region of the AWS config profile in use
+	Profile string
+	// SSORegion is the region of the IAM Identity Center instance
+	SSORegion string
+}
+
+// ResolveRegion returns the workload region from the first source that sets
+// one, in order: the explicit flag, the profile region, AWS_REGION,
+// AWS_DEFAULT_REGION, the SSO region, then DefaultRegion
+func ResolveRegion(sources RegionSources) string {
+	for _, region := range []string{
+		sources.Flag,
+		sources.Profile,
+		os.Getenv("AWS_REGION"),
+		os.Getenv("AWS_DEFAULT_REGION"),
+		sources.SSORegion,
+	} {
+		if region != "" {
+			return region
+		}
+	}
+	return DefaultRegion
+}
+
 // ValidateRegion validates an AWS region
 func ValidateRegion(region string) error {
 	if region == "" {
@@ -389,6 +824,17 @@ func ValidateRegion(region string) error {
 	return nil
 }
 
+// validateRegionField validates a region, naming which of the regions of an
+// input it is, as the SSO region and the workload region are easily confused
+func validateRegionField(field, region string) error {
+	err := ValidateRegion(region)
+	var configErr *InvalidConfigError
+	if errors.As(err, &configErr) {
+		return &InvalidConfigError{Message: fmt.Sprintf("%s: %s", field, configErr.Message)}
+	}
+	return err
+}
+
 // ValidateAccountID validates an AWS account ID
 func ValidateAccountID(accountID string) error {
 	if accountID == "" {
@@ -422,6 +868,86 @@ func ValidateRoleName(roleName string) error {
 	return nil
 }
 
+// ValidateRoleSessionName validates an STS role session name
+func ValidateRoleSessionName(name string) error {
+	if !roleSessionNameRegex.MatchString(name) {
+		return &InvalidConfigError{Message: fmt.Sprintf("invalid role session name: %q (must be 2-64 characters of letters, digits and +=,.@_-)", name)}
+	}
+	return nil
+}
+
+// maxClientNameLength is the longest OIDC client name accepted
+const maxClientNameLength = 128
+
+// ValidateClientName validates the name of an OIDC client registration
+func ValidateClientName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return &InvalidConfigError{Message: "client name cannot be empty"}
+	}
+	if len(name) > maxClientNameLength {
+		return &InvalidConfigError{Message: fmt.Sprintf("client name too long: %d characters (max %d)", len(name), maxClientNameLength)}
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return &InvalidConfigError{Message: fmt.Sprintf("invalid client name: %q (must not contain control characters)", name)}
+		}
+	}
+	return nil
+}
+
+// ValidatePageSize validates the page size of the SSO list APIs
+func ValidatePageSize(size int) error {
+	if size < 1 || size > maxPageSize {
+		return &InvalidConfigError{Message: fmt.Sprintf("invalid page size: %d (must be 1-%d)", size, maxPageSize)}
+	}
+	return nil
+}
+
+// validateConfigPageSize validates Config.PageSize when it is set
+func validateConfigPageSize(config *Config) error {
+	if config == nil || config.PageSize == 0 {
+		return nil
+	}
+	return ValidatePageSize(config.PageSize)
+}
+
+// DefaultRoleSessionName returns a role session name made of the current
+// username and the tool name, with the characters STS doesn't allow replaced
+func DefaultRoleSessionName(toolName string) string {
+	if toolName == "" {
+		toolName = "aws-sso-lib"
+	}
+
+	name := toolName
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		// Drop the domain of Windows usernames
+		username := current.Username[strings.LastIndex(current.Username, `\`)+1:]
+		name = username + "@" + toolName
+	}
+
+	name = invalidSessionNameChars.ReplaceAllString(name, "-")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	if len(name) < 2 {
+		return "aws-sso-lib"
+	}
+	return name
+}
+
+// OutputFormats are the output formats accepted by the AWS CLI
+var OutputFormats = []string{"json", "yaml", "yaml-stream", "text", "table"}
+
+// ValidateOutputFormat validates an AWS CLI output format
+func ValidateOutputFormat(format string) error {
+	for _, valid := range OutputFormats {
+		if format == valid {
+			return nil
+		}
+	}
+	return &InvalidConfigError{Message: fmt.Sprintf("invalid output format: %s (must be one of %s)", format, strings.Join(OutputFormats, ", "))}
+}
+
 // ValidateProfile validates a complete profile configuration
 func ValidateProfile(profile *Profile) error {
 	if profile == nil {
@@ -451,15 +977,92 @@ func ValidateProfile(profile *Profile) error {
 		}
 	}
 
+	// Validate output format if present
+	if profile.OutputFormat != "" {
+		if err := ValidateOutputFormat(profile.OutputFormat); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// Validate checks the referential integrity of the profile's credential_process.
+//
+// When the credential process runs aws-sso-util, the profile it names with
+// --profile must exist in cf (or in the file given with --config-file) and have
+// SSO configuration. The credential process executable must be resolvable.
+func (p *Profile) Validate(cf *ConfigFile) error {
+	// The SSO settings of an sso_session profile are those of the session
+	resolved, err := cf.resolveProfile(p)
+	if err != nil {
+		return err
+	}
+	if err := ValidateProfile(resolved); err != nil {
+		return err
+	}
+	if p.CredProcess == "" {
+		return nil
+	}
+
+	args := strings.Fields(p.CredProcess)
+	if len(args) == 0 {
+		return &InvalidConfigError{Message: fmt.Sprintf("profile %s: credential_process is empty", p.Name)}
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return &InvalidConfigError{Message: fmt.Sprintf("profile %s: credential_process executable %q not found", p.Name, args[0])}
+	}
+
+	if filepath.Base(args[0]) != "aws-sso-util" {
+		return nil
+	}
+
+	target := credentialProcessFlag(args, "profile")
+	if target == "" {
+		return &InvalidConfigError{Message: fmt.Sprintf("profile %s: credential_process does not specify --profile", p.Name)}
+	}
+
+	if configFile := credentialProcessFlag(args, "config-file"); configFile != "" {
+		cf, err = LoadConfigFile(configFile)
+		if err != nil {
+			return &InvalidConfigError{Message: fmt.Sprintf("profile %s: failed to load credential_process config file %s: %v", p.Name, configFile, err)}
+		}
+	}
+
+	if cf.GetProfile(target) == nil {
+		return &InvalidConfigError{Message: fmt.Sprintf("profile %s: credential_process references missing profile %s", p.Name, target)}
+	}
+	referenced, err := cf.ResolveProfile(target)
+	if err != nil {
+		return &InvalidConfigError{Message: fmt.Sprintf("profile %s: %v", p.Name, err)}
+	}
+	if referenced.StartURL == "" || referenced.SSORegion == "" || referenced.AccountID == "" || referenced.RoleName == "" {
+		return &InvalidConfigError{Message: fmt.Sprintf("profile %s: credential_process references profile %s without SSO configuration", p.Name, target)}
+	}
+
+	return nil
+}
+
+// credentialProcessFlag returns the value of --name in a credential_process command line
+func credentialProcessFlag(args []string, name string) string {
+	flag := "--" + name
+	for i, arg := range args {
+		if arg == flag && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(arg, flag+"=") {
+			return strings.TrimPrefix(arg, flag+"=")
+		}
+	}
+	return ""
+}
+
 // ValidateGetAWSConfigInput validates input for GetAWSConfig
 func ValidateGetAWSConfigInput(input GetAWSConfigInput) error {
 	if err := ValidateStartURL(input.StartURL); err != nil {
 		return err
 	}
-	if err := ValidateRegion(input.SSORegion); err != nil {
+	if err := validateRegionField("SSO region", input.SSORegion); err != nil {
 		return err
 	}
 	if err := ValidateAccountID(input.AccountID); err != nil {
@@ -468,9 +1071,14 @@ func ValidateGetAWSConfigInput(input GetAWSConfigInput) error {
 	if err := ValidateRoleName(input.RoleName); err != nil {
 		return err
 	}
-	if err := ValidateRegion(input.Region); err != nil {
+	if err := validateRegionField("workload region", input.Region); err != nil {
 		return err
 	}
+	if input.RoleSessionName != "" {
+		if err := ValidateRoleSessionName(input.RoleSessionName); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -482,5 +1090,15 @@ func ValidateLoginInput(input LoginInput) error {
 	if err := ValidateRegion(input.SSORegion); err != nil {
 		return err
 	}
+	if input.Config != nil && input.Config.ClientName != "" {
+		if err := ValidateClientName(input.Config.ClientName); err != nil {
+			return err
+		}
+	}
+	if input.ClientName != "" {
+		if err := ValidateClientName(input.ClientName); err != nil {
+			return err
+		}
+	}
 	return nil
 }
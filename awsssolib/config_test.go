@@ -0,0 +1,541 @@
+package awsssolib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigFileFromEnvironment(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "sso-profiles")
+	t.Setenv("AWS_CONFIG_FILE", filename)
+	t.Setenv("AWS_DEFAULT_SSO_START_URL", "")
+	t.Setenv("AWS_DEFAULT_SSO_REGION", "")
+
+	if path := ResolveConfigFilePath(""); path != filename {
+		t.Errorf("Expected %s, got %s", filename, path)
+	}
+	if path := ResolveConfigFilePath("/explicit/config"); path != "/explicit/config" {
+		t.Errorf("Expected explicit path to take precedence, got %s", path)
+	}
+
+	config := NewConfigFile()
+	config.SetProfile(&Profile{
+		Name:      "dev",
+		StartURL:  "https://test.awsapps.com/start",
+		SSORegion: "us-east-1",
+		AccountID: "123456789012",
+		RoleName:  "Admin",
+	})
+	if err := config.SaveConfigFile(""); err != nil {
+		t.Fatalf("SaveConfigFile failed: %v", err)
+	}
+
+	instance, err := FindInstanceInConfigFile("", filename)
+	if err != nil {
+		t.Fatalf("FindInstanceInConfigFile failed: %v", err)
+	}
+	if instance.StartURL != "https://test.awsapps.com/start" {
+		t.Errorf("Expected start URL from config file, got %s", instance.StartURL)
+	}
+}
+
+func TestGenerateProfileNameAccountPlaceholders(t *testing.T) {
+	account := &Account{
+		AccountID:    "123456789012",
+		AccountName:  "Test Account",
+		EmailAddress: "Team.Infra+prod@example.com",
+	}
+	role := &Role{RoleName: "Admin"}
+
+	tests := []struct {
+		template string
+		account  *Account
+		expected string
+	}{
+		{"{account_email}.{role_name}", account, "team.infra-prod.admin"},
+		{"{account_id_short}-{role_name}", account, "9012-admin"},
+		{"{account_alias}-{role_name}", account, "test-account-admin"},
+		{"{account_alias}-{role_name}", &Account{AccountID: "123456789012", AccountName: "Test Account", Alias: "prod"}, "prod-admin"},
+		{"{account_id_short}", &Account{AccountID: "1234-5678-9012"}, "9012"},
+	}
+
+	for _, tt := range tests {
+		result := GenerateProfileName(tt.template, tt.account, role, "us-east-1")
+		if result != tt.expected {
+			t.Errorf("Template %s: expected %s, got %s", tt.template, tt.expected, result)
+		}
+	}
+}
+
+func TestGenerateProfileNameUnicode(t *testing.T) {
+	role := &Role{RoleName: "Admin"}
+
+	tests := []struct {
+		accountName  string
+		preserveCase bool
+		expected     string
+	}{
+		{"São Paulo", false, "sao-paulo.admin"},
+		{"Zürich Prod", false, "zurich-prod.admin"},
+		{"Straße", false, "strasse.admin"},
+		{"Ørsted Økonomi", false, "orsted-okonomi.admin"},
+		{"東京", false, "東京.admin"},
+		{"São Paulo", true, "Sao-Paulo.Admin"},
+	}
+
+	for _, tt := range tests {
+		account := &Account{AccountID: "123456789012", AccountName: tt.accountName}
+		result := GenerateProfileNameWithOptions("{account_name}.{role_name}", account, role, "us-east-1", ProfileNameOptions{PreserveCase: tt.preserveCase})
+		if result != tt.expected {
+			t.Errorf("Account name %s: expected %s, got %s", tt.accountName, tt.expected, result)
+		}
+	}
+}
+
+func TestSaveConfigFileBackups(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config")
+
+	config := NewConfigFile()
+	config.SetProfile(&Profile{Name: "dev", Region: "us-east-1"})
+
+	// The first save has nothing to back up
+	if err := config.SaveConfigFileWithOptions(filename, SaveOptions{MaxBackups: 2}); err != nil {
+		t.Fatalf("SaveConfigFileWithOptions failed: %v", err)
+	}
+	backups, _ := filepath.Glob(filename + ".bak.*")
+	if len(backups) != 0 {
+		t.Fatalf("Expected no backups, got %d", len(backups))
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := config.SaveConfigFileWithOptions(filename, SaveOptions{MaxBackups: 2}); err != nil {
+			t.Fatalf("SaveConfigFileWithOptions failed: %v", err)
+		}
+	}
+	backups, _ = filepath.Glob(filename + ".bak.*")
+	if len(backups) != 2 {
+		t.Errorf("Expected 2 backups to be kept, got %d", len(backups))
+	}
+
+	if err := config.SaveConfigFileWithOptions(filename, SaveOptions{NoBackup: true, MaxBackups: 2}); err != nil {
+		t.Fatalf("SaveConfigFileWithOptions failed: %v", err)
+	}
+	after, _ := filepath.Glob(filename + ".bak.*")
+	if len(after) != 2 || after[1] != backups[1] {
+		t.Errorf("Expected no new backup with NoBackup, got %v", after)
+	}
+}
+
+func TestConfigFilePreservesUnknownKeys(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config")
+	original := `[profile dev]
+region = us-east-1
+cli_pager =
+s3 =
+  max_concurrent_requests = 20
+  multipart_threshold = 64MB
+duration_seconds = 3600
+
+`
+	if err := os.WriteFile(filename, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfigFile(filename)
+	if err != nil {
+		t.Fatalf("LoadConfigFile failed: %v", err)
+	}
+	profile := config.GetProfile("dev")
+	if profile.Region != "us-east-1" || profile.Extra["duration_seconds"] != "3600" {
+		t.Errorf("Expected known and unknown keys to be parsed, got %+v", profile)
+	}
+
+	if err := config.SaveConfigFileWithOptions(filename, SaveOptions{NoBackup: true}); err != nil {
+		t.Fatalf("SaveConfigFileWithOptions failed: %v", err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[profile dev]
+region = us-east-1
+cli_pager =
+duration_seconds = 3600
+s3 =
+  max_concurrent_requests = 20
+  multipart_threshold = 64MB
+
+`
+	if string(data) != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, data)
+	}
+}
+
+func TestConfigFileDeterministicOrder(t *testing.T) {
+	config := NewConfigFile()
+	for _, name := range []string{"prod", "default", "dev", "staging", "audit"} {
+		config.SetProfile(&Profile{
+			Name:      name,
+			StartURL:  "https://test.awsapps.com/start",
+			SSORegion: "us-east-1",
+		})
+	}
+	config.SetProfile(&Profile{Name: "plain", Region: "us-east-1"})
+
+	expected := []string{"audit", "default", "dev", "plain", "prod", "staging"}
+	for i := 0; i < 5; i++ {
+		if names := config.ListProfiles(); !reflect.DeepEqual(names, expected) {
+			t.Fatalf("Expected %v, got %v", expected, names)
+		}
+	}
+
+	var ssoNames []string
+	for _, profile := range config.GetSSOProfiles() {
+		ssoNames = append(ssoNames, profile.Name)
+	}
+	if expectedSSO := []string{"audit", "default", "dev", "prod", "staging"}; !reflect.DeepEqual(ssoNames, expectedSSO) {
+		t.Errorf("Expected %v, got %v", expectedSSO, ssoNames)
+	}
+
+	filename := filepath.Join(t.TempDir(), "config")
+	if err := config.SaveConfigFileWithOptions(filename, SaveOptions{NoBackup: true}); err != nil {
+		t.Fatalf("SaveConfigFileWithOptions failed: %v", err)
+	}
+	first, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if err := config.SaveConfigFileWithOptions(filename, SaveOptions{NoBackup: true}); err != nil {
+		t.Fatalf("SaveConfigFileWithOptions failed: %v", err)
+	}
+	second, _ := os.ReadFile(filename)
+	if string(first) != string(second) {
+		t.Error("Expected identical output across saves")
+	}
+	if !strings.HasPrefix(string(first), "[profile audit]\n") {
+		t.Errorf("Expected profiles in sorted order, got:\n%s", first)
+	}
+}
+
+func TestValidateRoleSessionName(t *testing.T) {
+	for _, name := range []string{"ab", "jane.doe@aws-sso-util", "user_1+ci=x,y", strings.Repeat("a", 64)} {
+		if err := ValidateRoleSessionName(name); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "a", "jane doe", "CORP\\jane", strings.Repeat("a", 65)} {
+		if err := ValidateRoleSessionName(name); err == nil {
+			t.Errorf("Expected %q to be invalid", name)
+		}
+	}
+
+	input := GetAWSConfigInput{
+		StartURL:        "https://test.awsapps.com/start",
+		SSORegion:       "us-east-1",
+		AccountID:       "123456789012",
+		RoleName:        "Admin",
+		Region:          "us-east-1",
+		RoleSessionName: "not valid",
+	}
+	if err := ValidateGetAWSConfigInput(input); err == nil {
+		t.Error("Expected an invalid role session name to be rejected")
+	}
+
+	for _, tool := range []string{"", "my tool", strings.Repeat("x", 80)} {
+		if name := DefaultRoleSessionName(tool); ValidateRoleSessionName(name) != nil {
+			t.Errorf("Expected a valid default for tool %q, got %q", tool, name)
+		}
+	}
+}
+
+func TestResolveRegion(t *testing.T) {
+	sources := RegionSources{Flag: "eu-west-1", Profile: "eu-west-2", SSORegion: "eu-west-3"}
+	t.Setenv("AWS_REGION", "ap-south-1")
+	t.Setenv("AWS_DEFAULT_REGION", "ap-southeast-2")
+
+	steps := []struct {
+		expected string
+		drop     func()
+	}{
+		{"eu-west-1", func() { sources.Flag = "" }},
+		{"eu-west-2", func() { sources.Profile = "" }},
+		{"ap-south-1", func() { t.Setenv("AWS_REGION", "") }},
+		{"ap-southeast-2", func() { t.Setenv("AWS_DEFAULT_REGION", "") }},
+		{"eu-west-3", func() { sources.SSORegion = "" }},
+		{DefaultRegion, func() {}},
+	}
+	for _, step := range steps {
+		if region := ResolveRegion(sources); region != step.expected {
+			t.Errorf("Expected %s, got %s", step.expected, region)
+		}
+		step.drop()
+	}
+}
+
+func TestValidateClientName(t *testing.T) {
+	for _, name := range []string{"aws-sso-lib-go", "Acme Deploy Tool", strings.Repeat("a", 128)} {
+		if err := ValidateClientName(name); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "   ", "bad\nname", strings.Repeat("a", 129)} {
+		if err := ValidateClientName(name); err == nil {
+			t.Errorf("Expected %q to be invalid", name)
+		}
+	}
+
+	input := LoginInput{
+		StartURL:  "https://test.awsapps.com/start",
+		SSORegion: "us-east-1",
+		Config:    &Config{ClientName: strings.Repeat("a", 129)},
+	}
+	if err := ValidateLoginInput(input); err == nil {
+		t.Error("Expected an invalid client name in the config to be rejected")
+	}
+}
+
+func TestValidatePageSize(t *testing.T) {
+	for size, valid := range map[int]bool{1: true, 50: true, 100: true, 0: false, -1: false, 101: false} {
+		if err := ValidatePageSize(size); (err == nil) != valid {
+			t.Errorf("ValidatePageSize(%d) = %v", size, err)
+		}
+	}
+}
+
+func TestValidateProfileOutputFormat(t *testing.T) {
+	for _, format := range []string{"", "json", "yaml", "yaml-stream", "text", "table"} {
+		if err := ValidateProfile(&Profile{Name: "dev", OutputFormat: format}); err != nil {
+			t.Errorf("Expected output format %q to be valid, got %v", format, err)
+		}
+	}
+	for _, format := range []string{"JSON", "yml", "csv", "table "} {
+		err := ValidateProfile(&Profile{Name: "dev", OutputFormat: format})
+		if _, ok := err.(*InvalidConfigError); !ok {
+			t.Errorf("Expected an InvalidConfigError for output format %q, got %v", format, err)
+		}
+	}
+}
+
+func TestProfileValidateCredentialProcess(t *testing.T) {
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "aws-sso-util"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake binary: %v", err)
+	}
+	t.Setenv("PATH", binDir)
+
+	config := NewConfigFile()
+	config.SetProfile(&Profile{
+		Name:      "dev",
+		StartURL:  "https://test.awsapps.com/start",
+		SSORegion: "us-east-1",
+		AccountID: "123456789012",
+		RoleName:  "Admin",
+	})
+	config.SetProfile(&Profile{Name: "plain", Region: "us-east-1"})
+	config.SetSSOSession(&SSOSession{Name: "corp", StartURL: "https://corp.awsapps.com/start", SSORegion: "us-east-1"})
+	config.SetProfile(&Profile{Name: "corp-dev", SSOSession: "corp", AccountID: "123456789012", RoleName: "Admin"})
+
+	if err := config.GetProfile("corp-dev").Validate(config); err != nil {
+		t.Errorf("Expected an sso_session profile to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		credProcess string
+		wantErr     string
+	}{
+		{"valid", "aws-sso-util credential-process --profile dev", ""},
+		{"valid equals", "aws-sso-util credential-process --profile=dev", ""},
+		{"valid sso-session", "aws-sso-util credential-process --profile corp-dev", ""},
+		{"missing profile", "aws-sso-util credential-process --profile prod", "missing profile prod"},
+		{"no sso config", "aws-sso-util credential-process --profile plain", "without SSO configuration"},
+		{"no profile flag", "aws-sso-util credential-process", "does not specify --profile"},
+		{"missing binary", "not-a-real-binary --profile dev", "not found"},
+		{"blank", "   ", "credential_process is empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := &Profile{Name: "wrapper", CredProcess: tt.credProcess}
+			err := profile.Validate(config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFindInstanceProfilePrecedence(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config")
+	t.Setenv("AWS_DEFAULT_SSO_START_URL", "https://env.awsapps.com/start")
+	t.Setenv("AWS_DEFAULT_SSO_REGION", "eu-west-1")
+
+	config := NewConfigFile()
+	config.SetProfile(&Profile{
+		Name:      "prod",
+		StartURL:  "https://prod.awsapps.com/start",
+		SSORegion: "us-east-1",
+	})
+	config.SetProfile(&Profile{Name: "plain", Region: "us-east-1"})
+	if err := config.SaveConfigFileWithOptions(filename, SaveOptions{NoBackup: true}); err != nil {
+		t.Fatalf("SaveConfigFileWithOptions failed: %v", err)
+	}
+
+	instance, err := FindInstanceInConfigFile("prod", filename)
+	if err != nil {
+		t.Fatalf("FindInstanceInConfigFile failed: %v", err)
+	}
+	if instance.StartURL != "https://prod.awsapps.com/start" || instance.StartURLSource != "profile" {
+		t.Errorf("Expected the profile to take precedence over the environment, got %+v", instance)
+	}
+
+	instance, err = FindInstanceInConfigFile("", filename)
+	if err != nil {
+		t.Fatalf("FindInstanceInConfigFile failed: %v", err)
+	}
+	if instance.StartURLSource != "environment" {
+		t.Errorf("Expected the environment without a profile, got %+v", instance)
+	}
+
+	if _, err := FindInstanceInConfigFile("plain", filename); err == nil || !strings.Contains(err.Error(), "no SSO configuration") {
+		t.Errorf("Expected error for profile without SSO configuration, got %v", err)
+	}
+	if _, err := FindInstanceInConfigFile("missing", filename); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected error for missing profile, got %v", err)
+	}
+}
+
+func TestFindInstanceEnvironmentAliases(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config")
+	t.Setenv("AWS_DEFAULT_SSO_START_URL", "")
+	t.Setenv("AWS_DEFAULT_SSO_REGION", "")
+	t.Setenv("AWS_SSO_START_URL", "https://alias.awsapps.com/start")
+	t.Setenv("AWS_SSO_REGION", "eu-west-1")
+
+	instance, err := FindInstanceInConfigFile("", filename)
+	if err != nil {
+		t.Fatalf("FindInstanceInConfigFile failed: %v", err)
+	}
+	if instance.StartURL != "https://alias.awsapps.com/start" || instance.Region != "eu-west-1" ||
+		instance.StartURLSource != InstanceSourceEnvironmentAlias || instance.RegionSource != InstanceSourceEnvironmentAlias {
+		t.Errorf("Expected the aliases to be used, got %+v", instance)
+	}
+
+	// The AWS_DEFAULT_SSO_* variables take precedence over their aliases
+	t.Setenv("AWS_DEFAULT_SSO_START_URL", "https://env.awsapps.com/start")
+	instance, err = FindInstanceInConfigFile("", filename)
+	if err != nil {
+		t.Fatalf("FindInstanceInConfigFile failed: %v", err)
+	}
+	if instance.StartURL != "https://env.awsapps.com/start" || instance.StartURLSource != InstanceSourceEnvironment ||
+		instance.Region != "eu-west-1" || instance.RegionSource != InstanceSourceEnvironmentAlias {
+		t.Errorf("Expected the start URL from AWS_DEFAULT_SSO_START_URL and the region from its alias, got %+v", instance)
+	}
+}
+
+func TestConfigFileSSOSession(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config")
+	content := `[profile dev]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Developer
+region = eu-west-1
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = us-east-1
+sso_registration_scopes = sso:account:access
+
+[services local]
+region = us-west-2
+
+[profile broken]
+sso_session = missing
+`
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfigFile(filename)
+	if err != nil {
+		t.Fatalf("LoadConfigFile failed: %v", err)
+	}
+
+	session := config.GetSSOSession("corp")
+	if session == nil || session.StartURL != "https://corp.awsapps.com/start" || session.RegistrationScopes != "sso:account:access" {
+		t.Fatalf("Unexpected sso-session: %+v", session)
+	}
+
+	profile, err := config.ResolveProfile("dev")
+	if err != nil {
+		t.Fatalf("ResolveProfile failed: %v", err)
+	}
+	if profile.StartURL != "https://corp.awsapps.com/start" || profile.SSORegion != "us-east-1" {
+		t.Errorf("Expected the sso-session settings, got %+v", profile)
+	}
+	if profile.Region != "eu-west-1" {
+		t.Errorf("Expected the [services] section not to override the profile region, got %s", profile.Region)
+	}
+	if config.GetProfile("dev").StartURL != "" {
+		t.Error("Expected ResolveProfile not to modify the stored profile")
+	}
+
+	if _, err := config.ResolveProfile("broken"); err == nil || !strings.Contains(err.Error(), "missing sso-session") {
+		t.Errorf("Expected error for a missing sso-session, got %v", err)
+	}
+
+	ssoProfiles := config.GetSSOProfiles()
+	if len(ssoProfiles) != 1 || ssoProfiles[0].Name != "dev" || ssoProfiles[0].StartURL != "https://corp.awsapps.com/start" {
+		t.Errorf("Expected the resolved dev profile among the SSO profiles, got %+v", ssoProfiles)
+	}
+
+	instance, err := FindInstanceInConfigFile("dev", filename)
+	if err != nil {
+		t.Fatalf("FindInstanceInConfigFile failed: %v", err)
+	}
+	if instance.StartURL != "https://corp.awsapps.com/start" {
+		t.Errorf("Expected the profile's sso-session start URL, got %s", instance.StartURL)
+	}
+
+	// Saving keeps the sso-session section and reference
+	if err := config.SaveConfigFileWithOptions(filename, SaveOptions{NoBackup: true}); err != nil {
+		t.Fatalf("SaveConfigFileWithOptions failed: %v", err)
+	}
+	reloaded, err := LoadConfigFile(filename)
+	if err != nil {
+		t.Fatalf("LoadConfigFile failed: %v", err)
+	}
+	if !reflect.DeepEqual(reloaded.GetSSOSession("corp"), session) {
+		t.Errorf("Expected sso-session to round-trip, got %+v", reloaded.GetSSOSession("corp"))
+	}
+	if reloaded.GetProfile("dev").SSOSession != "corp" {
+		t.Errorf("Expected sso_session reference to round-trip, got %+v", reloaded.GetProfile("dev"))
+	}
+}
+
+func TestDefaultPathsFollowHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AWS_CONFIG_FILE", "")
+
+	if path := ResolveConfigFilePath(""); path != filepath.Join(home, ".aws", "config") {
+		t.Errorf("Expected the config file under the current home directory, got %s", path)
+	}
+	if path := DefaultAWSCredentialsFilePath(); path != filepath.Join(home, ".aws", "credentials") {
+		t.Errorf("Expected the credentials file under the current home directory, got %s", path)
+	}
+	if dir := CLICacheDir(); dir != filepath.Join(home, ".aws", "cli", "cache") {
+		t.Errorf("Expected the CLI cache under the current home directory, got %s", dir)
+	}
+}
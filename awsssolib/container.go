@@ -0,0 +1,91 @@
+package awsssolib
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// containerRefreshWindow is how long before their expiry served credentials
+// are replaced, so SDKs never receive credentials about to expire
+const containerRefreshWindow = 5 * time.Minute
+
+// containerCredentials is the response of the container credentials provider
+// that AWS SDKs read from AWS_CONTAINER_CREDENTIALS_FULL_URI
+type containerCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration,omitempty"`
+}
+
+// containerError is the error response of the container credentials provider
+type containerError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewContainerCredentialsHandler returns an HTTP handler serving the
+// credentials of provider in the format of the ECS container credentials
+// provider, so any SDK pointed at it with AWS_CONTAINER_CREDENTIALS_FULL_URI
+// and AWS_CONTAINER_AUTHORIZATION_TOKEN gets SSO credentials without a
+// profile.
+//
+// Credentials are served at /credentials to requests whose Authorization
+// header is authToken. /health answers without a token and reveals nothing.
+// provider should cache, like the Credentials of GetAWSConfig; when it has an
+// Invalidate method, credentials expiring within five minutes are replaced
+// before they are served.
+func NewContainerCredentialsHandler(provider aws.CredentialsProvider, authToken string) (http.Handler, error) {
+	if authToken == "" {
+		return nil, &InvalidConfigError{Message: "authorization token cannot be empty"}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeContainerJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/credentials", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeContainerJSON(w, http.StatusMethodNotAllowed, containerError{Code: "MethodNotAllowed", Message: "only GET is supported"})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(authToken)) != 1 {
+			writeContainerJSON(w, http.StatusUnauthorized, containerError{Code: "Unauthorized", Message: "missing or invalid authorization token"})
+			return
+		}
+
+		creds, err := provider.Retrieve(r.Context())
+		if err == nil && creds.CanExpire && time.Until(creds.Expires) < containerRefreshWindow {
+			if cache, ok := provider.(interface{ Invalidate() }); ok {
+				cache.Invalidate()
+				creds, err = provider.Retrieve(r.Context())
+			}
+		}
+		if err != nil {
+			writeContainerJSON(w, http.StatusInternalServerError, containerError{Code: "CredentialsUnavailable", Message: err.Error()})
+			return
+		}
+
+		response := containerCredentials{
+			AccessKeyID:     creds.AccessKeyID,
+			SecretAccessKey: creds.SecretAccessKey,
+			Token:           creds.SessionToken,
+		}
+		if creds.CanExpire {
+			response.Expiration = creds.Expires.UTC().Format(time.RFC3339)
+		}
+		writeContainerJSON(w, http.StatusOK, response)
+	})
+	return mux, nil
+}
+
+// writeContainerJSON writes value as a JSON response with status
+func writeContainerJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
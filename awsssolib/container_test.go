@@ -0,0 +1,109 @@
+package awsssolib
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/endpointcreds"
+)
+
+func TestContainerCredentialsHandler(t *testing.T) {
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	provider := aws.NewCredentialsCache(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{
+			AccessKeyID:     "AKIA",
+			SecretAccessKey: "secret",
+			SessionToken:    "session",
+			CanExpire:       true,
+			Expires:         expires,
+		}, nil
+	}))
+
+	if _, err := NewContainerCredentialsHandler(provider, ""); err == nil {
+		t.Error("Expected an error without an authorization token")
+	}
+	handler, err := NewContainerCredentialsHandler(provider, "secret-token")
+	if err != nil {
+		t.Fatalf("NewContainerCredentialsHandler failed: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// The SDK container credentials provider reads the credentials
+	sdkProvider := endpointcreds.New(server.URL+"/credentials", func(o *endpointcreds.Options) {
+		o.AuthorizationToken = "secret-token"
+	})
+	creds, err := sdkProvider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+	if creds.AccessKeyID != "AKIA" || creds.SessionToken != "session" || !creds.Expires.Equal(expires) {
+		t.Errorf("Expected the served credentials, got %+v", creds)
+	}
+
+	// Requests without the token get nothing
+	for _, token := range []string{"", "wrong"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/credentials", nil)
+		req.Header.Set("Authorization", token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected token %q to be rejected, got %d", token, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the health check to pass, got %d", resp.StatusCode)
+	}
+}
+
+func TestContainerCredentialsHandlerRefresh(t *testing.T) {
+	calls := 0
+	provider := aws.NewCredentialsCache(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		calls++
+		switch calls {
+		case 1:
+			// Credentials about to expire are replaced before they are served
+			return aws.Credentials{AccessKeyID: "OLD", CanExpire: true, Expires: time.Now().Add(time.Minute)}, nil
+		case 2:
+			return aws.Credentials{AccessKeyID: "NEW", CanExpire: true, Expires: time.Now().Add(time.Hour)}, nil
+		default:
+			return aws.Credentials{}, errors.New("session expired")
+		}
+	}))
+	handler, err := NewContainerCredentialsHandler(provider, "token")
+	if err != nil {
+		t.Fatalf("NewContainerCredentialsHandler failed: %v", err)
+	}
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/credentials", nil)
+		req.Header.Set("Authorization", "token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"AccessKeyId":"NEW"`) {
+		t.Errorf("Expected refreshed credentials, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	provider.Invalidate()
+	if rec := get(); rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "session expired") {
+		t.Errorf("Expected an error response, got %d %s", rec.Code, rec.Body.String())
+	}
+}
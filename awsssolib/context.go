@@ -0,0 +1,35 @@
+package awsssolib
+
+import "context"
+
+// instanceContextKey is the context key of the SSO instance
+type instanceContextKey struct{}
+
+// ContextWithInstance returns a copy of ctx carrying the SSO instance. Library
+// functions use its start URL and region when their input leaves them empty.
+func ContextWithInstance(ctx context.Context, instance SSOInstance) context.Context {
+	return context.WithValue(ctx, instanceContextKey{}, instance)
+}
+
+// InstanceFromContext returns the SSO instance carried by ctx, if any
+func InstanceFromContext(ctx context.Context) (SSOInstance, bool) {
+	instance, ok := ctx.Value(instanceContextKey{}).(SSOInstance)
+	return instance, ok
+}
+
+// contextInstance fills in an empty start URL and SSO region from the instance
+// carried by ctx. Explicit values take precedence, and the region is only
+// taken for the start URL of the instance.
+func contextInstance(ctx context.Context, startURL, ssoRegion string) (string, string) {
+	instance, ok := InstanceFromContext(ctx)
+	if !ok {
+		return startURL, ssoRegion
+	}
+	if startURL == "" {
+		startURL = instance.StartURL
+	}
+	if ssoRegion == "" && startURL == instance.StartURL {
+		ssoRegion = instance.Region
+	}
+	return startURL, ssoRegion
+}
@@ -0,0 +1,57 @@
+package awsssolib
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+func TestContextInstance(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := InstanceFromContext(ctx); ok {
+		t.Error("Expected no instance in an empty context")
+	}
+
+	instance := SSOInstance{StartURL: "https://corp.awsapps.com/start", Region: "eu-west-1"}
+	ctx = ContextWithInstance(ctx, instance)
+	if got, ok := InstanceFromContext(ctx); !ok || got != instance {
+		t.Errorf("Expected the instance back, got %+v", got)
+	}
+
+	tests := []struct {
+		name                string
+		startURL, ssoRegion string
+		wantURL, wantRegion string
+	}{
+		{"empty input", "", "", instance.StartURL, instance.Region},
+		{"explicit region", "", "us-east-1", instance.StartURL, "us-east-1"},
+		{"same start URL", instance.StartURL, "", instance.StartURL, instance.Region},
+		{"other start URL", "https://other.awsapps.com/start", "", "https://other.awsapps.com/start", ""},
+		{"explicit values", "https://other.awsapps.com/start", "us-east-1", "https://other.awsapps.com/start", "us-east-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startURL, ssoRegion := contextInstance(ctx, tt.startURL, tt.ssoRegion)
+			if startURL != tt.wantURL || ssoRegion != tt.wantRegion {
+				t.Errorf("Expected %s in %s, got %s in %s", tt.wantURL, tt.wantRegion, startURL, ssoRegion)
+			}
+		})
+	}
+}
+
+func TestListAvailableAccountsFromContext(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	t.Setenv("AWS_ENDPOINT_URL_SSO", newPortalServer(t, map[string][]string{
+		"111111111111": {"Developer"},
+	}, &calls).URL)
+
+	ctx := ContextWithInstance(context.Background(), SSOInstance{StartURL: startURL, Region: "us-east-1"})
+	accounts, err := ListAvailableAccounts(ctx, ListAccountsInput{})
+	if err != nil {
+		t.Fatalf("ListAvailableAccounts failed: %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].AccountID != "111111111111" {
+		t.Errorf("Expected the account of the context instance, got %+v", accounts)
+	}
+}
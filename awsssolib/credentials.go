@@ -0,0 +1,275 @@
+package awsssolib
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+)
+
+// DefaultMaxConcurrency bounds the number of concurrent SSO API calls, low
+// enough to avoid throttling of the SSO API
+const DefaultMaxConcurrency = 4
+
+// CredentialsKey returns the key of the credentials of an account and role of
+// the SSO instance at startURL in the result of GetMultipleCredentials: the
+// CredentialCacheKey of the start URL, formatted account ID and role. The
+// start URL is part of the key, so the same account and role reached through
+// two SSO instances are kept apart.
+func CredentialsKey(startURL, accountID, roleName string) string {
+	return CredentialCacheKey(startURL, formatAccountID(accountID), roleName)
+}
+
+// GetMultipleCredentials retrieves role credentials for several account/role
+// combinations concurrently, reusing one SSO token per start URL.
+//
+// Results are keyed by CredentialsKey of the start URL, account and role.
+// Identical requests for the same start URL are fetched only once. The
+// returned errors are indexed like inputs, with nil entries for the inputs
+// that succeeded, so a failure for one input doesn't abort the rest of the
+// batch. The Region field of the inputs is not used.
+func GetMultipleCredentials(ctx context.Context, inputs []GetAWSConfigInput) (map[string]*CachedCredentials, []error) {
+	errs := make([]error, len(inputs))
+	results := make(map[string]*CachedCredentials)
+
+	if len(inputs) == 0 {
+		return results, errs
+	}
+
+	logger := getLogger(inputs[0].Config)
+
+	// Group inputs by start URL, account and role so identical requests are
+	// fetched once
+	type job struct {
+		input   GetAWSConfigInput
+		indexes []int
+	}
+	jobs := make(map[string]*job)
+	var order []string
+
+	for i, input := range inputs {
+		input.StartURL, input.SSORegion = contextInstance(ctx, input.StartURL, input.SSORegion)
+		if err := validateCredentialsInput(input); err != nil {
+			errs[i] = err
+			continue
+		}
+
+		key := CredentialsKey(input.StartURL, input.AccountID, input.RoleName)
+		if j, ok := jobs[key]; ok {
+			j.indexes = append(j.indexes, i)
+			if input.Login {
+				j.input.Login = true
+			}
+			continue
+		}
+		jobs[key] = &job{input: input, indexes: []int{i}}
+		order = append(order, key)
+	}
+
+	// Resolve one token per start URL. This happens sequentially because it may
+	// require an interactive login.
+	tokens := make(map[string]*Token)
+	tokenErrs := make(map[string]error)
+	for _, key := range order {
+		input := jobs[key].input
+		if _, ok := tokens[input.StartURL]; ok {
+			continue
+		}
+		if _, ok := tokenErrs[input.StartURL]; ok {
+			continue
+		}
+
+		token, err := getTokenForOperation(ctx, input.StartURL, input.SSORegion, input.Login, false, input.SSOCache, input.Config)
+		if err != nil {
+			tokenErrs[input.StartURL] = err
+			continue
+		}
+		tokens[input.StartURL] = token
+	}
+
+	// Fetch credentials concurrently with bounded parallelism
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, DefaultMaxConcurrency)
+	clients := newSSOClientPool()
+
+	for _, key := range order {
+		j := jobs[key]
+
+		if err, ok := tokenErrs[j.input.StartURL]; ok {
+			for _, i := range j.indexes {
+				errs[i] = err
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(key string, j *job) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			creds, err := fetchCredentials(ctx, clients, tokens[j.input.StartURL], j.input)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logger.Warn("Failed to retrieve credentials",
+					slog.String("account_id", j.input.AccountID),
+					slog.String("role_name", j.input.RoleName),
+					slog.Any("error", err))
+				for _, i := range j.indexes {
+					errs[i] = err
+				}
+				return
+			}
+			results[key] = creds
+		}(key, j)
+	}
+
+	wg.Wait()
+	return results, errs
+}
+
+// fetchCredentials returns credentials for one input, using its credential cache if set
+func fetchCredentials(ctx context.Context, clients *ssoClientPool, token *Token, input GetAWSConfigInput) (*CachedCredentials, error) {
+	accountID := formatAccountID(input.AccountID)
+	cacheKey := CredentialCacheKey(input.StartURL, accountID, input.RoleName)
+
+	cache := input.credentialCache()
+	if cache != nil {
+		if cached, err := getCachedCredentials(cache, cacheKey, input.MinValidity); err == nil && cached != nil {
+			getMetrics(input.Config).OnCredentialCacheHit(accountID, input.RoleName)
+			return cached, nil
+		}
+		getMetrics(input.Config).OnCredentialCacheMiss(accountID, input.RoleName)
+	}
+
+	client, err := clients.get(ctx, input.SSORegion, input.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	creds, err := getRoleCredentials(ctx, client, token.AccessToken, accountID, input.RoleName, input.Config)
+	if err != nil {
+		recordAPIError(input.Config, "GetRoleCredentials", err)
+		return nil, fmt.Errorf("failed to get role credentials for %s/%s: %w", accountID, input.RoleName, err)
+	}
+
+	if cache != nil {
+		if err := PutCachedCredentials(cache, cacheKey, creds); err != nil {
+			getLogger(input.Config).Warn("Failed to cache credentials", slog.Any("error", err))
+		}
+	}
+
+	return creds, nil
+}
+
+// validateCredentialsInput validates the fields of GetAWSConfigInput needed to fetch credentials
+func validateCredentialsInput(input GetAWSConfigInput) error {
+	if err := ValidateStartURL(input.StartURL); err != nil {
+		return err
+	}
+	if err := ValidateRegion(input.SSORegion); err != nil {
+		return err
+	}
+	if err := ValidateAccountID(input.AccountID); err != nil {
+		return err
+	}
+	return ValidateRoleName(input.RoleName)
+}
+
+// ssoClientPool shares SSO clients per region between goroutines
+type ssoClientPool struct {
+	mu      sync.Mutex
+	clients map[string]*sso.Client
+}
+
+// newSSOClientPool creates an empty SSO client pool
+func newSSOClientPool() *ssoClientPool {
+	return &ssoClientPool{
+		clients: make(map[string]*sso.Client),
+	}
+}
+
+// get returns the SSO client for a region, creating it if needed
+func (p *ssoClientPool) get(ctx context.Context, region string, cfg *Config) (*sso.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client, ok := p.clients[region]; ok {
+		return client, nil
+	}
+
+	sdkConfig, err := loadSDKConfig(ctx, region, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client := sso.NewFromConfig(sdkConfig)
+	p.clients[region] = client
+	return client, nil
+}
+
+// credentialFlights deduplicates concurrent credential fetches across
+// providers. Keys include the token cache directory, so providers reading
+// different token caches never share a fetch.
+var credentialFlights = &flightGroup{}
+
+// flightTimeout bounds a shared fetch, which no single caller can cancel
+const flightTimeout = 30 * time.Second
+
+// flightGroup runs one call per key at a time and shares its result with the
+// callers that arrive while it is running
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is a running or completed call of a flightGroup
+type flightCall struct {
+	done  chan struct{}
+	creds *CachedCredentials
+	err   error
+}
+
+// do calls fn, unless a call for key is already running, and waits for the
+// result of the call until ctx is done. The call runs with the values but not
+// the cancellation of the ctx that started it, bounded by flightTimeout, so
+// one caller giving up doesn't fail the others.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) (*CachedCredentials, error)) (*CachedCredentials, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go g.run(ctx, key, call, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.creds, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run makes the call of key and shares its result
+func (g *flightGroup) run(ctx context.Context, key string, call *flightCall, fn func(context.Context) (*CachedCredentials, error)) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flightTimeout)
+	defer cancel()
+
+	call.creds, call.err = fn(ctx)
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+}
@@ -0,0 +1,338 @@
+package awsssolib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ResolveCredentialsFilePath returns filename if set, otherwise the AWS
+// credentials file named by AWS_SHARED_CREDENTIALS_FILE, falling back to
+// DefaultAWSCredentialsFilePath
+func ResolveCredentialsFilePath(filename string) string {
+	if filename != "" {
+		return filename
+	}
+	if envFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); envFile != "" {
+		return envFile
+	}
+	return DefaultAWSCredentialsFilePath()
+}
+
+// CredentialsProfile represents a [name] section of the AWS credentials file
+type CredentialsProfile struct {
+	Name            string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// SessionExpiration is when temporary credentials expire, written as
+	// aws_session_expiration. It is zero for long-term keys.
+	SessionExpiration time.Time
+	// Extra holds the keys the library doesn't know, such as region, so
+	// they survive a load and save. Nested values keep their indented lines.
+	Extra map[string]string
+}
+
+// CredentialsFile represents the AWS credentials file
+type CredentialsFile struct {
+	profiles map[string]*CredentialsProfile
+}
+
+// NewCredentialsFile creates a new credentials file
+func NewCredentialsFile() *CredentialsFile {
+	return &CredentialsFile{
+		profiles: make(map[string]*CredentialsProfile),
+	}
+}
+
+// LoadCredentialsFile loads the AWS credentials file. An empty filename is
+// resolved with ResolveCredentialsFilePath, and a missing file is empty.
+// Comments are not kept; WriteCredentials keeps them.
+func LoadCredentialsFile(filename string) (*CredentialsFile, error) {
+	filename = ResolveCredentialsFilePath(filename)
+
+	file, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewCredentialsFile(), nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	credentials := NewCredentialsFile()
+	scanner := bufio.NewScanner(file)
+
+	var current *CredentialsProfile
+	sectionRegex := regexp.MustCompile(`^\[\s*(.+?)\s*\]$`)
+	keyValueRegex := regexp.MustCompile(`^\s*(\w+)\s*=\s*(.*)$`)
+	var nestedKey string
+
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+
+		// Keep the indented lines of a nested value
+		if current != nil && nestedKey != "" && line != "" && raw != strings.TrimLeft(raw, " \t") {
+			current.Extra[nestedKey] += "\n" + strings.TrimRight(raw, " \t")
+			continue
+		}
+		nestedKey = ""
+
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if matches := sectionRegex.FindStringSubmatch(line); matches != nil {
+			current = &CredentialsProfile{Name: matches[1]}
+			credentials.profiles[current.Name] = current
+			continue
+		}
+
+		if current == nil || !keyValueRegex.MatchString(line) {
+			continue
+		}
+		matches := keyValueRegex.FindStringSubmatch(line)
+		key := matches[1]
+		value := strings.TrimSpace(matches[2])
+
+		switch key {
+		case "aws_access_key_id":
+			current.AccessKeyID = value
+		case "aws_secret_access_key":
+			current.SecretAccessKey = value
+		case "aws_session_token":
+			current.SessionToken = value
+		case "aws_session_expiration":
+			if expiration, err := time.Parse(time.RFC3339, value); err == nil {
+				current.SessionExpiration = expiration
+				break
+			}
+			// Keep values in other formats as they are
+			fallthrough
+		default:
+			if current.Extra == nil {
+				current.Extra = make(map[string]string)
+			}
+			current.Extra[key] = value
+			if value == "" {
+				nestedKey = key
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// SaveCredentialsFile saves the credentials to file, readable only by the
+// owner. An empty filename is resolved with ResolveCredentialsFilePath.
+// Profiles are written in sorted order.
+func (c *CredentialsFile) SaveCredentialsFile(filename string) error {
+	filename = ResolveCredentialsFilePath(filename)
+
+	var buf bytes.Buffer
+	for i, name := range c.ListProfiles() {
+		profile := c.profiles[name]
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "[%s]\n", name)
+
+		if profile.AccessKeyID != "" {
+			fmt.Fprintf(&buf, "aws_access_key_id = %s\n", profile.AccessKeyID)
+		}
+		if profile.SecretAccessKey != "" {
+			fmt.Fprintf(&buf, "aws_secret_access_key = %s\n", profile.SecretAccessKey)
+		}
+		if profile.SessionToken != "" {
+			fmt.Fprintf(&buf, "aws_session_token = %s\n", profile.SessionToken)
+		}
+		if !profile.SessionExpiration.IsZero() {
+			fmt.Fprintf(&buf, "aws_session_expiration = %s\n", profile.SessionExpiration.UTC().Format(time.RFC3339))
+		}
+		for _, key := range sortedKeys(profile.Extra) {
+			value := profile.Extra[key]
+			// Empty and nested values have nothing after the equals sign
+			if value == "" || strings.HasPrefix(value, "\n") {
+				fmt.Fprintf(&buf, "%s =%s\n", key, value)
+			} else {
+				fmt.Fprintf(&buf, "%s = %s\n", key, value)
+			}
+		}
+	}
+
+	return writeFileAtomic(filename, buf.Bytes(), 0600)
+}
+
+// GetProfile returns a profile by name
+func (c *CredentialsFile) GetProfile(name string) *CredentialsProfile {
+	return c.profiles[name]
+}
+
+// SetProfile adds or updates a profile
+func (c *CredentialsFile) SetProfile(profile *CredentialsProfile) {
+	c.profiles[profile.Name] = profile
+}
+
+// RemoveProfile removes a profile
+func (c *CredentialsFile) RemoveProfile(name string) {
+	delete(c.profiles, name)
+}
+
+// ListProfiles returns all profile names, sorted
+func (c *CredentialsFile) ListProfiles() []string {
+	names := make([]string, 0, len(c.profiles))
+	for name := range c.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteCredentials writes static credentials to the [profileName] section of
+// the AWS credentials file, for tools that can't use SSO. The keys of the
+// section are replaced and marked with a comment as temporary, with their
+// aws_session_expiration; other settings of the section, such as region, and
+// the rest of the file are kept as is. The credentials stop working when they
+// expire, so they must be written again.
+func WriteCredentials(filename, profileName string, creds aws.Credentials) error {
+	filename = ResolveCredentialsFilePath(filename)
+
+	data, err := os.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var section bytes.Buffer
+	section.WriteString(fmt.Sprintf("[%s]\n", profileName))
+	if creds.CanExpire {
+		section.WriteString(fmt.Sprintf("%s, expiring at %s\n", temporaryCredentialsMarker, creds.Expires.UTC().Format(time.RFC3339)))
+	} else {
+		section.WriteString(credentialsMarker + "\n")
+	}
+	section.WriteString(fmt.Sprintf("aws_access_key_id = %s\n", creds.AccessKeyID))
+	section.WriteString(fmt.Sprintf("aws_secret_access_key = %s\n", creds.SecretAccessKey))
+	if creds.SessionToken != "" {
+		section.WriteString(fmt.Sprintf("aws_session_token = %s\n", creds.SessionToken))
+	}
+	if creds.CanExpire {
+		section.WriteString(fmt.Sprintf("aws_session_expiration = %s\n", creds.Expires.UTC().Format(time.RFC3339)))
+	}
+
+	// Copy the file, putting the new section in place of the old one and
+	// keeping the settings of the old section that aren't credentials
+	var out bytes.Buffer
+	var kept []string
+	written := false
+	inSection := false
+	endSection := func(last bool) {
+		out.Write(section.Bytes())
+		for len(kept) > 0 && strings.TrimSpace(kept[len(kept)-1]) == "" {
+			kept = kept[:len(kept)-1]
+		}
+		for _, line := range kept {
+			out.WriteString(line + "\n")
+		}
+		if !last {
+			out.WriteString("\n")
+		}
+		written = true
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			if inSection && !written {
+				endSection(false)
+			}
+			inSection = strings.TrimSpace(trimmed[1:len(trimmed)-1]) == profileName
+		}
+		if inSection {
+			// A repeated section is dropped
+			if !written && !isWrittenCredentialsLine(trimmed) && !strings.HasPrefix(trimmed, "[") {
+				kept = append(kept, line)
+			}
+			continue
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if inSection && !written {
+		endSection(true)
+	}
+	if !written {
+		if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n\n")) {
+			out.WriteString("\n")
+		}
+		out.Write(section.Bytes())
+	}
+
+	return writeFileAtomic(filename, out.Bytes(), 0600)
+}
+
+// Comments marking the sections written by WriteCredentials
+const (
+	temporaryCredentialsMarker = "# Temporary SSO credentials written by aws-sso-util"
+	credentialsMarker          = "# SSO credentials written by aws-sso-util"
+)
+
+// isWrittenCredentialsLine reports whether a trimmed line of a credentials
+// section is replaced by WriteCredentials: a credentials key or a marker
+func isWrittenCredentialsLine(trimmed string) bool {
+	if strings.HasPrefix(trimmed, temporaryCredentialsMarker) || trimmed == credentialsMarker {
+		return true
+	}
+	key, _, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return false
+	}
+	switch strings.TrimSpace(key) {
+	case "aws_access_key_id", "aws_secret_access_key", "aws_session_token", "aws_session_expiration":
+		return true
+	}
+	return false
+}
+
+// writeFileAtomic replaces filename with data through a temporary file in the
+// same directory, so readers never see a partial file
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Chmod(perm); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), filename)
+}
@@ -0,0 +1,149 @@
+package awsssolib
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestWriteCredentials(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "credentials")
+	existing := `# Keys managed by hand
+[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+
+[legacy]
+aws_access_key_id = AKIDOLD
+aws_secret_access_key = old-secret
+region = us-west-2
+
+[other]
+region = eu-west-1
+`
+	if err := os.WriteFile(filename, []byte(existing), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	creds := aws.Credentials{
+		AccessKeyID:     "AKIDNEW",
+		SecretAccessKey: "new-secret",
+		SessionToken:    "new-token",
+		CanExpire:       true,
+		Expires:         expires,
+	}
+	if err := WriteCredentials(filename, "legacy", creds); err != nil {
+		t.Fatalf("WriteCredentials failed: %v", err)
+	}
+	if err := WriteCredentials(filename, "added", creds); err != nil {
+		t.Fatalf("WriteCredentials failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Keys managed by hand
+[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+
+[legacy]
+# Temporary SSO credentials written by aws-sso-util, expiring at 2030-01-02T03:04:05Z
+aws_access_key_id = AKIDNEW
+aws_secret_access_key = new-secret
+aws_session_token = new-token
+aws_session_expiration = 2030-01-02T03:04:05Z
+region = us-west-2
+
+[other]
+region = eu-west-1
+
+[added]
+# Temporary SSO credentials written by aws-sso-util, expiring at 2030-01-02T03:04:05Z
+aws_access_key_id = AKIDNEW
+aws_secret_access_key = new-secret
+aws_session_token = new-token
+aws_session_expiration = 2030-01-02T03:04:05Z
+`
+	if got := string(data); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+	if strings.Contains(string(data), "AKIDOLD") {
+		t.Error("Expected the old credentials to be replaced")
+	}
+
+	// Writing again replaces the marker rather than adding one
+	if err := WriteCredentials(filename, "legacy", creds); err != nil {
+		t.Fatalf("WriteCredentials failed: %v", err)
+	}
+	if again, _ := os.ReadFile(filename); string(again) != want {
+		t.Errorf("Expected writing again to give the same file, got:\n%s", again)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm != 0600 {
+		t.Errorf("Expected permissions 0600, got %#o", perm)
+	}
+}
+
+func TestCredentialsFileRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "credentials")
+	content := `[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+aws_session_token = token
+aws_session_expiration = 2030-01-02T03:04:05Z
+region = eu-west-1
+s3 =
+  max_concurrent_requests = 20
+
+[odd]
+aws_access_key_id = AKIDODD
+aws_secret_access_key = odd-secret
+aws_session_expiration = tomorrow
+`
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, err := LoadCredentialsFile(filename)
+	if err != nil {
+		t.Fatalf("LoadCredentialsFile failed: %v", err)
+	}
+	profile := credentials.GetProfile("default")
+	if profile == nil || profile.AccessKeyID != "AKIDDEFAULT" || profile.SecretAccessKey != "default-secret" || profile.SessionToken != "token" {
+		t.Fatalf("Expected the default keys, got %+v", profile)
+	}
+	if want := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC); !profile.SessionExpiration.Equal(want) {
+		t.Errorf("Expected expiration %s, got %s", want, profile.SessionExpiration)
+	}
+	if odd := credentials.GetProfile("odd"); odd.Extra["aws_session_expiration"] != "tomorrow" {
+		t.Errorf("Expected an unparsable expiration to be kept as is, got %+v", odd)
+	}
+
+	if err := credentials.SaveCredentialsFile(filename); err != nil {
+		t.Fatalf("SaveCredentialsFile failed: %v", err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != content {
+		t.Errorf("Expected the file to round-trip, got:\n%s", got)
+	}
+
+	credentials.RemoveProfile("odd")
+	if names := credentials.ListProfiles(); len(names) != 1 || names[0] != "default" {
+		t.Errorf("Expected only the default profile, got %v", names)
+	}
+}
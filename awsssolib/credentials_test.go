@@ -0,0 +1,310 @@
+package awsssolib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetMultipleCredentialsPerInputErrors(t *testing.T) {
+	// Use an empty home directory so no cached token is found
+	t.Setenv("HOME", t.TempDir())
+
+	valid := GetAWSConfigInput{
+		StartURL:  "https://test.awsapps.com/start",
+		SSORegion: "us-east-1",
+		AccountID: "123456789012",
+		RoleName:  "TestRole",
+	}
+	invalid := valid
+	invalid.AccountID = "not-an-account"
+
+	results, errs := GetMultipleCredentials(context.Background(), []GetAWSConfigInput{valid, invalid, valid})
+
+	if len(errs) != 3 {
+		t.Fatalf("Expected 3 errors, got %d", len(errs))
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results, got %d", len(results))
+	}
+
+	var configErr *InvalidConfigError
+	if !errors.As(errs[1], &configErr) {
+		t.Errorf("Expected invalid config error for input 1, got %v", errs[1])
+	}
+
+	var authErr *AuthenticationNeededError
+	for _, i := range []int{0, 2} {
+		if !errors.As(errs[i], &authErr) {
+			t.Errorf("Expected authentication needed error for input %d, got %v", i, errs[i])
+		}
+	}
+}
+
+func TestCredentialsKey(t *testing.T) {
+	startURL := "https://test.awsapps.com/start"
+	if key := CredentialsKey(startURL, "1234-5678-9012", "Admin"); key != CredentialCacheKey(startURL, "123456789012", "Admin") {
+		t.Errorf("Expected the credential cache key of the formatted account, got %s", key)
+	}
+	if CredentialsKey("https://other.awsapps.com/start", "123456789012", "Admin") == CredentialsKey(startURL, "123456789012", "Admin") {
+		t.Error("Expected the start URL to be part of the key")
+	}
+}
+
+func TestGetMultipleCredentialsPerStartURL(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	otherURL := "https://other.awsapps.com/start"
+
+	input := GetAWSConfigInput{
+		StartURL:  startURL,
+		SSORegion: "us-east-1",
+		AccountID: "111111111111",
+		RoleName:  "Developer",
+	}
+	other := input
+	other.StartURL = otherURL
+
+	// The same account and role of another instance isn't merged into the first
+	results, errs := GetMultipleCredentials(context.Background(), []GetAWSConfigInput{input, other})
+	if errs[0] != nil {
+		t.Fatalf("GetMultipleCredentials failed: %v", errs[0])
+	}
+	var authErr *AuthenticationNeededError
+	if !errors.As(errs[1], &authErr) {
+		t.Errorf("Expected authentication needed error for the other start URL, got %v", errs[1])
+	}
+	if results[CredentialsKey(startURL, "111111111111", "Developer")] == nil {
+		t.Errorf("Expected credentials keyed by the start URL, got %v", results)
+	}
+	if _, ok := results[CredentialsKey(otherURL, "111111111111", "Developer")]; ok {
+		t.Error("Expected no credentials for the other start URL")
+	}
+}
+
+// countingCache is a memory cache that counts Get and Put calls
+type countingCache struct {
+	*MemoryCache
+	gets, puts atomic.Int32
+}
+
+func (c *countingCache) Get(key string) ([]byte, error) {
+	c.gets.Add(1)
+	return c.MemoryCache.Get(key)
+}
+
+func (c *countingCache) Put(key string, data []byte) error {
+	c.puts.Add(1)
+	return c.MemoryCache.Put(key, data)
+}
+
+func TestDisableCredentialCache(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	ctx := context.Background()
+	cache := &countingCache{MemoryCache: NewMemoryCache()}
+
+	input := GetAWSConfigInput{
+		StartURL:               startURL,
+		SSORegion:              "us-east-1",
+		AccountID:              "111111111111",
+		RoleName:               "Developer",
+		Region:                 "us-east-1",
+		CredentialCache:        cache,
+		DisableCredentialCache: true,
+	}
+
+	cfg, err := GetAWSConfig(ctx, input)
+	if err != nil {
+		t.Fatalf("GetAWSConfig failed: %v", err)
+	}
+	if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+
+	_, errs := GetMultipleCredentials(ctx, []GetAWSConfigInput{input})
+	if errs[0] != nil {
+		t.Fatalf("GetMultipleCredentials failed: %v", errs[0])
+	}
+
+	if cache.gets.Load() != 0 || cache.puts.Load() != 0 {
+		t.Errorf("Expected the cache to be unused, got %d gets and %d puts", cache.gets.Load(), cache.puts.Load())
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 GetRoleCredentials calls, got %d", calls.Load())
+	}
+}
+
+func TestMinValidity(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	ctx := context.Background()
+	cache := NewMemoryCache()
+
+	cacheKey := CredentialCacheKey(startURL, "111111111111", "Developer")
+	expiring := &CachedCredentials{AccessKeyID: "CACHED", Expiration: time.Now().Add(10 * time.Minute)}
+	if err := PutCachedCredentials(cache, cacheKey, expiring); err != nil {
+		t.Fatal(err)
+	}
+
+	input := GetAWSConfigInput{
+		StartURL:        startURL,
+		SSORegion:       "us-east-1",
+		AccountID:       "111111111111",
+		RoleName:        "Developer",
+		Region:          "us-east-1",
+		CredentialCache: cache,
+	}
+	retrieve := func(input GetAWSConfigInput) string {
+		t.Helper()
+		cfg, err := GetAWSConfig(ctx, input)
+		if err != nil {
+			t.Fatalf("GetAWSConfig failed: %v", err)
+		}
+		creds, err := cfg.Credentials.Retrieve(ctx)
+		if err != nil {
+			t.Fatalf("Retrieve failed: %v", err)
+		}
+		return creds.AccessKeyID
+	}
+
+	if key := retrieve(input); key != "CACHED" || calls.Load() != 0 {
+		t.Errorf("Expected the cached credentials without MinValidity, got %s after %d calls", key, calls.Load())
+	}
+
+	input.MinValidity = 15 * time.Minute
+	if key := retrieve(input); key == "CACHED" || calls.Load() != 1 {
+		t.Errorf("Expected fresh credentials within MinValidity, got %s after %d calls", key, calls.Load())
+	}
+	if cached, _ := GetCachedCredentials(cache, cacheKey); cached == nil || cached.AccessKeyID == "CACHED" {
+		t.Errorf("Expected the fresh credentials to replace the cached ones, got %+v", cached)
+	}
+}
+
+func TestConcurrentRetrieveSharesOneCall(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+
+	// Answer slowly so that all retrievals overlap
+	var slowCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slowCalls.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"roleCredentials":{"accessKeyId":"AKID","secretAccessKey":"secret","sessionToken":"session","expiration":%d}}`,
+			time.Now().Add(time.Hour).UnixMilli())
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL_SSO", server.URL)
+
+	ctx := context.Background()
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		provider := &ssoCredentialProvider{
+			startURL:  startURL,
+			ssoRegion: "us-east-1",
+			accountID: "111111111111",
+			roleName:  "Developer",
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = provider.Retrieve(ctx)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Retrieve %d failed: %v", i, err)
+		}
+	}
+	if slowCalls.Load() != 1 {
+		t.Errorf("Expected 1 GetRoleCredentials call, got %d", slowCalls.Load())
+	}
+}
+
+func TestFlightGroupCancellation(t *testing.T) {
+	var group flightGroup
+	release := make(chan struct{})
+	var calls atomic.Int32
+	fn := func(ctx context.Context) (*CachedCredentials, error) {
+		calls.Add(1)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return &CachedCredentials{AccessKeyID: "AKID"}, nil
+	}
+
+	// The caller starting the call gives up, the one waiting for it doesn't
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := group.do(leaderCtx, "key", fn)
+		leaderErr <- err
+	}()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	waiterResult := make(chan *CachedCredentials, 1)
+	go func() {
+		creds, _ := group.do(context.Background(), "key", fn)
+		waiterResult <- creds
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancelLeader()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancelled caller to stop waiting, got %v", err)
+	}
+	close(release)
+	if creds := <-waiterResult; creds == nil || creds.AccessKeyID != "AKID" {
+		t.Errorf("Expected the waiting caller to get the credentials, got %+v", creds)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 call, got %d", n)
+	}
+}
+
+func TestConcurrentRetrieveSeparatesTokenCaches(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+
+	// The same role through another token cache without a token
+	other := &ssoCredentialProvider{
+		startURL:  startURL,
+		ssoRegion: "us-east-1",
+		accountID: "111111111111",
+		roleName:  "Developer",
+		config:    &Config{SSOCacheDir: t.TempDir()},
+	}
+	provider := *other
+	provider.config = nil
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	var otherErr, providerErr error
+	wg.Add(2)
+	go func() { defer wg.Done(); _, otherErr = other.Retrieve(ctx) }()
+	go func() { defer wg.Done(); _, providerErr = provider.Retrieve(ctx) }()
+	wg.Wait()
+
+	var authErr *AuthenticationNeededError
+	if !errors.As(otherErr, &authErr) {
+		t.Errorf("Expected authentication needed error for the empty token cache, got %v", otherErr)
+	}
+	if providerErr != nil {
+		t.Errorf("Retrieve failed: %v", providerErr)
+	}
+}
@@ -0,0 +1,26 @@
+package awsssolib
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// WriteAccountsCSV writes accounts as CSV with an AccountId, AccountName,
+// EmailAddress header row
+func WriteAccountsCSV(w io.Writer, accounts []Account) error {
+	records := [][]string{{"AccountId", "AccountName", "EmailAddress"}}
+	for _, account := range accounts {
+		records = append(records, []string{account.AccountID, account.AccountName, account.EmailAddress})
+	}
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteRolesCSV writes roles as CSV with an AccountId, AccountName, RoleName
+// header row
+func WriteRolesCSV(w io.Writer, roles []Role) error {
+	records := [][]string{{"AccountId", "AccountName", "RoleName"}}
+	for _, role := range roles {
+		records = append(records, []string{role.AccountID, role.AccountName, role.RoleName})
+	}
+	return csv.NewWriter(w).WriteAll(records)
+}
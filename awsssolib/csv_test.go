@@ -0,0 +1,45 @@
+package awsssolib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestWriteAccountsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	accounts := []Account{
+		{AccountID: "123456789012", AccountName: "Prod, \"EU\"", EmailAddress: "aws@example.com"},
+	}
+
+	if err := WriteAccountsCSV(&buf, accounts); err != nil {
+		t.Fatalf("WriteAccountsCSV failed: %v", err)
+	}
+
+	expected := "AccountId,AccountName,EmailAddress\n123456789012,\"Prod, \"\"EU\"\"\",aws@example.com\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV back: %v", err)
+	}
+	if records[1][1] != "Prod, \"EU\"" {
+		t.Errorf("Expected account name to round-trip, got %q", records[1][1])
+	}
+}
+
+func TestWriteRolesCSV(t *testing.T) {
+	var buf bytes.Buffer
+	roles := []Role{{RoleName: "Admin", AccountID: "123456789012", AccountName: "Prod"}}
+
+	if err := WriteRolesCSV(&buf, roles); err != nil {
+		t.Fatalf("WriteRolesCSV failed: %v", err)
+	}
+
+	expected := "AccountId,AccountName,RoleName\n123456789012,Prod,Admin\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
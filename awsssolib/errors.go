@@ -0,0 +1,126 @@
+package awsssolib
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// ErrorKind classifies errors returned by the SSO, OIDC and STS APIs
+type ErrorKind int
+
+const (
+	// ErrorKindUnknown is an error that doesn't match any other kind
+	ErrorKindUnknown ErrorKind = iota
+	// ErrorKindAuthPending means the user hasn't completed the device authorization yet
+	ErrorKindAuthPending
+	// ErrorKindSlowDown means the device flow is polling too fast
+	ErrorKindSlowDown
+	// ErrorKindThrottled means the request was rate limited
+	ErrorKindThrottled
+	// ErrorKindExpiredToken means an access or device token has expired
+	ErrorKindExpiredToken
+	// ErrorKindUnauthorized means the access token was rejected
+	ErrorKindUnauthorized
+	// ErrorKindAccessDenied means the caller isn't allowed to perform the operation
+	ErrorKindAccessDenied
+	// ErrorKindInvalidClient means the OIDC client registration was rejected
+	ErrorKindInvalidClient
+	// ErrorKindNetworkError means the request couldn't be sent or answered
+	ErrorKindNetworkError
+	// ErrorKindServerError means the service failed with a 5xx status
+	ErrorKindServerError
+)
+
+// String returns the name of the error kind
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindAuthPending:
+		return "AuthPending"
+	case ErrorKindSlowDown:
+		return "SlowDown"
+	case ErrorKindThrottled:
+		return "Throttled"
+	case ErrorKindExpiredToken:
+		return "ExpiredToken"
+	case ErrorKindUnauthorized:
+		return "Unauthorized"
+	case ErrorKindAccessDenied:
+		return "AccessDenied"
+	case ErrorKindInvalidClient:
+		return "InvalidClient"
+	case ErrorKindNetworkError:
+		return "NetworkError"
+	case ErrorKindServerError:
+		return "ServerError"
+	default:
+		return "Unknown"
+	}
+}
+
+// errorCodeKinds maps AWS API error codes to error kinds
+var errorCodeKinds = map[string]ErrorKind{
+	"AuthorizationPendingException": ErrorKindAuthPending,
+	"SlowDownException":             ErrorKindSlowDown,
+	"Throttling":                    ErrorKindThrottled,
+	"ThrottlingException":           ErrorKindThrottled,
+	"TooManyRequestsException":      ErrorKindThrottled,
+	"RequestLimitExceeded":          ErrorKindThrottled,
+	"ExpiredToken":                  ErrorKindExpiredToken,
+	"ExpiredTokenException":         ErrorKindExpiredToken,
+	"UnauthorizedException":         ErrorKindUnauthorized,
+	"UnauthorizedClientException":   ErrorKindUnauthorized,
+	"InvalidGrantException":         ErrorKindUnauthorized,
+	"AccessDenied":                  ErrorKindAccessDenied,
+	"AccessDeniedException":         ErrorKindAccessDenied,
+	"ForbiddenException":            ErrorKindAccessDenied,
+	"InvalidClientException":        ErrorKindInvalidClient,
+	"InternalServerException":       ErrorKindServerError,
+	"ServiceUnavailableException":   ErrorKindServerError,
+}
+
+// ClassifyError returns the kind of an SSO, OIDC or STS error.
+//
+// SDK API errors are matched by their error code, also when wrapped. As a
+// fallback the error text is searched for a known error code.
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return ErrorKindUnknown
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if kind, ok := errorCodeKinds[apiErr.ErrorCode()]; ok {
+			return kind
+		}
+	}
+
+	var responseErr *smithyhttp.ResponseError
+	if errors.As(err, &responseErr) && responseErr.HTTPStatusCode() >= 500 {
+		return ErrorKindServerError
+	}
+
+	var sendErr *smithyhttp.RequestSendError
+	var netErr net.Error
+	if errors.As(err, &sendErr) || errors.As(err, &netErr) {
+		return ErrorKindNetworkError
+	}
+
+	// Fall back to the error text, longest codes first so that
+	// ThrottlingException isn't matched as Throttling
+	message := err.Error()
+	var best string
+	for code := range errorCodeKinds {
+		if len(code) > len(best) && strings.Contains(message, code) {
+			best = code
+		}
+	}
+	if best != "" {
+		return errorCodeKinds[best]
+	}
+
+	return ErrorKindUnknown
+}
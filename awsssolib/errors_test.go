@@ -0,0 +1,41 @@
+package awsssolib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorKind
+	}{
+		{"nil", nil, ErrorKindUnknown},
+		{"auth pending", &types.AuthorizationPendingException{}, ErrorKindAuthPending},
+		{"wrapped slow down", fmt.Errorf("polling: %w", &types.SlowDownException{}), ErrorKindSlowDown},
+		{"expired token", &types.ExpiredTokenException{}, ErrorKindExpiredToken},
+		{"invalid client", &types.InvalidClientException{}, ErrorKindInvalidClient},
+		{"unauthorized", &ssotypes.UnauthorizedException{}, ErrorKindUnauthorized},
+		{"throttled", &ssotypes.TooManyRequestsException{}, ErrorKindThrottled},
+		{"network", &smithyhttp.RequestSendError{Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, ErrorKindNetworkError},
+		{"server error", &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 503}}, Err: errors.New("unavailable")}, ErrorKindServerError},
+		{"internal server", &types.InternalServerException{}, ErrorKindServerError},
+		{"string fallback", errors.New("operation error: ThrottlingException: rate exceeded"), ErrorKindThrottled},
+		{"unknown", context.Canceled, ErrorKindUnknown},
+	}
+
+	for _, tt := range tests {
+		if kind := ClassifyError(tt.err); kind != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, kind)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package awsssolib
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newRoleCredentialsServer serves the SSO GetRoleCredentials API and counts calls
+func newRoleCredentialsServer(t testing.TB, calls *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"roleCredentials":{"accessKeyId":"AKID-%s","secretAccessKey":"secret","sessionToken":"session","expiration":%d}}`,
+			r.URL.Query().Get("account_id"), time.Now().Add(time.Hour).UnixMilli())
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// setupFactoryTest isolates the caches and points the SSO API at a local server
+func setupFactoryTest(t testing.TB, calls *atomic.Int32) string {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", "")
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_ENDPOINT_URL_SSO", newRoleCredentialsServer(t, calls).URL)
+
+	startURL := "https://test.awsapps.com/start"
+	err := PutCachedToken(nil, startURL, &Token{
+		AccessToken: "access-token",
+		ExpiresAt:   time.Now().Add(time.Hour),
+		Region:      "us-east-1",
+	})
+	if err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+	return startURL
+}
+
+func TestCredentialFactory(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	ctx := context.Background()
+
+	factory, err := NewCredentialFactory(ctx, CredentialFactoryInput{
+		StartURL:  startURL,
+		SSORegion: "us-east-1",
+		Region:    "eu-west-1",
+	})
+	if err != nil {
+		t.Fatalf("NewCredentialFactory failed: %v", err)
+	}
+
+	// The token is resolved once, so providers keep working without the cache file
+	if err := DeleteCachedToken(nil, startURL); err != nil {
+		t.Fatalf("DeleteCachedToken failed: %v", err)
+	}
+
+	for _, accountID := range []string{"111111111111", "2222-2222-2222"} {
+		creds, err := factory.ProviderFor(accountID, "Developer").Retrieve(ctx)
+		if err != nil {
+			t.Fatalf("Retrieve failed for %s: %v", accountID, err)
+		}
+		if want := "AKID-" + formatAccountID(accountID); creds.AccessKeyID != want {
+			t.Errorf("Expected %s, got %s", want, creds.AccessKeyID)
+		}
+		if want := "aws-sso-lib:" + formatAccountID(accountID) + "/Developer"; creds.Source != want {
+			t.Errorf("Expected source %s, got %s", want, creds.Source)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 GetRoleCredentials calls, got %d", calls.Load())
+	}
+
+	cfg := factory.AWSConfig("111111111111", "Developer", "")
+	if cfg.Region != "eu-west-1" {
+		t.Errorf("Expected the default region, got %s", cfg.Region)
+	}
+	if cfg = factory.AWSConfig("111111111111", "Developer", "us-west-2"); cfg.Region != "us-west-2" {
+		t.Errorf("Expected us-west-2, got %s", cfg.Region)
+	}
+
+	// An expired token is re-read from the cache, which is now empty
+	factory.token.ExpiresAt = time.Now().Add(-time.Minute)
+	_, err = factory.ProviderFor("111111111111", "Developer").Retrieve(ctx)
+	if _, ok := err.(*AuthenticationNeededError); !ok {
+		t.Errorf("Expected AuthenticationNeededError, got %v", err)
+	}
+}
+
+func TestNewCredentialFactoryWithoutToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+
+	_, err := NewCredentialFactory(context.Background(), CredentialFactoryInput{
+		StartURL:  "https://test.awsapps.com/start",
+		SSORegion: "us-east-1",
+	})
+	if _, ok := err.(*AuthenticationNeededError); !ok {
+		t.Errorf("Expected AuthenticationNeededError, got %v", err)
+	}
+}
+
+// benchmarkRoles is the number of account/role configs built per iteration
+const benchmarkRoles = 20
+
+// benchmarkConfig discards the library logs during benchmarks
+var benchmarkConfig = &Config{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+func BenchmarkGetAWSConfigPerRole(b *testing.B) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(b, &calls)
+	ctx := context.Background()
+
+	for i := 0; i < b.N; i++ {
+		for role := 0; role < benchmarkRoles; role++ {
+			cfg, err := GetAWSConfig(ctx, GetAWSConfigInput{
+				StartURL:  startURL,
+				SSORegion: "us-east-1",
+				AccountID: fmt.Sprintf("%012d", role),
+				RoleName:  "Developer",
+				Region:    "us-east-1",
+				Config:    benchmarkConfig,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkCredentialFactory(b *testing.B) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(b, &calls)
+	ctx := context.Background()
+
+	for i := 0; i < b.N; i++ {
+		factory, err := NewCredentialFactory(ctx, CredentialFactoryInput{
+			StartURL:  startURL,
+			SSORegion: "us-east-1",
+			Region:    "us-east-1",
+			Config:    benchmarkConfig,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		for role := 0; role < benchmarkRoles; role++ {
+			cfg := factory.AWSConfig(fmt.Sprintf("%012d", role), "Developer", "")
+			if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package awsssolib
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+)
+
+// iamTokenCreator is the part of the OIDC client used by LoginWithIAM
+type iamTokenCreator interface {
+	CreateTokenWithIAM(ctx context.Context, params *ssooidc.CreateTokenWithIAMInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenWithIAMOutput, error)
+}
+
+// newIAMTokenClient creates the OIDC client for LoginWithIAM; replaced in tests
+var newIAMTokenClient = func(cfg aws.Config) iamTokenCreator {
+	return ssooidc.NewFromConfig(cfg)
+}
+
+// LoginWithIAM obtains an SSO token without user interaction by exchanging a
+// JWT assertion with CreateTokenWithIAM. The call is signed with the IAM
+// credentials of the default credential chain, and the token is cached for
+// the start URL like one from Login.
+func LoginWithIAM(ctx context.Context, input LoginWithIAMInput) (*Token, error) {
+	logger := getLogger(input.Config)
+
+	if err := ValidateStartURL(input.StartURL); err != nil {
+		return nil, err
+	}
+	if err := ValidateRegion(input.SSORegion); err != nil {
+		return nil, err
+	}
+	if input.ClientID == "" {
+		return nil, &InvalidConfigError{Message: "client ID cannot be empty"}
+	}
+	if input.Assertion == "" {
+		return nil, &InvalidConfigError{Message: "assertion cannot be empty"}
+	}
+
+	ctx, span := startSpan(ctx, input.Config, "LoginWithIAM",
+		slog.String("start_url", input.StartURL),
+		slog.String("sso_region", input.SSORegion))
+
+	cfg, err := loadSDKConfig(ctx, input.SSORegion, input.Config)
+	if err != nil {
+		span.End(err)
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	logger.Debug("Creating SSO token with IAM", slog.String("client_id", input.ClientID))
+	resp, err := newIAMTokenClient(cfg).CreateTokenWithIAM(ctx, &ssooidc.CreateTokenWithIAMInput{
+		ClientId:  aws.String(input.ClientID),
+		GrantType: aws.String(grantTypeJWTBearer),
+		Assertion: aws.String(input.Assertion),
+		Scope:     input.Scopes,
+	})
+	span.End(err)
+	if err != nil {
+		recordAPIError(input.Config, "CreateTokenWithIAM", err)
+		logger.Error("Failed to create SSO token with IAM", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to create token with IAM: %w", err)
+	}
+
+	token := &Token{
+		AccessToken:  aws.ToString(resp.AccessToken),
+		ExpiresAt:    time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second),
+		RefreshToken: aws.ToString(resp.RefreshToken),
+		Region:       input.SSORegion,
+		StartURL:     input.StartURL,
+	}
+
+	if err := putCachedToken(getSSOCacheDir(input.Config), input.StartURL, ssoSessionsUsing(input.StartURL), token, input.Config); err != nil {
+		logger.Warn("Failed to cache SSO token", slog.Any("error", err))
+	}
+
+	getMetrics(input.Config).OnLogin(input.StartURL, LoginSourceIAM)
+	logger.Info("SSO token created with IAM", slog.Time("expires_at", token.ExpiresAt))
+	return token, nil
+}
@@ -0,0 +1,82 @@
+package awsssolib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+)
+
+// stubIAMTokenCreator records the last CreateTokenWithIAM request
+type stubIAMTokenCreator struct {
+	params *ssooidc.CreateTokenWithIAMInput
+	err    error
+}
+
+func (s *stubIAMTokenCreator) CreateTokenWithIAM(ctx context.Context, params *ssooidc.CreateTokenWithIAMInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenWithIAMOutput, error) {
+	s.params = params
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &ssooidc.CreateTokenWithIAMOutput{
+		AccessToken:  aws.String("iam-token"),
+		RefreshToken: aws.String("iam-refresh"),
+		ExpiresIn:    3600,
+	}, nil
+}
+
+func TestLoginWithIAM(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+
+	client := &stubIAMTokenCreator{}
+	original := newIAMTokenClient
+	newIAMTokenClient = func(cfg aws.Config) iamTokenCreator { return client }
+	t.Cleanup(func() { newIAMTokenClient = original })
+
+	input := LoginWithIAMInput{
+		StartURL:  "https://test.awsapps.com/start",
+		SSORegion: "us-east-1",
+		ClientID:  "arn:aws:sso::123456789012:application/ssoins-1/apl-1",
+		Assertion: "jwt",
+	}
+	ctx := context.Background()
+
+	for _, missing := range []func(*LoginWithIAMInput){
+		func(in *LoginWithIAMInput) { in.ClientID = "" },
+		func(in *LoginWithIAMInput) { in.Assertion = "" },
+		func(in *LoginWithIAMInput) { in.SSORegion = "" },
+	} {
+		invalid := input
+		missing(&invalid)
+		var configErr *InvalidConfigError
+		if _, err := LoginWithIAM(ctx, invalid); !errors.As(err, &configErr) {
+			t.Errorf("Expected an InvalidConfigError, got %v", err)
+		}
+	}
+
+	token, err := LoginWithIAM(ctx, input)
+	if err != nil {
+		t.Fatalf("LoginWithIAM failed: %v", err)
+	}
+	if token.AccessToken != "iam-token" || token.RefreshToken != "iam-refresh" {
+		t.Errorf("Expected the IAM token, got %+v", token)
+	}
+	if aws.ToString(client.params.GrantType) != grantTypeJWTBearer || aws.ToString(client.params.Assertion) != "jwt" {
+		t.Errorf("Expected a JWT bearer request, got %+v", client.params)
+	}
+
+	cached, err := GetCachedToken(nil, input.StartURL)
+	if err != nil || cached == nil || cached.AccessToken != "iam-token" {
+		t.Errorf("Expected the token to be cached, got %+v (%v)", cached, err)
+	}
+
+	client.err = errors.New("AccessDeniedException")
+	if _, err := LoginWithIAM(ctx, input); err == nil {
+		t.Error("Expected the API error to be returned")
+	}
+}
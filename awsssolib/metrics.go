@@ -0,0 +1,47 @@
+package awsssolib
+
+// Metrics receives library events for observability, for example to export
+// them to Prometheus or OpenTelemetry. Set it on Config; when it is nil the
+// events are dropped. Implementations must be safe for concurrent use and
+// should return quickly, since they are called inline.
+type Metrics interface {
+	// OnTokenCacheHit is called when a valid SSO token is read from the cache
+	OnTokenCacheHit(startURL string)
+	// OnTokenCacheMiss is called when no valid SSO token is cached
+	OnTokenCacheMiss(startURL string)
+	// OnCredentialCacheHit is called when role credentials are served from
+	// the credential cache
+	OnCredentialCacheHit(accountID, roleName string)
+	// OnCredentialCacheMiss is called when role credentials have to be
+	// fetched because the credential cache has none
+	OnCredentialCacheMiss(accountID, roleName string)
+	// OnLogin is called after each successful Login. The source tells
+	// device flow logins apart from cached and supplied tokens.
+	OnLogin(startURL string, source LoginSource)
+	// OnAPIError is called when an SSO or OIDC API call fails, with the name
+	// of the API operation. Pending device authorizations aren't reported.
+	OnAPIError(operation string, kind ErrorKind)
+}
+
+// NoopMetrics is a Metrics implementation that ignores all events
+type NoopMetrics struct{}
+
+func (NoopMetrics) OnTokenCacheHit(startURL string)                  {}
+func (NoopMetrics) OnTokenCacheMiss(startURL string)                 {}
+func (NoopMetrics) OnCredentialCacheHit(accountID, roleName string)  {}
+func (NoopMetrics) OnCredentialCacheMiss(accountID, roleName string) {}
+func (NoopMetrics) OnLogin(startURL string, source LoginSource)      {}
+func (NoopMetrics) OnAPIError(operation string, kind ErrorKind)      {}
+
+// getMetrics returns the metrics of the config, or NoopMetrics
+func getMetrics(config *Config) Metrics {
+	if config != nil && config.Metrics != nil {
+		return config.Metrics
+	}
+	return NoopMetrics{}
+}
+
+// recordAPIError reports a failed API call to the metrics of the config
+func recordAPIError(config *Config, operation string, err error) {
+	getMetrics(config).OnAPIError(operation, ClassifyError(err))
+}
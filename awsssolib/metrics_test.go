@@ -0,0 +1,103 @@
+package awsssolib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// recordingMetrics counts metrics events by name
+type recordingMetrics struct {
+	mu     sync.Mutex
+	events map[string]int
+}
+
+func (m *recordingMetrics) record(format string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.events == nil {
+		m.events = make(map[string]int)
+	}
+	m.events[fmt.Sprintf(format, args...)]++
+}
+
+func (m *recordingMetrics) count(event string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.events[event]
+}
+
+func (m *recordingMetrics) OnTokenCacheHit(startURL string)  { m.record("token_hit") }
+func (m *recordingMetrics) OnTokenCacheMiss(startURL string) { m.record("token_miss") }
+func (m *recordingMetrics) OnCredentialCacheHit(accountID, roleName string) {
+	m.record("credential_hit %s/%s", accountID, roleName)
+}
+func (m *recordingMetrics) OnCredentialCacheMiss(accountID, roleName string) {
+	m.record("credential_miss %s/%s", accountID, roleName)
+}
+func (m *recordingMetrics) OnLogin(startURL string, source LoginSource) {
+	m.record("login %s", source)
+}
+func (m *recordingMetrics) OnAPIError(operation string, kind ErrorKind) {
+	m.record("api_error %s %s", operation, kind)
+}
+
+func TestMetrics(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	ctx := context.Background()
+	metrics := &recordingMetrics{}
+	cfg := &Config{Metrics: metrics}
+
+	if _, err := Login(ctx, LoginInput{StartURL: startURL, SSORegion: "us-east-1", Config: cfg}); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if metrics.count("token_hit") != 1 || metrics.count("login cached") != 1 {
+		t.Errorf("Expected a token cache hit and a cached login, got %v", metrics.events)
+	}
+
+	if _, err := getTokenForOperation(ctx, "https://other.awsapps.com/start", "us-east-1", false, false, nil, cfg); err == nil {
+		t.Fatal("Expected an error without a cached token")
+	}
+	if metrics.count("token_miss") != 1 {
+		t.Errorf("Expected a token cache miss, got %v", metrics.events)
+	}
+
+	provider := &ssoCredentialProvider{
+		startURL:        startURL,
+		ssoRegion:       "us-east-1",
+		accountID:       "111111111111",
+		roleName:        "Developer",
+		credentialCache: NewMemoryCache(),
+		config:          cfg,
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Retrieve(ctx); err != nil {
+			t.Fatalf("Retrieve failed: %v", err)
+		}
+	}
+	if metrics.count("credential_miss 111111111111/Developer") != 1 || metrics.count("credential_hit 111111111111/Developer") != 1 {
+		t.Errorf("Expected one credential cache miss and one hit, got %v", metrics.events)
+	}
+
+	// Rejected tokens are reported as API errors
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-Errortype", "UnauthorizedException")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message":"Session token not found or invalid"}`)
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL_SSO", server.URL)
+
+	provider.credentialCache = nil
+	if _, err := provider.Retrieve(ctx); err == nil {
+		t.Fatal("Expected an error for a rejected token")
+	}
+	if metrics.count("api_error GetRoleCredentials Unauthorized") != 1 {
+		t.Errorf("Expected an Unauthorized API error, got %v", metrics.events)
+	}
+}
@@ -0,0 +1,324 @@
+package awsssolib
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+)
+
+// pkceCallbackPath is the path of the redirect URI served by LoginWithPKCE
+const pkceCallbackPath = "/oauth/callback"
+
+// defaultPKCEScopes are requested when LoginWithPKCEInput.Scopes is empty
+var defaultPKCEScopes = []string{"sso:account:access"}
+
+// pkceGrantTypes are the grant types of clients registered by LoginWithPKCE.
+// The device code grant lets Login reuse the registration cached with the token.
+var pkceGrantTypes = []string{
+	grantTypeAuthorizationCode,
+	grantTypeRefreshToken,
+	grantTypeDeviceCode,
+}
+
+// pkceAPI is the part of the OIDC client used by LoginWithPKCE
+type pkceAPI interface {
+	clientRegistrar
+	tokenCreator
+}
+
+// newPKCEClient creates the OIDC client for LoginWithPKCE; replaced in tests
+var newPKCEClient = func(cfg aws.Config) pkceAPI {
+	return ssooidc.NewFromConfig(cfg)
+}
+
+// pkceCallback is the outcome of the redirect to the redirect server
+type pkceCallback struct {
+	code string
+	err  error
+}
+
+// LoginWithPKCE logs in with the OAuth authorization code flow with PKCE
+// instead of the device flow. It registers a client for a redirect URI on
+// 127.0.0.1, opens the authorization page in the browser and waits for
+// Identity Center to redirect back with the authorization code, so the
+// browser must run on the same machine. The token is cached for the start URL
+// with its scopes, and Login refreshes it like a token of the device flow.
+func LoginWithPKCE(ctx context.Context, input LoginWithPKCEInput) (*LoginOutput, error) {
+	input.StartURL, input.SSORegion = contextInstance(ctx, input.StartURL, input.SSORegion)
+	ctx, span := startSpan(ctx, input.Config, "LoginWithPKCE",
+		slog.String("start_url", input.StartURL),
+		slog.String("sso_region", input.SSORegion))
+	output, err := loginWithPKCE(ctx, input)
+	if err == nil {
+		err = runPostLoginHook(ctx, input.Config, output)
+	}
+	span.End(err)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// loginWithPKCE performs the PKCE login within the span started by LoginWithPKCE
+func loginWithPKCE(ctx context.Context, input LoginWithPKCEInput) (*LoginOutput, error) {
+	logger := getLogger(input.Config)
+
+	if err := ValidateStartURL(input.StartURL); err != nil {
+		return nil, err
+	}
+	if err := ValidateRegion(input.SSORegion); err != nil {
+		return nil, err
+	}
+	if input.ClientName != "" {
+		if err := ValidateClientName(input.ClientName); err != nil {
+			return nil, err
+		}
+	}
+	if input.RedirectPort < 0 || input.RedirectPort > 65535 {
+		return nil, &InvalidConfigError{Message: fmt.Sprintf("invalid redirect port %d", input.RedirectPort)}
+	}
+	scopes := input.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultPKCEScopes
+	}
+
+	if input.Timeout <= 0 {
+		input.Timeout = DefaultLoginTimeout
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < input.Timeout {
+		input.Timeout = time.Until(deadline)
+	}
+	ctx, cancel := context.WithTimeout(ctx, input.Timeout)
+	defer cancel()
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", input.RedirectPort))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the redirect server: %w", err)
+	}
+	redirectURI := "http://" + listener.Addr().String() + pkceCallbackPath
+
+	cfg, err := loadSDKConfig(ctx, input.SSORegion, input.Config)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	client := newPKCEClient(cfg)
+
+	// The redirect URI includes the port, so every login registers a client
+	logger.Debug("Registering SSO client for PKCE", slog.String("redirect_uri", redirectURI))
+	clientName := LoginInput{ClientName: input.ClientName, Config: input.Config}.clientName()
+	registration, err := client.RegisterClient(ctx, &ssooidc.RegisterClientInput{
+		ClientName:   aws.String(clientName),
+		ClientType:   aws.String(defaultClientType),
+		GrantTypes:   pkceGrantTypes,
+		RedirectUris: []string{redirectURI},
+		IssuerUrl:    aws.String(input.StartURL),
+		Scopes:       scopes,
+	})
+	if err != nil {
+		listener.Close()
+		recordAPIError(input.Config, "RegisterClient", err)
+		return nil, fmt.Errorf("failed to register SSO client: %w", err)
+	}
+	clientID := aws.ToString(registration.ClientId)
+
+	verifier, err := randomURLString(32)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	state, err := randomURLString(16)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	callbacks := make(chan pkceCallback, 1)
+	server := &http.Server{
+		Handler:           newPKCECallbackHandler(state, callbacks),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authHandler := input.UserAuthHandler
+	if authHandler == nil {
+		authHandler = newPKCEAuthHandler(input.DisableBrowser)
+	}
+	authorizeURL := pkceAuthorizeURL(input.SSORegion, clientID, redirectURI, state, pkceChallenge(verifier), scopes)
+	expiresAt := time.Now().Add(input.Timeout)
+	err = authHandler(ctx, AuthHandlerParams{
+		VerificationURI:         authorizeURL,
+		VerificationURIComplete: authorizeURL,
+		ExpiresAt:               expiresAt,
+	})
+	if errors.Is(err, ErrLoginCancelled) {
+		logger.Info("SSO login cancelled by the auth handler")
+		return nil, ErrLoginCancelled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Waiting for the SSO authorization in the browser")
+	var callback pkceCallback
+	select {
+	case callback = <-callbacks:
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("SSO login timed out after %v without the authorization being approved: %w", input.Timeout.Round(time.Second), ctx.Err())
+		}
+		return nil, ctx.Err()
+	}
+	if callback.err != nil {
+		logger.Error("SSO authorization failed", slog.Any("error", callback.err))
+		return nil, callback.err
+	}
+
+	resp, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+		ClientId:     aws.String(clientID),
+		ClientSecret: registration.ClientSecret,
+		GrantType:    aws.String(grantTypeAuthorizationCode),
+		Code:         aws.String(callback.code),
+		CodeVerifier: aws.String(verifier),
+		RedirectUri:  aws.String(redirectURI),
+	})
+	if err != nil {
+		recordAPIError(input.Config, "CreateToken", err)
+		logger.Error("Failed to create SSO token", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	token := &Token{
+		AccessToken:           aws.ToString(resp.AccessToken),
+		ExpiresAt:             time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second),
+		RefreshToken:          aws.ToString(resp.RefreshToken),
+		ClientID:              clientID,
+		ClientSecret:          aws.ToString(registration.ClientSecret),
+		RegistrationTime:      time.Now(),
+		RegistrationExpiresAt: registrationExpiry(registration.ClientSecretExpiresAt, time.Now()),
+		Region:                input.SSORegion,
+		StartURL:              input.StartURL,
+		Scopes:                scopes,
+	}
+
+	if err := putCachedToken(getSSOCacheDir(input.Config), input.StartURL, ssoSessionsUsing(input.StartURL), token, input.Config); err != nil {
+		logger.Warn("Failed to cache SSO token", slog.Any("error", err))
+	}
+
+	logger.Info("SSO login with PKCE completed successfully", slog.Time("expires_at", token.ExpiresAt))
+	getMetrics(input.Config).OnLogin(input.StartURL, LoginSourcePKCE)
+	return &LoginOutput{
+		Token:      token,
+		ExpiresAt:  token.ExpiresAt,
+		StartURL:   input.StartURL,
+		Source:     LoginSourcePKCE,
+		Registered: true,
+	}, nil
+}
+
+// newPKCECallbackHandler returns the handler of the redirect URI, which sends
+// the authorization code or error of the first redirect carrying state to
+// callbacks
+func newPKCECallbackHandler(state string, callbacks chan<- pkceCallback) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pkceCallbackPath, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		// Ignore requests that didn't come from this login's authorization
+		if query.Get("state") != state {
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+
+		var callback pkceCallback
+		switch {
+		case query.Get("error") != "":
+			message := query.Get("error")
+			if description := query.Get("error_description"); description != "" {
+				message += ": " + description
+			}
+			callback.err = fmt.Errorf("SSO authorization failed: %s", message)
+		case query.Get("code") == "":
+			callback.err = errors.New("SSO authorization failed: no authorization code in the redirect")
+		default:
+			callback.code = query.Get("code")
+		}
+
+		select {
+		case callbacks <- callback:
+		default:
+			// A login has already completed
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if callback.err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "<p>%s</p>", html.EscapeString(callback.err.Error()))
+			return
+		}
+		fmt.Fprint(w, "<p>SSO login approved. You can close this window.</p>")
+	})
+	return mux
+}
+
+// pkceAuthorizeURL returns the authorization page of the OIDC service in region
+func pkceAuthorizeURL(region, clientID, redirectURI, state, challenge string, scopes []string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"state":                 {state},
+		"code_challenge_method": {"S256"},
+		"code_challenge":        {challenge},
+		"scopes":                {strings.Join(scopes, " ")},
+	}
+	return fmt.Sprintf("https://oidc.%s.amazonaws.com/authorize?%s", region, query.Encode())
+}
+
+// pkceChallenge returns the S256 code challenge of verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomURLString returns n random bytes encoded for URLs
+func randomURLString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newPKCEAuthHandler returns the default auth handler of LoginWithPKCE, which
+// opens the authorization page unless disableBrowser is set and prints its URL
+func newPKCEAuthHandler(disableBrowser bool) AuthHandler {
+	return func(ctx context.Context, params AuthHandlerParams) error {
+		fmt.Fprintf(os.Stderr, "\n")
+		if !disableBrowser {
+			if err := NewBrowserLauncher(false).OpenURL(params.VerificationURIComplete); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to open browser automatically.\n")
+			}
+			fmt.Fprintf(os.Stderr, "Attempting to open the SSO authorization page in your default browser.\n")
+			fmt.Fprintf(os.Stderr, "If the browser does not open, open the following URL on this machine:\n\n")
+		} else {
+			fmt.Fprintf(os.Stderr, "Open the following URL in a browser on this machine:\n\n")
+		}
+		fmt.Fprintf(os.Stderr, "\t%s\n\n", params.VerificationURIComplete)
+		return nil
+	}
+}
@@ -0,0 +1,185 @@
+package awsssolib
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+)
+
+// stubPKCEClient records the PKCE registration and token requests. Its client
+// registration has no ClientSecretExpiresAt, which is unset when unknown.
+type stubPKCEClient struct {
+	registration *ssooidc.RegisterClientInput
+	token        *ssooidc.CreateTokenInput
+}
+
+func (s *stubPKCEClient) RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error) {
+	s.registration = params
+	return &ssooidc.RegisterClientOutput{
+		ClientId:     aws.String("pkce-client"),
+		ClientSecret: aws.String("pkce-secret"),
+	}, nil
+}
+
+func (s *stubPKCEClient) CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error) {
+	s.token = params
+	return &ssooidc.CreateTokenOutput{
+		AccessToken:  aws.String("pkce-token"),
+		RefreshToken: aws.String("pkce-refresh"),
+		ExpiresIn:    3600,
+	}, nil
+}
+
+// useStubPKCEClient replaces the OIDC client of LoginWithPKCE for a test
+func useStubPKCEClient(t *testing.T) *stubPKCEClient {
+	client := &stubPKCEClient{}
+	original := newPKCEClient
+	newPKCEClient = func(cfg aws.Config) pkceAPI { return client }
+	t.Cleanup(func() { newPKCEClient = original })
+	return client
+}
+
+// redirectTo follows the authorization URL like Identity Center would after
+// the user approves, redirecting to the redirect URI with query
+func redirectTo(t *testing.T, authorizeURL string, query url.Values) *http.Response {
+	t.Helper()
+	parsed, err := url.Parse(authorizeURL)
+	if err != nil {
+		t.Fatalf("Invalid authorization URL: %v", err)
+	}
+	resp, err := http.Get(parsed.Query().Get("redirect_uri") + "?" + query.Encode())
+	if err != nil {
+		t.Fatalf("Redirect failed: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestLoginWithPKCE(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+	client := useStubPKCEClient(t)
+	startURL := "https://test.awsapps.com/start"
+
+	var authorizeURL string
+	output, err := LoginWithPKCE(context.Background(), LoginWithPKCEInput{
+		StartURL:  startURL,
+		SSORegion: "us-east-1",
+		Scopes:    []string{"sso:account:access", "codewhisperer:completions"},
+		UserAuthHandler: func(ctx context.Context, params AuthHandlerParams) error {
+			authorizeURL = params.VerificationURIComplete
+			query, _ := url.Parse(authorizeURL)
+			state := query.Query().Get("state")
+
+			// A redirect with another state is not for this login
+			if resp := redirectTo(t, authorizeURL, url.Values{"state": {"other"}, "code": {"stolen"}}); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected a redirect with another state to be rejected, got %d", resp.StatusCode)
+			}
+			redirectTo(t, authorizeURL, url.Values{"state": {state}, "code": {"auth-code"}})
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoginWithPKCE failed: %v", err)
+	}
+	if output.Source != LoginSourcePKCE || output.Token.AccessToken != "pkce-token" {
+		t.Errorf("Expected a PKCE token, got %+v", output)
+	}
+
+	authorize, _ := url.Parse(authorizeURL)
+	query := authorize.Query()
+	if authorize.Host != "oidc.us-east-1.amazonaws.com" || authorize.Path != "/authorize" {
+		t.Errorf("Unexpected authorization URL %s", authorizeURL)
+	}
+	if query.Get("client_id") != "pkce-client" || query.Get("code_challenge_method") != "S256" ||
+		query.Get("scopes") != "sso:account:access codewhisperer:completions" {
+		t.Errorf("Unexpected authorization parameters %v", query)
+	}
+
+	redirectURI := query.Get("redirect_uri")
+	if !strings.HasPrefix(redirectURI, "http://127.0.0.1:") || !strings.HasSuffix(redirectURI, pkceCallbackPath) {
+		t.Errorf("Expected a loopback redirect URI, got %s", redirectURI)
+	}
+	if !reflect.DeepEqual(client.registration.RedirectUris, []string{redirectURI}) ||
+		aws.ToString(client.registration.IssuerUrl) != startURL ||
+		!reflect.DeepEqual(client.registration.GrantTypes, pkceGrantTypes) {
+		t.Errorf("Unexpected client registration %+v", client.registration)
+	}
+
+	if aws.ToString(client.token.GrantType) != grantTypeAuthorizationCode || aws.ToString(client.token.Code) != "auth-code" ||
+		aws.ToString(client.token.RedirectUri) != redirectURI {
+		t.Errorf("Unexpected token request %+v", client.token)
+	}
+	if pkceChallenge(aws.ToString(client.token.CodeVerifier)) != query.Get("code_challenge") {
+		t.Error("Expected the code verifier to match the code challenge")
+	}
+
+	cached, err := readCachedToken(SSOCacheDir(), startURL, nil)
+	if err != nil || cached == nil {
+		t.Fatalf("Expected the token to be cached, got %v", err)
+	}
+	if cached.RefreshToken != "pkce-refresh" || cached.ClientID != "pkce-client" ||
+		!reflect.DeepEqual(cached.Scopes, []string{"sso:account:access", "codewhisperer:completions"}) {
+		t.Errorf("Expected the token to be cached with its registration and scopes, got %+v", cached)
+	}
+	if time.Until(cached.RegistrationExpiresAt) < 89*24*time.Hour {
+		t.Errorf("Expected an unset registration expiry to default to 90 days, got %s", cached.RegistrationExpiresAt)
+	}
+}
+
+func TestLoginWithPKCEDenied(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	useStubPKCEClient(t)
+
+	_, err := LoginWithPKCE(context.Background(), LoginWithPKCEInput{
+		StartURL:  "https://test.awsapps.com/start",
+		SSORegion: "us-east-1",
+		UserAuthHandler: func(ctx context.Context, params AuthHandlerParams) error {
+			state, _ := url.Parse(params.VerificationURIComplete)
+			redirectTo(t, params.VerificationURIComplete, url.Values{
+				"state":             {state.Query().Get("state")},
+				"error":             {"access_denied"},
+				"error_description": {"user denied the request"},
+			})
+			return nil
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "access_denied: user denied the request") {
+		t.Errorf("Expected the authorization error, got %v", err)
+	}
+}
+
+func TestLoginWithPKCETimeout(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	useStubPKCEClient(t)
+
+	_, err := LoginWithPKCE(context.Background(), LoginWithPKCEInput{
+		StartURL:        "https://test.awsapps.com/start",
+		SSORegion:       "us-east-1",
+		Timeout:         50 * time.Millisecond,
+		UserAuthHandler: func(ctx context.Context, params AuthHandlerParams) error { return nil },
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+}
+
+func TestLoginWithPKCEValidation(t *testing.T) {
+	tests := []LoginWithPKCEInput{
+		{StartURL: "not a url", SSORegion: "us-east-1"},
+		{StartURL: "https://test.awsapps.com/start", SSORegion: "nowhere"},
+		{StartURL: "https://test.awsapps.com/start", SSORegion: "us-east-1", RedirectPort: 70000},
+	}
+	for _, input := range tests {
+		if _, err := LoginWithPKCE(context.Background(), input); err == nil {
+			t.Errorf("Expected %+v to be rejected", input)
+		}
+	}
+}
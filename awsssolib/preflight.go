@@ -0,0 +1,120 @@
+package awsssolib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+)
+
+// preflightTimeout bounds each preflight probe
+const preflightTimeout = 5 * time.Second
+
+// preflightHTTPClient is used to probe the start URL
+var preflightHTTPClient = &http.Client{Timeout: preflightTimeout}
+
+// PreflightError is returned when the SSO portal or region fails a preflight check
+type PreflightError struct {
+	Message string
+	Err     error
+}
+
+func (e *PreflightError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *PreflightError) Unwrap() error {
+	return e.Err
+}
+
+// Preflight checks that the start URL is reachable and the SSO region is valid
+// before starting the device flow, turning opaque SDK errors into friendly ones
+func Preflight(ctx context.Context, startURL, ssoRegion string, cfg *Config) error {
+	logger := getLogger(cfg)
+
+	logger.Debug("Running preflight checks",
+		slog.String("start_url", startURL),
+		slog.String("sso_region", ssoRegion))
+
+	if err := checkStartURLReachable(ctx, preflightClient(cfg), startURL); err != nil {
+		return err
+	}
+	if err := CheckSSORegion(ctx, ssoRegion, cfg); err != nil {
+		return err
+	}
+
+	logger.Debug("Preflight checks passed")
+	return nil
+}
+
+// CheckStartURLReachable makes a lightweight request to the SSO start URL
+func CheckStartURLReachable(ctx context.Context, startURL string) error {
+	return checkStartURLReachable(ctx, preflightHTTPClient, startURL)
+}
+
+// preflightClient returns the client for start URL probes, routed through
+// Config.Proxy when it is set
+func preflightClient(cfg *Config) *http.Client {
+	if cfg == nil || cfg.Proxy == nil {
+		return preflightHTTPClient
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(cfg)
+	return &http.Client{Timeout: preflightTimeout, Transport: transport}
+}
+
+func checkStartURLReachable(ctx context.Context, client *http.Client, startURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, startURL, nil)
+	if err != nil {
+		return &InvalidConfigError{Message: fmt.Sprintf("invalid start URL: %v", err)}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return &PreflightError{Message: fmt.Sprintf("SSO portal %s is unreachable", startURL), Err: err}
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return &PreflightError{Message: fmt.Sprintf("SSO portal %s is unavailable (%s)", startURL, resp.Status)}
+	}
+
+	return nil
+}
+
+// CheckSSORegion validates the SSO region by registering an OIDC client in it
+func CheckSSORegion(ctx context.Context, ssoRegion string, cfg *Config) error {
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	sdkConfig, err := loadSDKConfig(ctx, ssoRegion, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	_, err = ssooidc.NewFromConfig(sdkConfig).RegisterClient(ctx, &ssooidc.RegisterClientInput{
+		ClientName: aws.String(LoginInput{Config: cfg}.clientName()),
+		ClientType: aws.String(defaultClientType),
+	})
+	if err == nil {
+		return nil
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return &PreflightError{Message: fmt.Sprintf("SSO region %s looks invalid", ssoRegion), Err: err}
+	}
+	return &PreflightError{Message: fmt.Sprintf("SSO region %s is unreachable", ssoRegion), Err: err}
+}
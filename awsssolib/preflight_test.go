@@ -0,0 +1,39 @@
+package awsssolib
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckStartURLReachable(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	original := preflightHTTPClient
+	preflightHTTPClient = server.Client()
+	defer func() { preflightHTTPClient = original }()
+
+	ctx := context.Background()
+
+	if err := CheckStartURLReachable(ctx, server.URL); err != nil {
+		t.Errorf("Expected reachable portal, got %v", err)
+	}
+
+	status = http.StatusServiceUnavailable
+	var preflightErr *PreflightError
+	if err := CheckStartURLReachable(ctx, server.URL); !errors.As(err, &preflightErr) {
+		t.Errorf("Expected PreflightError for unavailable portal, got %v", err)
+	}
+
+	url := server.URL
+	server.Close()
+	if err := CheckStartURLReachable(ctx, url); !errors.As(err, &preflightErr) {
+		t.Errorf("Expected PreflightError for unreachable portal, got %v", err)
+	}
+}
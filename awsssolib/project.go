@@ -0,0 +1,132 @@
+package awsssolib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ProjectConfigFileName is the name of the project-scoped SSO config file
+const ProjectConfigFileName = ".aws-sso"
+
+// ProjectConfig is the SSO target pinned by a project's .aws-sso file.
+//
+// The file is either JSON or flat TOML with the keys start_url, sso_region,
+// account_id and role_name:
+//
+//	start_url = "https://my-sso.awsapps.com/start"
+//	sso_region = "us-east-1"
+//	account_id = "123456789012"
+//	role_name = "Developer"
+type ProjectConfig struct {
+	StartURL  string `json:"start_url"`
+	SSORegion string `json:"sso_region"`
+	AccountID string `json:"account_id"`
+	RoleName  string `json:"role_name"`
+	// Path is the file the configuration was read from
+	Path string `json:"-"`
+}
+
+// FindProjectConfig looks for a .aws-sso file in dir and its parent
+// directories. An empty dir starts at the current directory. It returns nil
+// when no file is found.
+func FindProjectConfig(dir string) (*ProjectConfig, error) {
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		dir = wd
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectConfigFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return LoadProjectConfig(path)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig reads a .aws-sso file in JSON or flat TOML format
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &ProjectConfig{}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := json.Unmarshal(data, project); err != nil {
+			return nil, &InvalidConfigError{Message: fmt.Sprintf("failed to parse %s: %v", path, err)}
+		}
+	} else if err := parseProjectTOML(data, project); err != nil {
+		return nil, &InvalidConfigError{Message: fmt.Sprintf("failed to parse %s: %v", path, err)}
+	}
+	project.Path = path
+
+	if project.StartURL != "" {
+		if err := ValidateStartURL(project.StartURL); err != nil {
+			return nil, &InvalidConfigError{Message: fmt.Sprintf("%s: %v", path, err)}
+		}
+	}
+
+	return project, nil
+}
+
+// parseProjectTOML parses the flat key = "value" subset of TOML used by .aws-sso files
+func parseProjectTOML(data []byte, project *ProjectConfig) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid string %s", lineNumber, value)
+			}
+			value = unquoted
+		}
+
+		switch key {
+		case "start_url":
+			project.StartURL = value
+		case "sso_region":
+			project.SSORegion = value
+		case "account_id":
+			project.AccountID = value
+		case "role_name":
+			project.RoleName = value
+		default:
+			return fmt.Errorf("line %d: unknown key %s", lineNumber, key)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,129 @@
+package awsssolib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "services", "api")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	content := `# Pinned SSO target
+start_url = "https://team.awsapps.com/start"
+sso_region = "eu-west-1"
+account_id = "123456789012"
+role_name = "Developer"
+`
+	if err := os.WriteFile(filepath.Join(root, ProjectConfigFileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := FindProjectConfig(nested)
+	if err != nil {
+		t.Fatalf("FindProjectConfig failed: %v", err)
+	}
+	if project == nil {
+		t.Fatal("Expected project config to be found in a parent directory")
+	}
+	if project.StartURL != "https://team.awsapps.com/start" || project.SSORegion != "eu-west-1" {
+		t.Errorf("Unexpected SSO settings: %+v", project)
+	}
+	if project.AccountID != "123456789012" || project.RoleName != "Developer" {
+		t.Errorf("Unexpected account and role: %+v", project)
+	}
+	if project.Path != filepath.Join(root, ProjectConfigFileName) {
+		t.Errorf("Expected path %s, got %s", filepath.Join(root, ProjectConfigFileName), project.Path)
+	}
+}
+
+func TestLoadProjectConfigJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ProjectConfigFileName)
+	content := `{"start_url": "https://team.awsapps.com/start", "sso_region": "us-east-1"}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := LoadProjectConfig(path)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig failed: %v", err)
+	}
+	if project.StartURL != "https://team.awsapps.com/start" || project.SSORegion != "us-east-1" {
+		t.Errorf("Unexpected SSO settings: %+v", project)
+	}
+}
+
+func TestLoadProjectConfigInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := map[string]string{
+		"unknown key":   `sso_start = "https://team.awsapps.com/start"`,
+		"missing value": `start_url`,
+		"bad url":       `start_url = "http://team.awsapps.com/start"`,
+		"bad json":      `{"start_url": }`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadProjectConfig(path); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestFindInstancePrefersProjectOverConfig(t *testing.T) {
+	t.Setenv("AWS_DEFAULT_SSO_START_URL", "")
+	t.Setenv("AWS_DEFAULT_SSO_REGION", "")
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config")
+	config := NewConfigFile()
+	config.SetProfile(&Profile{Name: "dev", StartURL: "https://global.awsapps.com/start", SSORegion: "us-east-1"})
+	if err := config.SaveConfigFileWithOptions(filename, SaveOptions{NoBackup: true}); err != nil {
+		t.Fatalf("SaveConfigFileWithOptions failed: %v", err)
+	}
+
+	content := "start_url = \"https://team.awsapps.com/start\"\nsso_region = \"eu-west-1\"\nrole_name = \"Developer\"\n"
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	instance, err := FindInstanceInConfigFile("", filename)
+	if err != nil {
+		t.Fatalf("FindInstanceInConfigFile failed: %v", err)
+	}
+	if instance.StartURL != "https://team.awsapps.com/start" || instance.StartURLSource != InstanceSourceProject {
+		t.Errorf("Expected the project file to take precedence over the config, got %+v", instance)
+	}
+	if instance.RoleName != "Developer" {
+		t.Errorf("Expected the project role name, got %q", instance.RoleName)
+	}
+
+	t.Setenv("AWS_DEFAULT_SSO_START_URL", "https://env.awsapps.com/start")
+	t.Setenv("AWS_DEFAULT_SSO_REGION", "us-west-2")
+	instance, err = FindInstanceInConfigFile("", filename)
+	if err != nil {
+		t.Fatalf("FindInstanceInConfigFile failed: %v", err)
+	}
+	if instance.StartURLSource != InstanceSourceEnvironment {
+		t.Errorf("Expected the environment to take precedence over the project file, got %+v", instance)
+	}
+}
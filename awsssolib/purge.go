@@ -0,0 +1,144 @@
+package awsssolib
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheFileNameRegex matches the SHA1 file names of the AWS CLI token and
+// credential caches, which this library uses too
+var cacheFileNameRegex = regexp.MustCompile(`^[0-9a-f]{40}\.json$`)
+
+// PurgeCacheOptions contains parameters for PurgeCache
+type PurgeCacheOptions struct {
+	// All removes every token and credential cache file, not only expired ones
+	All bool
+	// DryRun reports the files that would be removed without removing them
+	DryRun bool
+	// SSOCacheDir is the token cache directory (default: SSOCacheDir)
+	SSOCacheDir string
+	// CredentialCacheDir is the credential cache directory (default: CLICacheDir)
+	CredentialCacheDir string
+}
+
+// PurgeCache removes expired files from the SSO token cache and the
+// credential cache, or all of them with All, and returns their paths, sorted.
+// Only files named like the AWS CLI cache files that hold a token or
+// credentials are touched; other files in the directories are left alone. An
+// expired token is kept while its refresh token and client registration can
+// still renew it.
+func PurgeCache(opts PurgeCacheOptions) ([]string, error) {
+	return purgeCache(opts, time.Now())
+}
+
+// purgeCache removes the cache files that are expired at now
+func purgeCache(opts PurgeCacheOptions, now time.Time) ([]string, error) {
+	if opts.SSOCacheDir == "" {
+		opts.SSOCacheDir = SSOCacheDir()
+	}
+	if opts.CredentialCacheDir == "" {
+		opts.CredentialCacheDir = CLICacheDir()
+	}
+
+	var purge []string
+
+	tokenFiles, err := cacheFiles(opts.SSOCacheDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range tokenFiles {
+		token, err := readCachedTokenFile(path)
+		if err != nil || token == nil || token.AccessToken == "" {
+			continue
+		}
+		renewable := token.RefreshToken != "" && now.Before(token.RegistrationExpiresAt)
+		if opts.All || (!now.Before(token.ExpiresAt) && !renewable) {
+			purge = append(purge, path)
+		}
+	}
+
+	credentialFiles, err := cacheFiles(opts.CredentialCacheDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range credentialFiles {
+		expiration, ok := readCredentialCacheExpiration(path)
+		if ok && (opts.All || !now.Before(expiration)) {
+			purge = append(purge, path)
+		}
+	}
+
+	sort.Strings(purge)
+	if opts.DryRun {
+		return purge, nil
+	}
+
+	var removed []string
+	for _, path := range purge {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// cacheFiles returns the files of dir named like AWS CLI cache files or this
+// library's credential cache entries. A missing directory has none.
+func cacheFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if cacheFileNameRegex.MatchString(name) || (strings.HasPrefix(name, "aws-sso-creds-") && strings.HasSuffix(name, ".json")) {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	return files, nil
+}
+
+// readCredentialCacheExpiration returns the expiry of a credential cache file
+// in the AWS CLI format, with the expiry under Credentials, or in the
+// CachedCredentials format. It reports false for files holding neither.
+func readCredentialCacheExpiration(path string) (time.Time, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var entry struct {
+		AccessKeyID string    `json:"AccessKeyId"`
+		Expiration  time.Time `json:"Expiration"`
+		Credentials struct {
+			AccessKeyID string    `json:"AccessKeyId"`
+			Expiration  time.Time `json:"Expiration"`
+		} `json:"Credentials"`
+	}
+	if json.Unmarshal(data, &entry) != nil {
+		return time.Time{}, false
+	}
+
+	switch {
+	case entry.AccessKeyID != "" && !entry.Expiration.IsZero():
+		return entry.Expiration, true
+	case entry.Credentials.AccessKeyID != "" && !entry.Credentials.Expiration.IsZero():
+		return entry.Credentials.Expiration, true
+	default:
+		return time.Time{}, false
+	}
+}
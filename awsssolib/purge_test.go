@@ -0,0 +1,105 @@
+package awsssolib
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPurgeCache(t *testing.T) {
+	ssoDir := t.TempDir()
+	credentialDir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tokens := map[string]*Token{
+		"https://valid.awsapps.com/start":   {AccessToken: "token", ExpiresAt: now.Add(time.Hour)},
+		"https://expired.awsapps.com/start": {AccessToken: "token", ExpiresAt: now.Add(-time.Hour)},
+		"https://refresh.awsapps.com/start": {
+			AccessToken:           "token",
+			ExpiresAt:             now.Add(-time.Hour),
+			RefreshToken:          "refresh",
+			ClientID:              "client",
+			ClientSecret:          "secret",
+			RegistrationExpiresAt: now.Add(24 * time.Hour),
+		},
+	}
+	for startURL, token := range tokens {
+		if err := putCachedToken(ssoDir, startURL, nil, token, nil); err != nil {
+			t.Fatalf("putCachedToken failed: %v", err)
+		}
+	}
+
+	writeFile := func(dir, name, data string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	hexName := func(c string) string { return strings.Repeat(c, 40) + ".json" }
+
+	// Files that aren't cache entries are never touched
+	notes := writeFile(ssoDir, "notes.json", `{"accessToken":"x","expiresAt":"2000-01-01T00:00:00Z"}`)
+	registration := writeFile(ssoDir, "botocore-client-id-us-east-1.json", `{"clientId":"id"}`)
+	other := writeFile(credentialDir, hexName("c"), `{"something":"else"}`)
+
+	expiredCLI := writeFile(credentialDir, hexName("a"), `{"Credentials":{"AccessKeyId":"AKIA","Expiration":"`+now.Add(-time.Minute).Format(time.RFC3339)+`"}}`)
+	validLib := writeFile(credentialDir, "aws-sso-creds-key.json", `{"AccessKeyId":"AKIA","Expiration":"`+now.Add(time.Hour).Format(time.RFC3339)+`"}`)
+
+	opts := PurgeCacheOptions{SSOCacheDir: ssoDir, CredentialCacheDir: credentialDir, DryRun: true}
+	purged, err := purgeCache(opts, now)
+	if err != nil {
+		t.Fatalf("purgeCache failed: %v", err)
+	}
+	want := []string{ssoCacheFilePath(ssoDir, "https://expired.awsapps.com/start"), expiredCLI}
+	if strings.Join(purged, " ") != strings.Join(sortedCopy(want), " ") {
+		t.Errorf("Expected %v, got %v", sortedCopy(want), purged)
+	}
+	if _, err := os.Stat(expiredCLI); err != nil {
+		t.Error("Expected a dry run to keep the files")
+	}
+
+	opts.DryRun = false
+	if _, err := purgeCache(opts, now); err != nil {
+		t.Fatalf("purgeCache failed: %v", err)
+	}
+	if _, err := os.Stat(expiredCLI); !os.IsNotExist(err) {
+		t.Error("Expected the expired credentials to be removed")
+	}
+	if _, err := os.Stat(ssoCacheFilePath(ssoDir, "https://refresh.awsapps.com/start")); err != nil {
+		t.Error("Expected a renewable token to be kept")
+	}
+
+	opts.All = true
+	purged, err = purgeCache(opts, now)
+	if err != nil {
+		t.Fatalf("purgeCache failed: %v", err)
+	}
+	if len(purged) != 3 {
+		t.Errorf("Expected the 2 remaining tokens and the credentials to be removed, got %v", purged)
+	}
+	for _, path := range []string{notes, registration, other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be left alone", path)
+		}
+	}
+	if _, err := os.Stat(validLib); !os.IsNotExist(err) {
+		t.Error("Expected all credentials to be removed")
+	}
+
+	if purged, err := purgeCache(PurgeCacheOptions{SSOCacheDir: filepath.Join(ssoDir, "missing"), CredentialCacheDir: filepath.Join(credentialDir, "missing")}, now); err != nil || len(purged) != 0 {
+		t.Errorf("Expected nothing to purge without cache directories, got %v (%v)", purged, err)
+	}
+}
+
+// sortedCopy returns a sorted copy of values
+func sortedCopy(values []string) []string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return sorted
+}
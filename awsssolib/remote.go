@@ -0,0 +1,210 @@
+package awsssolib
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RemoteConfigURLEnvVar names the environment variable with the URL of a
+// remote SSO config, used by FindInstance when no other source is set
+const RemoteConfigURLEnvVar = "AWS_SSO_CONFIG_URL"
+
+// DefaultRemoteConfigTTL is how long a fetched remote config is reused
+const DefaultRemoteConfigTTL = time.Hour
+
+// maxRemoteConfigSize bounds the remote config document
+const maxRemoteConfigSize = 64 << 10
+
+// remoteConfigHTTPClient fetches remote configs; replaced in tests
+var remoteConfigHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// RemoteConfig is the SSO config published at a URL, for example by IT for
+// zero-touch onboarding:
+//
+//	{
+//	  "start_url": "https://my-sso.awsapps.com/start",
+//	  "sso_region": "us-east-1",
+//	  "profile_template": "{account_name}.{role_name}"
+//	}
+type RemoteConfig struct {
+	StartURL  string `json:"start_url"`
+	SSORegion string `json:"sso_region"`
+	// ProfileTemplate is the default template of generated profile names
+	ProfileTemplate string `json:"profile_template,omitempty"`
+}
+
+// RemoteConfigOptions controls how FindInstanceFromURLWithOptions fetches
+// and caches a remote config
+type RemoteConfigOptions struct {
+	// TTL is how long the cached config is used before it is fetched again
+	// (default: DefaultRemoteConfigTTL)
+	TTL time.Duration
+	// CacheDir overrides the cache directory (default: RemoteConfigCacheDir)
+	CacheDir string
+}
+
+// RemoteConfigError means the remote SSO config at URL couldn't be fetched
+// or is invalid, and no cached copy is available
+type RemoteConfigError struct {
+	URL string
+	Err error
+}
+
+func (e RemoteConfigError) Error() string {
+	return fmt.Sprintf("failed to read SSO config from %s: %v", e.URL, e.Err)
+}
+
+func (e RemoteConfigError) Unwrap() error {
+	return e.Err
+}
+
+// cachedRemoteConfig is the cache file of a remote config
+type cachedRemoteConfig struct {
+	URL       string       `json:"url"`
+	FetchedAt time.Time    `json:"fetchedAt"`
+	Config    RemoteConfig `json:"config"`
+}
+
+// RemoteConfigCacheDir returns the directory where fetched remote configs
+// are cached, under the current home directory
+func RemoteConfigCacheDir() string {
+	return filepath.Join(userHomeDir(), ".aws", "sso", "remote-config")
+}
+
+// FindInstanceFromURL fetches the SSO config published at an HTTPS URL and
+// returns its instance. The config is cached for DefaultRemoteConfigTTL.
+func FindInstanceFromURL(ctx context.Context, configURL string) (*SSOInstance, error) {
+	return FindInstanceFromURLWithOptions(ctx, configURL, RemoteConfigOptions{})
+}
+
+// FindInstanceFromURLWithOptions fetches the SSO config published at an HTTPS
+// URL and returns its instance, using the cached copy while it is younger
+// than the TTL. When the config can't be fetched, an expired cached copy is
+// used rather than failing.
+func FindInstanceFromURLWithOptions(ctx context.Context, configURL string, opts RemoteConfigOptions) (*SSOInstance, error) {
+	config, err := loadRemoteConfig(ctx, configURL, opts, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &SSOInstance{
+		StartURL:        config.StartURL,
+		Region:          config.SSORegion,
+		StartURLSource:  InstanceSourceRemote,
+		RegionSource:    InstanceSourceRemote,
+		ProfileTemplate: config.ProfileTemplate,
+	}, nil
+}
+
+// loadRemoteConfig returns the remote config at configURL, from the cache
+// when it was fetched within the TTL before now
+func loadRemoteConfig(ctx context.Context, configURL string, opts RemoteConfigOptions, now time.Time) (*RemoteConfig, error) {
+	parsed, err := url.Parse(configURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, &InvalidConfigError{Message: fmt.Sprintf("invalid SSO config URL: %q (must be an https:// URL)", configURL)}
+	}
+
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultRemoteConfigTTL
+	}
+	if opts.CacheDir == "" {
+		opts.CacheDir = RemoteConfigCacheDir()
+	}
+	path := remoteConfigCachePath(opts.CacheDir, configURL)
+
+	cached := readCachedRemoteConfig(path, configURL)
+	if cached != nil && now.Sub(cached.FetchedAt) < opts.TTL {
+		return &cached.Config, nil
+	}
+
+	config, err := fetchRemoteConfig(ctx, configURL)
+	if err != nil {
+		if cached != nil {
+			return &cached.Config, nil
+		}
+		return nil, RemoteConfigError{URL: configURL, Err: err}
+	}
+
+	// The config is usable even if it can't be cached
+	writeCachedRemoteConfig(path, cachedRemoteConfig{URL: configURL, FetchedAt: now, Config: *config})
+	return config, nil
+}
+
+// fetchRemoteConfig downloads and validates the remote config at configURL
+func fetchRemoteConfig(ctx context.Context, configURL string) (*RemoteConfig, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, configURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := remoteConfigHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRemoteConfigSize {
+		return nil, fmt.Errorf("config larger than %d bytes", maxRemoteConfigSize)
+	}
+
+	config := &RemoteConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if err := ValidateStartURL(config.StartURL); err != nil {
+		return nil, err
+	}
+	if err := ValidateRegion(config.SSORegion); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// remoteConfigCachePath returns the cache file of the remote config at configURL
+func remoteConfigCachePath(dir, configURL string) string {
+	hash := sha1.Sum([]byte(configURL))
+	return filepath.Join(dir, hex.EncodeToString(hash[:])+".json")
+}
+
+// readCachedRemoteConfig returns the cached remote config of configURL, or
+// nil when there is no usable cache file
+func readCachedRemoteConfig(path, configURL string) *cachedRemoteConfig {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var cached cachedRemoteConfig
+	if err := json.Unmarshal(data, &cached); err != nil || cached.URL != configURL {
+		return nil
+	}
+	return &cached
+}
+
+// writeCachedRemoteConfig caches a fetched remote config, ignoring errors
+func writeCachedRemoteConfig(path string, cached cachedRemoteConfig) {
+	data, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	os.WriteFile(path, data, 0600)
+}
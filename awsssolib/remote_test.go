@@ -0,0 +1,114 @@
+package awsssolib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newRemoteConfigServer serves a remote config at /sso.json, or fails with
+// a 500 once failing is set
+func newRemoteConfigServer(t *testing.T, requests *atomic.Int32, failing *atomic.Bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failing.Load() || r.URL.Path != "/sso.json" {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"start_url":"https://corp.awsapps.com/start","sso_region":"eu-west-1","profile_template":"{account_name}.{role_name}"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	original := remoteConfigHTTPClient
+	remoteConfigHTTPClient = server.Client()
+	t.Cleanup(func() { remoteConfigHTTPClient = original })
+	return server
+}
+
+func TestFindInstanceFromURL(t *testing.T) {
+	var requests atomic.Int32
+	var failing atomic.Bool
+	server := newRemoteConfigServer(t, &requests, &failing)
+	configURL := server.URL + "/sso.json"
+	opts := RemoteConfigOptions{CacheDir: t.TempDir()}
+	ctx := context.Background()
+
+	instance, err := FindInstanceFromURLWithOptions(ctx, configURL, opts)
+	if err != nil {
+		t.Fatalf("FindInstanceFromURLWithOptions failed: %v", err)
+	}
+	if instance.StartURL != "https://corp.awsapps.com/start" || instance.Region != "eu-west-1" ||
+		instance.StartURLSource != InstanceSourceRemote || instance.ProfileTemplate != "{account_name}.{role_name}" {
+		t.Errorf("Unexpected instance %+v", instance)
+	}
+
+	// The cached copy is used within the TTL
+	if _, err := FindInstanceFromURLWithOptions(ctx, configURL, opts); err != nil {
+		t.Fatalf("FindInstanceFromURLWithOptions failed: %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected 1 request within the TTL, got %d", n)
+	}
+
+	now := time.Now()
+	if _, err := loadRemoteConfig(ctx, configURL, opts, now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("loadRemoteConfig failed: %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected the expired config to be fetched again, got %d requests", n)
+	}
+
+	// An expired copy is better than no config when the server is down
+	failing.Store(true)
+	config, err := loadRemoteConfig(ctx, configURL, opts, now.Add(4*time.Hour))
+	if err != nil || config.StartURL != "https://corp.awsapps.com/start" {
+		t.Errorf("Expected the expired cached config, got %+v (%v)", config, err)
+	}
+
+	var remoteErr RemoteConfigError
+	if _, err := FindInstanceFromURLWithOptions(ctx, server.URL+"/other.json", opts); !errors.As(err, &remoteErr) {
+		t.Errorf("Expected a RemoteConfigError without a cached copy, got %v", err)
+	}
+
+	var configErr *InvalidConfigError
+	if _, err := FindInstanceFromURL(ctx, "http://corp.example.com/sso.json"); !errors.As(err, &configErr) {
+		t.Errorf("Expected a plain HTTP URL to be rejected, got %v", err)
+	}
+}
+
+func TestFindInstanceFromRemoteConfigURL(t *testing.T) {
+	var requests atomic.Int32
+	var failing atomic.Bool
+	server := newRemoteConfigServer(t, &requests, &failing)
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_DEFAULT_SSO_START_URL", "")
+	t.Setenv("AWS_DEFAULT_SSO_REGION", "")
+	t.Setenv(RemoteConfigURLEnvVar, server.URL+"/sso.json")
+
+	filename := filepath.Join(t.TempDir(), "config")
+	config := NewConfigFile()
+	config.SetProfile(&Profile{Name: "dev", StartURL: "https://global.awsapps.com/start", SSORegion: "us-east-1"})
+	if err := config.SaveConfigFileWithOptions(filename, SaveOptions{NoBackup: true}); err != nil {
+		t.Fatalf("SaveConfigFileWithOptions failed: %v", err)
+	}
+
+	instance, err := FindInstanceInConfigFile("", filename)
+	if err != nil {
+		t.Fatalf("FindInstanceInConfigFile failed: %v", err)
+	}
+	if instance.StartURL != "https://corp.awsapps.com/start" || instance.RegionSource != InstanceSourceRemote {
+		t.Errorf("Expected the remote config to take precedence over the config file, got %+v", instance)
+	}
+	if _, err := os.Stat(remoteConfigCachePath(RemoteConfigCacheDir(), server.URL+"/sso.json")); err != nil {
+		t.Errorf("Expected the remote config to be cached: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package awsssolib
+
+import (
+	"fmt"
+	"strings"
+)
+
+// accountIDLength is the number of digits in an AWS account ID
+const accountIDLength = 12
+
+// ResolveAccountID resolves a full or partial account ID against a list of accounts.
+//
+// An exact match wins. Otherwise the query must be a prefix or suffix of
+// exactly one account ID; dashes and spaces in the query are ignored.
+func ResolveAccountID(accounts []Account, query string) (string, error) {
+	digits := formatAccountID(query)
+	if digits == "" {
+		return "", &InvalidConfigError{Message: fmt.Sprintf("invalid account ID: %s", query)}
+	}
+
+	var matches []string
+	for _, account := range accounts {
+		accountID := formatAccountID(account.AccountID)
+		if accountID == digits {
+			return accountID, nil
+		}
+		if strings.HasPrefix(accountID, digits) || strings.HasSuffix(accountID, digits) {
+			matches = append(matches, accountID)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", &InvalidConfigError{Message: fmt.Sprintf("no account matches %s", query)}
+	case 1:
+		return matches[0], nil
+	default:
+		return "", &InvalidConfigError{Message: fmt.Sprintf("account %s is ambiguous, matches: %s", query, strings.Join(matches, ", "))}
+	}
+}
+
+// IsFullAccountID reports whether the value is a complete 12-digit account ID
+func IsFullAccountID(accountID string) bool {
+	return len(formatAccountID(accountID)) == accountIDLength
+}
+
+// ResolveRoleName resolves a role name case-insensitively against the roles of
+// an account and returns the canonical name.
+//
+// An exact match wins. When several roles differ only by case, the query is
+// ambiguous. When nothing matches, the error suggests the closest role name.
+func ResolveRoleName(roles []Role, accountID, query string) (string, error) {
+	accountID = formatAccountID(accountID)
+
+	var names []string
+	var matches []string
+	for _, role := range roles {
+		if accountID != "" && formatAccountID(role.AccountID) != accountID {
+			continue
+		}
+		if role.RoleName == query {
+			return role.RoleName, nil
+		}
+		if strings.EqualFold(role.RoleName, query) {
+			matches = append(matches, role.RoleName)
+		}
+		names = append(names, role.RoleName)
+	}
+
+	switch len(matches) {
+	case 0:
+		message := fmt.Sprintf("role %s not found in account %s", query, accountID)
+		if suggestion := closestName(names, query); suggestion != "" {
+			message += fmt.Sprintf(", did you mean %s?", suggestion)
+		}
+		return "", &InvalidConfigError{Message: message}
+	case 1:
+		return matches[0], nil
+	default:
+		return "", &InvalidConfigError{Message: fmt.Sprintf("role %s is ambiguous, matches: %s", query, strings.Join(matches, ", "))}
+	}
+}
+
+// closestName returns the name with the smallest case-insensitive edit distance
+// to query, or "" when no name is reasonably close
+func closestName(names []string, query string) string {
+	query = strings.ToLower(query)
+
+	best := ""
+	bestDistance := len(query)/3 + 2
+	for _, name := range names {
+		if d := editDistance(strings.ToLower(name), query); d < bestDistance {
+			best = name
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,86 @@
+package awsssolib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveAccountID(t *testing.T) {
+	accounts := []Account{
+		{AccountID: "123456789012", AccountName: "prod"},
+		{AccountID: "123400005678", AccountName: "dev"},
+		{AccountID: "999988887777", AccountName: "audit"},
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+		wantErr  string
+	}{
+		{"123456789012", "123456789012", ""},
+		{"1234-5678-9012", "123456789012", ""},
+		{"9012", "123456789012", ""},
+		{"99998", "999988887777", ""},
+		{"5678", "123400005678", ""},
+		{"1234", "", "ambiguous"},
+		{"4444", "", "no account matches"},
+		{"abc", "", "invalid account ID"},
+	}
+
+	for _, tt := range tests {
+		result, err := ResolveAccountID(accounts, tt.query)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Query %s: expected error containing %q, got %v", tt.query, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Query %s: unexpected error: %v", tt.query, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Query %s: expected %s, got %s", tt.query, tt.expected, result)
+		}
+	}
+}
+
+func TestResolveRoleName(t *testing.T) {
+	roles := []Role{
+		{RoleName: "AdministratorAccess", AccountID: "123456789012"},
+		{RoleName: "ReadOnlyAccess", AccountID: "123456789012"},
+		{RoleName: "Deploy", AccountID: "123456789012"},
+		{RoleName: "DEPLOY", AccountID: "123456789012"},
+		{RoleName: "Billing", AccountID: "999988887777"},
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+		wantErr  string
+	}{
+		{"AdministratorAccess", "AdministratorAccess", ""},
+		{"administratoraccess", "AdministratorAccess", ""},
+		{"Deploy", "Deploy", ""},
+		{"deploy", "", "ambiguous"},
+		{"ReadOnlyAcess", "", "did you mean ReadOnlyAccess?"},
+		{"Billing", "", "not found"},
+	}
+
+	for _, tt := range tests {
+		result, err := ResolveRoleName(roles, "123456789012", tt.query)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Query %s: expected error containing %q, got %v", tt.query, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Query %s: unexpected error: %v", tt.query, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Query %s: expected %s, got %s", tt.query, tt.expected, result)
+		}
+	}
+}
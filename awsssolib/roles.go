@@ -0,0 +1,116 @@
+package awsssolib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GroupRolesByAccount groups roles under their accounts. Accounts are sorted
+// by name, then ID, and the roles of each account by role name.
+func GroupRolesByAccount(roles []Role) []AccountRoles {
+	index := make(map[string]int)
+	var groups []AccountRoles
+
+	for _, role := range roles {
+		accountID := formatAccountID(role.AccountID)
+		i, ok := index[accountID]
+		if !ok {
+			i = len(groups)
+			index[accountID] = i
+			groups = append(groups, AccountRoles{
+				Account: Account{AccountID: accountID, AccountName: role.AccountName},
+			})
+		}
+		groups[i].Roles = append(groups[i].Roles, role)
+	}
+
+	sortAccountRoles(groups)
+	return groups
+}
+
+// sortAccountRoles sorts accounts by name, then ID, and the roles of each
+// account by role name
+func sortAccountRoles(groups []AccountRoles) {
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := strings.ToLower(groups[i].Account.AccountName), strings.ToLower(groups[j].Account.AccountName)
+		if a != b {
+			return a < b
+		}
+		return groups[i].Account.AccountID < groups[j].Account.AccountID
+	})
+	for _, group := range groups {
+		sort.SliceStable(group.Roles, func(i, j int) bool {
+			return group.Roles[i].RoleName < group.Roles[j].RoleName
+		})
+	}
+}
+
+// RoleFilter restricts roles by account and role. Patterns are globs, where
+// * matches any text and ? one character, ignoring case, unless enclosed in
+// slashes, such as /^(Admin|Billing)$/, which makes them regular expressions.
+// A role is kept when it matches one of the patterns of each non-empty list.
+type RoleFilter struct {
+	// Accounts match the account ID or name
+	Accounts []string
+	// Roles match the role name
+	Roles []string
+}
+
+// FilterRoles returns the roles kept by filter, in their order. It fails when
+// a pattern is invalid.
+func FilterRoles(roles []Role, filter RoleFilter) ([]Role, error) {
+	accounts, err := compilePatterns(filter.Accounts)
+	if err != nil {
+		return nil, err
+	}
+	roleNames, err := compilePatterns(filter.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []Role
+	for _, role := range roles {
+		if len(accounts) > 0 && !matchAny(accounts, formatAccountID(role.AccountID), role.AccountName) {
+			continue
+		}
+		if len(roleNames) > 0 && !matchAny(roleNames, role.RoleName) {
+			continue
+		}
+		kept = append(kept, role)
+	}
+	return kept, nil
+}
+
+// compilePatterns compiles glob and /regexp/ patterns of a RoleFilter
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		expr := pattern
+		if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			expr = pattern[1 : len(pattern)-1]
+		} else {
+			expr = "(?i)^" + strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(pattern)) + "$"
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, &InvalidConfigError{Message: fmt.Sprintf("invalid filter pattern %q: %v", pattern, err)}
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// matchAny reports whether one of the patterns matches one of the values
+func matchAny(patterns []*regexp.Regexp, values ...string) bool {
+	for _, re := range patterns {
+		for _, value := range values {
+			if re.MatchString(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
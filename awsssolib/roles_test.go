@@ -0,0 +1,85 @@
+package awsssolib
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroupRolesByAccount(t *testing.T) {
+	roles := []Role{
+		{RoleName: "ReadOnly", AccountID: "222222222222", AccountName: "prod"},
+		{RoleName: "Admin", AccountID: "111111111111", AccountName: "Dev"},
+		{RoleName: "Admin", AccountID: "222222222222", AccountName: "prod"},
+		{RoleName: "Billing", AccountID: "3333-3333-3333", AccountName: "dev"},
+	}
+
+	groups := GroupRolesByAccount(roles)
+
+	var got []string
+	for _, group := range groups {
+		for _, role := range group.Roles {
+			got = append(got, group.Account.AccountID+"/"+role.RoleName)
+		}
+	}
+	expected := []string{
+		"111111111111/Admin",
+		"333333333333/Billing",
+		"222222222222/Admin",
+		"222222222222/ReadOnly",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	if len(GroupRolesByAccount(nil)) != 0 {
+		t.Error("Expected no groups without roles")
+	}
+}
+
+func TestFilterRoles(t *testing.T) {
+	roles := []Role{
+		{RoleName: "AdministratorAccess", AccountID: "111111111111", AccountName: "dev"},
+		{RoleName: "ReadOnly", AccountID: "111111111111", AccountName: "dev"},
+		{RoleName: "AdministratorAccess", AccountID: "222222222222", AccountName: "prod"},
+		{RoleName: "BillingAdminAccess", AccountID: "222222222222", AccountName: "prod"},
+		{RoleName: "ReadOnly", AccountID: "333333333333", AccountName: "prod-eu"},
+	}
+
+	tests := []struct {
+		name   string
+		filter RoleFilter
+		want   string
+	}{
+		{"no filter", RoleFilter{}, "111111111111/AdministratorAccess 111111111111/ReadOnly 222222222222/AdministratorAccess 222222222222/BillingAdminAccess 333333333333/ReadOnly"},
+		{"role glob", RoleFilter{Roles: []string{"*AdminAccess"}}, "222222222222/BillingAdminAccess"},
+		{"glob ignores case", RoleFilter{Roles: []string{"readonly"}}, "111111111111/ReadOnly 333333333333/ReadOnly"},
+		{"single character", RoleFilter{Accounts: []string{"pro?"}}, "222222222222/AdministratorAccess 222222222222/BillingAdminAccess"},
+		{"account ID", RoleFilter{Accounts: []string{"3333*"}}, "333333333333/ReadOnly"},
+		{"several patterns", RoleFilter{Accounts: []string{"dev", "prod-*"}, Roles: []string{"ReadOnly"}}, "111111111111/ReadOnly 333333333333/ReadOnly"},
+		{"regexp", RoleFilter{Roles: []string{"/^(Administrator|Billing)/"}}, "111111111111/AdministratorAccess 222222222222/AdministratorAccess 222222222222/BillingAdminAccess"},
+		{"no match", RoleFilter{Roles: []string{"Owner"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, err := FilterRoles(roles, tt.filter)
+			if err != nil {
+				t.Fatalf("FilterRoles failed: %v", err)
+			}
+			var got []string
+			for _, role := range filtered {
+				got = append(got, role.AccountID+"/"+role.RoleName)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, strings.Join(got, " "))
+			}
+		})
+	}
+
+	_, err := FilterRoles(roles, RoleFilter{Roles: []string{"/(/"}})
+	var configErr *InvalidConfigError
+	if !errors.As(err, &configErr) {
+		t.Errorf("Expected an InvalidConfigError for an invalid regexp, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package awsssolib
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// NewSignedRoundTripper returns an HTTP transport that signs requests with
+// Signature Version 4 for service in input.Region, using the SSO credentials
+// of the account and role. Credentials are cached and refreshed as they
+// expire, like those of GetAWSConfig. Requests are sent with
+// http.DefaultTransport.
+func NewSignedRoundTripper(ctx context.Context, input GetAWSConfigInput, service string) (http.RoundTripper, error) {
+	if service == "" {
+		return nil, &InvalidConfigError{Message: "service cannot be empty"}
+	}
+
+	cfg, err := GetAWSConfig(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signedRoundTripper{
+		base:        http.DefaultTransport,
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+		service:     service,
+		region:      cfg.Region,
+	}, nil
+}
+
+// signedRoundTripper signs requests with SigV4 before sending them with base
+type signedRoundTripper struct {
+	base        http.RoundTripper
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	service     string
+	region      string
+}
+
+// RoundTrip signs a copy of req and sends it
+func (t *signedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	// The body is read to hash it, so the signed copy gets its own
+	signed := req.Clone(ctx)
+	payloadHash := emptyPayloadHash
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+		signed.Body = io.NopCloser(bytes.NewReader(body))
+		signed.ContentLength = int64(len(body))
+	}
+
+	creds, err := t.credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve credentials: %w", err)
+	}
+
+	if err := t.signer.SignHTTP(ctx, creds, signed, payloadHash, t.service, t.region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	return t.base.RoundTrip(signed)
+}
@@ -0,0 +1,62 @@
+package awsssolib
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewSignedRoundTripper(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+
+	var authorization, securityToken, body string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		securityToken = r.Header.Get("X-Amz-Security-Token")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer api.Close()
+
+	ctx := context.Background()
+	input := GetAWSConfigInput{
+		StartURL:  startURL,
+		SSORegion: "us-east-1",
+		AccountID: "111111111111",
+		RoleName:  "Developer",
+		Region:    "eu-west-1",
+	}
+	if _, err := NewSignedRoundTripper(ctx, input, ""); err == nil {
+		t.Error("Expected an error without a service")
+	}
+
+	transport, err := NewSignedRoundTripper(ctx, input, "execute-api")
+	if err != nil {
+		t.Fatalf("NewSignedRoundTripper failed: %v", err)
+	}
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Post(api.URL+"/items", "application/json", strings.NewReader(`{"name":"item"}`))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKID-111111111111/") ||
+		!strings.Contains(authorization, "/eu-west-1/execute-api/aws4_request") {
+		t.Errorf("Expected a SigV4 authorization for the role, got %q", authorization)
+	}
+	if securityToken != "session" || body != `{"name":"item"}` {
+		t.Errorf("Expected the session token and the body to be sent, got %q and %q", securityToken, body)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected the credentials to be reused across requests, got %d retrievals", n)
+	}
+}
@@ -5,14 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sso"
 	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
-	"github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
 )
 
 const (
@@ -20,13 +22,55 @@ const (
 	defaultClientName = "aws-sso-lib-go"
 	defaultClientType = "public"
 
-	// Token expiry window (5 minutes)
+	// Token expiry window (5 minutes): cached tokens expiring sooner are not
+	// used, and Login replaces them unless LoginInput.ExpiryWindow is set
 	defaultExpiryWindow = 5 * time.Minute
+
+	// Cached client registrations expiring sooner than this are replaced
+	registrationExpiryWindow = time.Hour
+
+	// Client registrations typically expire after 90 days, assumed when the
+	// expiry is unknown
+	defaultRegistrationLifetime = 90 * 24 * time.Hour
+)
+
+// OAuth grant types of the OIDC CreateToken and CreateTokenWithIAM APIs
+const (
+	// grantTypeDeviceCode exchanges the device code of the device flow
+	grantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+	// grantTypeRefreshToken renews a token with its refresh token
+	grantTypeRefreshToken = "refresh_token"
+	// grantTypeAuthorizationCode exchanges the authorization code of the
+	// authorization code flow with PKCE
+	grantTypeAuthorizationCode = "authorization_code"
+	// grantTypeJWTBearer exchanges a JWT from a trusted token issuer
+	grantTypeJWTBearer = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+// DefaultLoginTimeout caps a login when LoginInput.Timeout is not set
+const DefaultLoginTimeout = 10 * time.Minute
+
+const (
+	// Polling interval when the device authorization doesn't specify one
+	defaultPollInterval = 5 * time.Second
+
+	// Added to the polling interval on every slow down response (RFC 8628)
+	slowDownIncrement = 5 * time.Second
+
+	// Upper bound of the random delay added to each polling interval
+	maxPollJitter = 500 * time.Millisecond
+
+	// Interval of the reminders printed while waiting for the user
+	waitingReminderInterval = 30 * time.Second
+
+	// Consecutive network or server errors tolerated while polling for a token
+	maxTransientPollErrors = 3
 )
 
 // GetAWSConfig returns an AWS SDK v2 config for the specified account and role
 func GetAWSConfig(ctx context.Context, input GetAWSConfigInput) (aws.Config, error) {
 	logger := getLogger(input.Config)
+	input.StartURL, input.SSORegion = contextInstance(ctx, input.StartURL, input.SSORegion)
 
 	logger.Debug("Starting AWS config retrieval",
 		slog.String("start_url", input.StartURL),
@@ -45,8 +89,8 @@ func GetAWSConfig(ctx context.Context, input GetAWSConfigInput) (aws.Config, err
 	// Format account ID (remove dashes if present)
 	accountID := formatAccountID(input.AccountID)
 
-	// Login if requested
-	if input.Login {
+	// Login if requested; without caches, the token comes from a new login
+	if input.Login || noCache(input.Config) {
 		logger.Info("Performing SSO login before config retrieval")
 		_, err := Login(ctx, LoginInput{
 			StartURL:  input.StartURL,
@@ -68,8 +112,10 @@ func GetAWSConfig(ctx context.Context, input GetAWSConfigInput) (aws.Config, err
 		accountID:       accountID,
 		roleName:        input.RoleName,
 		ssoCache:        input.SSOCache,
-		credentialCache: input.CredentialCache,
+		credentialCache: input.credentialCache(),
+		minValidity:     input.MinValidity,
 		config:          input.Config,
+		ssoSessions:     ssoSessionsUsing(input.StartURL),
 	}
 
 	// Create AWS config
@@ -92,6 +138,43 @@ func GetAWSConfig(ctx context.Context, input GetAWSConfigInput) (aws.Config, err
 
 // Login performs SSO login and returns the access token
 func Login(ctx context.Context, input LoginInput) (*LoginOutput, error) {
+	input.StartURL, input.SSORegion = contextInstance(ctx, input.StartURL, input.SSORegion)
+	ctx, span := startSpan(ctx, input.Config, "Login",
+		slog.String("start_url", input.StartURL),
+		slog.String("sso_region", input.SSORegion))
+	output, err := login(ctx, input)
+	if err == nil {
+		err = runPostLoginHook(ctx, input.Config, output)
+	}
+	span.End(err)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// runPostLoginHook calls the post-login hook of the config, if any. Hook
+// errors are only logged unless the config makes the hook strict.
+func runPostLoginHook(ctx context.Context, cfg *Config, output *LoginOutput) error {
+	if cfg == nil || cfg.PostLoginHook == nil {
+		return nil
+	}
+
+	err := cfg.PostLoginHook(ctx, output)
+	if err == nil {
+		return nil
+	}
+	if cfg.StrictPostLoginHook {
+		return fmt.Errorf("post-login hook failed: %w", err)
+	}
+	getLogger(cfg).Warn("Post-login hook failed",
+		slog.String("start_url", output.StartURL),
+		slog.Any("error", err))
+	return nil
+}
+
+// login performs the SSO login within the span started by Login
+func login(ctx context.Context, input LoginInput) (*LoginOutput, error) {
 	logger := getLogger(input.Config)
 
 	logger.Info("Starting SSO login",
@@ -106,10 +189,42 @@ func Login(ctx context.Context, input LoginInput) (*LoginOutput, error) {
 		return nil, err
 	}
 
+	// The AWS config is read once for the token files of the whole login
+	sessions := ssoSessionsUsing(input.StartURL)
+
+	// Without caches, only a token of this process is reused
+	if noCache(input.Config) && !input.ForceRefresh {
+		token, _ := getCachedToken(getSSOCacheDir(input.Config), input.StartURL, input.SSORegion, sessions)
+		if token == nil || !cachedByThisProcess(token) {
+			logger.Debug("Caches are disabled, logging in again")
+			input.ForceRefresh = true
+		}
+	}
+
+	// Use a token supplied by the caller instead of the device flow
+	if input.UseToken != nil {
+		if err := validateToken(input.StartURL, input.UseToken); err != nil {
+			logger.Error("Supplied SSO token is invalid", slog.Any("error", err))
+			return nil, err
+		}
+		logger.Info("Using supplied SSO token", slog.Time("expires_at", input.UseToken.ExpiresAt))
+		if err := putCachedToken(getSSOCacheDir(input.Config), input.StartURL, sessions, input.UseToken, input.Config); err != nil {
+			logger.Warn("Failed to cache SSO token", slog.Any("error", err))
+		}
+		getMetrics(input.Config).OnLogin(input.StartURL, LoginSourceSupplied)
+		return &LoginOutput{
+			Token:     input.UseToken,
+			ExpiresAt: input.UseToken.ExpiresAt,
+			StartURL:  input.StartURL,
+			Source:    LoginSourceSupplied,
+		}, nil
+	}
+
 	// Check for existing token if not forcing refresh
 	if !input.ForceRefresh {
 		logger.Debug("Checking for cached SSO token")
-		token, err := GetCachedToken(input.SSOCache, input.StartURL)
+		token, err := getCachedToken(getSSOCacheDir(input.Config), input.StartURL, input.SSORegion, sessions)
+		removeCorruptToken(err, logger)
 		if err == nil && token != nil {
 			// Check if token is still valid with expiry window
 			expiryWindow := input.ExpiryWindow
@@ -121,9 +236,13 @@ func Login(ctx context.Context, input LoginInput) (*LoginOutput, error) {
 				logger.Info("Using cached SSO token",
 					slog.Time("expires_at", token.ExpiresAt),
 					slog.Duration("expires_in", time.Until(token.ExpiresAt)))
+				getMetrics(input.Config).OnTokenCacheHit(input.StartURL)
+				getMetrics(input.Config).OnLogin(input.StartURL, LoginSourceCached)
 				return &LoginOutput{
 					Token:     token,
 					ExpiresAt: token.ExpiresAt,
+					StartURL:  input.StartURL,
+					Source:    LoginSourceCached,
 				}, nil
 			} else {
 				logger.Debug("Cached token is expired or will expire soon",
@@ -135,21 +254,68 @@ func Login(ctx context.Context, input LoginInput) (*LoginOutput, error) {
 		} else {
 			logger.Debug("No cached token found")
 		}
+		getMetrics(input.Config).OnTokenCacheMiss(input.StartURL)
+	}
+
+	// Cap the rest of the login, including the wait for the user
+	if input.Timeout <= 0 {
+		input.Timeout = DefaultLoginTimeout
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < input.Timeout {
+		input.Timeout = time.Until(deadline)
+	}
+	ctx, cancel := context.WithTimeout(ctx, input.Timeout)
+	defer cancel()
+
+	if input.Preflight {
+		if err := Preflight(ctx, input.StartURL, input.SSORegion, input.Config); err != nil {
+			logger.Error("Preflight check failed", slog.Any("error", err))
+			return nil, err
+		}
+	}
+
+	// Renew the session with the cached refresh token before prompting the user
+	if !input.ForceRefresh {
+		token, err := refreshCachedToken(ctx, input, sessions)
+		switch {
+		case err != nil:
+			logger.Info("Refreshing the SSO token failed, falling back to device authorization", slog.Any("error", err))
+		case token != nil:
+			logger.Info("SSO token refreshed without user interaction", slog.Time("expires_at", token.ExpiresAt))
+			if err := putCachedToken(getSSOCacheDir(input.Config), input.StartURL, sessions, token, input.Config); err != nil {
+				logger.Warn("Failed to cache SSO token", slog.Any("error", err))
+			}
+			getMetrics(input.Config).OnLogin(input.StartURL, LoginSourceRefreshed)
+			return &LoginOutput{
+				Token:     token,
+				ExpiresAt: token.ExpiresAt,
+				StartURL:  input.StartURL,
+				Source:    LoginSourceRefreshed,
+			}, nil
+		}
 	}
 
 	// Perform device authorization flow
 	logger.Info("Starting device authorization flow")
-	token, err := performDeviceAuthorization(ctx, input)
+	token, registered, err := performDeviceAuthorization(ctx, input, sessions)
+	if err == ErrLoginCancelled {
+		logger.Info("SSO login cancelled by the auth handler")
+		return nil, err
+	}
+	if err != nil && ctx.Err() != nil {
+		err = loginContextError(ctx, input.Timeout)
+	}
 	if err != nil {
 		logger.Error("Device authorization failed", slog.Any("error", err))
 		return nil, err
 	}
 	logger.Info("Device authorization completed successfully",
-		slog.Time("expires_at", token.ExpiresAt))
+		slog.Time("expires_at", token.ExpiresAt),
+		slog.Bool("registered_client", registered))
 
 	// Cache the token
 	logger.Debug("Caching SSO token")
-	if err := PutCachedToken(input.SSOCache, input.StartURL, token); err != nil {
+	if err := putCachedToken(getSSOCacheDir(input.Config), input.StartURL, sessions, token, input.Config); err != nil {
 		// Log error but don't fail - token caching is not critical
 		logger.Warn("Failed to cache SSO token", slog.Any("error", err))
 	} else {
@@ -157,27 +323,41 @@ func Login(ctx context.Context, input LoginInput) (*LoginOutput, error) {
 	}
 
 	logger.Info("SSO login completed successfully")
+	getMetrics(input.Config).OnLogin(input.StartURL, LoginSourceInteractive)
 	return &LoginOutput{
-		Token:     token,
-		ExpiresAt: token.ExpiresAt,
+		Token:      token,
+		ExpiresAt:  token.ExpiresAt,
+		StartURL:   input.StartURL,
+		Source:     LoginSourceInteractive,
+		Registered: registered,
 	}, nil
 }
 
 // Logout removes the cached SSO token
 func Logout(ctx context.Context, startURL, ssoRegion string, ssoCache Cache) error {
+	return LogoutWithConfig(ctx, startURL, ssoRegion, ssoCache, nil)
+}
+
+// LogoutWithConfig removes the cached SSO token, logging through cfg
+func LogoutWithConfig(ctx context.Context, startURL, ssoRegion string, ssoCache Cache, cfg *Config) error {
+	logger := getLogger(cfg)
+	startURL, ssoRegion = contextInstance(ctx, startURL, ssoRegion)
+
 	// Get the cached token
-	token, err := GetCachedToken(ssoCache, startURL)
+	sessions := ssoSessionsUsing(startURL)
+	token, err := getCachedToken(getSSOCacheDir(cfg), startURL, "", sessions)
 	if err != nil || token == nil {
+		logger.Debug("No cached SSO token, already logged out", slog.String("start_url", startURL))
 		return nil // Already logged out
 	}
 
 	// Create SSO client
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(ssoRegion))
+	sdkConfig, err := loadSDKConfig(ctx, ssoRegion, cfg)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	client := sso.NewFromConfig(cfg)
+	client := sso.NewFromConfig(sdkConfig)
 
 	// Call logout API
 	_, err = client.Logout(ctx, &sso.LogoutInput{
@@ -185,40 +365,54 @@ func Logout(ctx context.Context, startURL, ssoRegion string, ssoCache Cache) err
 	})
 	if err != nil {
 		// Continue with cache deletion even if API call fails
-		// Note: In production, this should use structured logging
-		_ = err // Silently ignore API errors to avoid exposing internal details
+		logger.Warn("SSO logout API call failed, removing cached token anyway",
+			slog.Any("error", err))
 	}
 
 	// Delete cached token
-	return DeleteCachedToken(ssoCache, startURL)
+	return deleteCachedToken(getSSOCacheDir(cfg), startURL, sessions)
 }
 
 // ListAvailableAccounts returns all accounts accessible through SSO
 func ListAvailableAccounts(ctx context.Context, input ListAccountsInput) ([]Account, error) {
+	input.StartURL, input.SSORegion = contextInstance(ctx, input.StartURL, input.SSORegion)
+	if err := validateConfigPageSize(input.Config); err != nil {
+		return nil, err
+	}
+
 	// Get token
-	token, err := getTokenForOperation(ctx, input.StartURL, input.SSORegion, input.Login, input.SSOCache)
+	token, err := getTokenForOperation(ctx, input.StartURL, input.SSORegion, input.Login, input.ForceRefresh, input.SSOCache, input.Config)
 	if err != nil {
 		return nil, err
 	}
 
 	// Create SSO client
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(input.SSORegion))
+	cfg, err := loadSDKConfig(ctx, input.SSORegion, input.Config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
 	client := sso.NewFromConfig(cfg)
 
-	// List accounts
+	return listAccounts(ctx, client, token, input.MaxResults, input.Config)
+}
+
+// listAccounts lists the accounts accessible with the token, up to
+// maxResults accounts when it is positive
+func listAccounts(ctx context.Context, client *sso.Client, token *Token, maxResults int, cfg *Config) ([]Account, error) {
 	var accounts []Account
 	var nextToken *string
 
 	for {
-		resp, err := client.ListAccounts(ctx, &sso.ListAccountsInput{
+		callCtx, span := startSpan(ctx, cfg, "ListAccounts")
+		resp, err := client.ListAccounts(callCtx, &sso.ListAccountsInput{
 			AccessToken: aws.String(token.AccessToken),
+			MaxResults:  pageSize(maxResults, len(accounts), cfg),
 			NextToken:   nextToken,
 		})
+		span.End(err)
 		if err != nil {
+			recordAPIError(cfg, "ListAccounts", err)
 			return nil, fmt.Errorf("failed to list accounts: %w", err)
 		}
 
@@ -228,27 +422,52 @@ func ListAvailableAccounts(ctx context.Context, input ListAccountsInput) ([]Acco
 				AccountName:  aws.ToString(acc.AccountName),
 				EmailAddress: aws.ToString(acc.EmailAddress),
 			})
+			if maxResults > 0 && len(accounts) == maxResults {
+				return accounts, nil
+			}
 		}
 
 		nextToken = resp.NextToken
 		if nextToken == nil {
-			break
+			return accounts, nil
 		}
 	}
+}
 
-	return accounts, nil
+// maxPageSize is the largest page the SSO list APIs return
+const maxPageSize = 100
+
+// pageSize returns the page size to request once found items are listed:
+// the configured page size, or maxPageSize, capped by the items maxResults
+// still allows
+func pageSize(maxResults, found int, cfg *Config) *int32 {
+	size := maxPageSize
+	if cfg != nil && cfg.PageSize > 0 {
+		size = cfg.PageSize
+	}
+	if maxResults > 0 {
+		size = min(size, maxResults-found)
+	}
+	return aws.Int32(int32(size))
 }
 
-// ListAvailableRoles returns all roles accessible through SSO
+// ListAvailableRoles returns all roles accessible through SSO. Accounts whose
+// roles can't be listed are skipped, unless they were given in AccountIDs, in
+// which case the error is returned.
 func ListAvailableRoles(ctx context.Context, input ListRolesInput) ([]Role, error) {
+	input.StartURL, input.SSORegion = contextInstance(ctx, input.StartURL, input.SSORegion)
+	if err := validateConfigPageSize(input.Config); err != nil {
+		return nil, err
+	}
+
 	// Get token
-	token, err := getTokenForOperation(ctx, input.StartURL, input.SSORegion, input.Login, input.SSOCache)
+	token, err := getTokenForOperation(ctx, input.StartURL, input.SSORegion, input.Login, input.ForceRefresh, input.SSOCache, input.Config)
 	if err != nil {
 		return nil, err
 	}
 
 	// Create SSO client
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(input.SSORegion))
+	cfg, err := loadSDKConfig(ctx, input.SSORegion, input.Config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
@@ -256,92 +475,265 @@ func ListAvailableRoles(ctx context.Context, input ListRolesInput) ([]Role, erro
 	client := sso.NewFromConfig(cfg)
 
 	// Get accounts to iterate over
-	var accountsToCheck []Account
-
-	if len(input.AccountIDs) > 0 {
-		// Use specified accounts
-		for _, id := range input.AccountIDs {
-			accountsToCheck = append(accountsToCheck, Account{
-				AccountID:   formatAccountID(id),
-				AccountName: "UNKNOWN",
-			})
-		}
-	} else {
-		// List all accounts
-		accounts, err := ListAvailableAccounts(ctx, ListAccountsInput{
-			StartURL:  input.StartURL,
-			SSORegion: input.SSORegion,
-			SSOCache:  input.SSOCache,
-		})
-		if err != nil {
-			return nil, err
+	accountsToCheck, err := accountsForRoles(ctx, client, token, input, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accounts whose roles can't be listed are skipped when sweeping all
+	// accounts, but fail the listing of accounts asked for by ID
+	explicit := len(input.AccountIDs) > 0
+
+	// With a limit, list roles one account at a time so the listing stops early
+	if input.MaxResults > 0 {
+		var roles []Role
+		for _, account := range accountsToCheck {
+			accountRoles, err := listAccountRoles(ctx, client, token, account, input.MaxResults-len(roles), input.Config)
+			if err != nil {
+				if explicit {
+					return nil, err
+				}
+				warnRolesNotListed(input.Config, account, err)
+			}
+			roles = append(roles, accountRoles...)
+			if len(roles) >= input.MaxResults {
+				break
+			}
 		}
-		accountsToCheck = accounts
+		return roles, nil
 	}
 
 	// List roles for each account
+	accountRoles, errs := listRolesConcurrently(ctx, client, token, accountsToCheck, input.MaxConcurrency, input.Config)
 	var roles []Role
+	for i := range accountRoles {
+		if errs[i] != nil && explicit {
+			return nil, errs[i]
+		}
+		roles = append(roles, accountRoles[i]...)
+	}
 
-	for _, account := range accountsToCheck {
-		var nextToken *string
+	return roles, nil
+}
 
-		for {
-			resp, err := client.ListAccountRoles(ctx, &sso.ListAccountRolesInput{
-				AccessToken: aws.String(token.AccessToken),
-				AccountId:   aws.String(account.AccountID),
-				NextToken:   nextToken,
-			})
-			if err != nil {
-				// Skip this account if we can't list roles
-				// Note: In production, this should use structured logging
+// ListAccessibleAccountRoles returns the accessible accounts, each with its
+// roles, in one sweep. Accounts are sorted by name, then ID, and roles by name.
+// Accounts whose roles can't be listed are returned without roles.
+func ListAccessibleAccountRoles(ctx context.Context, input ListRolesInput) ([]AccountRoles, error) {
+	input.StartURL, input.SSORegion = contextInstance(ctx, input.StartURL, input.SSORegion)
+	if err := validateConfigPageSize(input.Config); err != nil {
+		return nil, err
+	}
+
+	token, err := getTokenForOperation(ctx, input.StartURL, input.SSORegion, input.Login, input.ForceRefresh, input.SSOCache, input.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := loadSDKConfig(ctx, input.SSORegion, input.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	client := sso.NewFromConfig(cfg)
+
+	accounts, err := accountsForRoles(ctx, client, token, input, input.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, _ := listRolesConcurrently(ctx, client, token, accounts, input.MaxConcurrency, input.Config)
+	groups := make([]AccountRoles, len(accounts))
+	for i, account := range accounts {
+		groups[i] = AccountRoles{Account: account, Roles: roles[i]}
+	}
+
+	sortAccountRoles(groups)
+	return groups, nil
+}
+
+// ListAccountsForRole returns the accessible accounts in which the user can
+// assume roleName, sorted by name, then ID. Roles are listed concurrently as
+// for ListAccessibleAccountRoles. MaxResults limits the matching accounts
+// returned rather than the accounts searched.
+func ListAccountsForRole(ctx context.Context, input ListRolesInput, roleName string) ([]Account, error) {
+	if roleName == "" {
+		return nil, &InvalidConfigError{Message: "role name cannot be empty"}
+	}
+
+	maxResults := input.MaxResults
+	input.MaxResults = 0
+	groups, err := ListAccessibleAccountRoles(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []Account
+	for _, group := range groups {
+		for _, role := range group.Roles {
+			if role.RoleName == roleName || (input.IgnoreRoleCase && strings.EqualFold(role.RoleName, roleName)) {
+				accounts = append(accounts, group.Account)
 				break
 			}
+		}
+		if maxResults > 0 && len(accounts) >= maxResults {
+			break
+		}
+	}
+	return accounts, nil
+}
+
+// accountsForRoles returns the accounts whose roles a ListRolesInput asks for:
+// the given account IDs, or else all accessible accounts, up to maxResults
+// accounts when it is positive
+func accountsForRoles(ctx context.Context, client *sso.Client, token *Token, input ListRolesInput, maxResults int) ([]Account, error) {
+	if len(input.AccountIDs) == 0 {
+		return listAccounts(ctx, client, token, maxResults, input.Config)
+	}
+
+	ids := input.AccountIDs
+	if maxResults > 0 && len(ids) > maxResults {
+		ids = ids[:maxResults]
+	}
+
+	var accounts []Account
+	for _, id := range ids {
+		accounts = append(accounts, Account{
+			AccountID:   formatAccountID(id),
+			AccountName: "UNKNOWN",
+		})
+	}
+	return accounts, nil
+}
+
+// listRolesConcurrently lists the roles of several accounts, at most
+// maxConcurrency at a time, or DefaultMaxConcurrency when it isn't positive.
+// The roles and errors are indexed like accounts. Failures are logged and
+// leave the roles listed so far for the account.
+func listRolesConcurrently(ctx context.Context, client *sso.Client, token *Token, accounts []Account, maxConcurrency int, cfg *Config) ([][]Role, []error) {
+	roles := make([][]Role, len(accounts))
+	errs := make([]error, len(accounts))
 
-			for _, role := range resp.RoleList {
-				roles = append(roles, Role{
-					RoleName:    aws.ToString(role.RoleName),
-					AccountID:   account.AccountID,
-					AccountName: account.AccountName,
-				})
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+	getLogger(cfg).Debug("Listing account roles",
+		slog.Int("accounts", len(accounts)),
+		slog.Int("max_concurrency", maxConcurrency))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrency)
+	for i, account := range accounts {
+		wg.Add(1)
+		go func(i int, account Account) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			roles[i], errs[i] = listAccountRoles(ctx, client, token, account, 0, cfg)
+			if errs[i] != nil {
+				warnRolesNotListed(cfg, account, errs[i])
 			}
+		}(i, account)
+	}
 
-			nextToken = resp.NextToken
-			if nextToken == nil {
-				break
+	wg.Wait()
+	return roles, errs
+}
+
+// warnRolesNotListed logs an account skipped because its roles can't be listed
+func warnRolesNotListed(cfg *Config, account Account, err error) {
+	getLogger(cfg).Warn("Failed to list roles for account",
+		slog.String("account_id", account.AccountID),
+		slog.Any("error", err))
+}
+
+// listAccountRoles lists the roles of one account, up to maxResults roles
+// when it is positive. On failure, the roles listed so far are returned with
+// the error.
+func listAccountRoles(ctx context.Context, client *sso.Client, token *Token, account Account, maxResults int, cfg *Config) ([]Role, error) {
+	var roles []Role
+	var nextToken *string
+
+	for {
+		callCtx, span := startSpan(ctx, cfg, "ListAccountRoles",
+			slog.String("account_id", account.AccountID))
+		resp, err := client.ListAccountRoles(callCtx, &sso.ListAccountRolesInput{
+			AccessToken: aws.String(token.AccessToken),
+			AccountId:   aws.String(account.AccountID),
+			MaxResults:  pageSize(maxResults, len(roles), cfg),
+			NextToken:   nextToken,
+		})
+		span.End(err)
+		if err != nil {
+			recordAPIError(cfg, "ListAccountRoles", err)
+			return roles, fmt.Errorf("failed to list roles of account %s: %w", account.AccountID, err)
+		}
+
+		for _, role := range resp.RoleList {
+			roles = append(roles, Role{
+				RoleName:    aws.ToString(role.RoleName),
+				AccountID:   account.AccountID,
+				AccountName: account.AccountName,
+			})
+			if maxResults > 0 && len(roles) == maxResults {
+				return roles, nil
 			}
 		}
-	}
 
-	return roles, nil
+		nextToken = resp.NextToken
+		if nextToken == nil {
+			return roles, nil
+		}
+	}
 }
 
-// performDeviceAuthorization performs the SSO device authorization flow
-func performDeviceAuthorization(ctx context.Context, input LoginInput) (*Token, error) {
+// performDeviceAuthorization performs the SSO device authorization flow and
+// reports whether a new OIDC client was registered for it
+func performDeviceAuthorization(ctx context.Context, input LoginInput, sessions []string) (*Token, bool, error) {
 	// Create OIDC client
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(input.SSORegion))
+	cfg, err := loadSDKConfig(ctx, input.SSORegion, input.Config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load config: %w", err)
+		return nil, false, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	oidcClient := ssooidc.NewFromConfig(cfg)
+	oidcClient := newOIDCClient(cfg)
 
-	// Register client
-	registerResp, err := oidcClient.RegisterClient(ctx, &ssooidc.RegisterClientInput{
-		ClientName: aws.String(defaultClientName),
-		ClientType: aws.String(defaultClientType),
-	})
+	// Reuse the cached client registration unless it has expired
+	registration, err := getClientRegistration(ctx, oidcClient, input, sessions, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to register SSO client: %w", err)
+		return nil, false, err
 	}
 
 	// Start device authorization
-	authResp, err := oidcClient.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
-		ClientId:     registerResp.ClientId,
-		ClientSecret: registerResp.ClientSecret,
+	startInput := &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     aws.String(registration.ClientID),
+		ClientSecret: aws.String(registration.ClientSecret),
 		StartUrl:     aws.String(input.StartURL),
-	})
+	}
+	authResp, err := oidcClient.StartDeviceAuthorization(ctx, startInput)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start SSO device authorization: %w", err)
+		recordAPIError(input.Config, "StartDeviceAuthorization", err)
+	}
+
+	// The server may reject a cached registration before it expires, so
+	// re-register and retry once
+	if ClassifyError(err) == ErrorKindInvalidClient && registration.Cached {
+		getLogger(input.Config).Info("Cached SSO client registration was rejected, registering a new client")
+
+		registration, err = getClientRegistration(ctx, oidcClient, input, sessions, true)
+		if err != nil {
+			return nil, false, err
+		}
+		startInput.ClientId = aws.String(registration.ClientID)
+		startInput.ClientSecret = aws.String(registration.ClientSecret)
+		authResp, err = oidcClient.StartDeviceAuthorization(ctx, startInput)
+		if err != nil {
+			recordAPIError(input.Config, "StartDeviceAuthorization", err)
+		}
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to start SSO device authorization: %w", err)
 	}
 
 	// Call auth handler
@@ -361,92 +753,292 @@ func performDeviceAuthorization(ctx context.Context, input LoginInput) (*Token,
 		VerificationURIComplete: aws.ToString(authResp.VerificationUriComplete),
 		ExpiresAt:               expiresAt,
 	})
+	if errors.Is(err, ErrLoginCancelled) {
+		return nil, false, ErrLoginCancelled
+	}
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	// Poll for token with timeout
-	interval := time.Duration(authResp.Interval) * time.Second
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	// Remind the user at a terminal that the default handler's login is
+	// waiting for them; custom handlers own their output
+	stopReminder := func() {}
+	if input.UserAuthHandler == nil && !input.DisableBrowser && !input.Quiet && stderrIsTerminal() {
+		stopReminder = startWaitingReminder(os.Stderr, waitingReminderInterval)
+	}
 
-	// Set a reasonable timeout for the entire authorization process (10 minutes)
-	timeout := 10 * time.Minute
-	if deadline, ok := ctx.Deadline(); ok {
-		// Use context deadline if it's sooner than our timeout
-		if time.Until(deadline) < timeout {
-			timeout = time.Until(deadline)
+	// Poll for token until the login deadline
+	tokenResp, err := pollForToken(ctx, oidcClient, &ssooidc.CreateTokenInput{
+		ClientId:     aws.String(registration.ClientID),
+		ClientSecret: aws.String(registration.ClientSecret),
+		DeviceCode:   authResp.DeviceCode,
+		GrantType:    aws.String(grantTypeDeviceCode),
+	}, time.Duration(authResp.Interval)*time.Second, input.Timeout)
+	stopReminder()
+	if err != nil {
+		if ctx.Err() == nil {
+			recordAPIError(input.Config, "CreateToken", err)
 		}
+		return nil, false, err
 	}
 
-	authCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	// Success! Create token object
+	token := &Token{
+		AccessToken:           aws.ToString(tokenResp.AccessToken),
+		ExpiresAt:             time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+		RefreshToken:          aws.ToString(tokenResp.RefreshToken),
+		ClientID:              registration.ClientID,
+		ClientSecret:          registration.ClientSecret,
+		RegistrationTime:      time.Now(),
+		RegistrationExpiresAt: registration.ExpiresAt,
+		Region:                input.SSORegion,
+		StartURL:              input.StartURL,
+	}
+
+	return token, !registration.Cached, nil
+}
+
+// refreshCachedToken renews the cached token for the start URL with its
+// refresh token and client registration. It returns nil without an error when
+// there is nothing to refresh with.
+func refreshCachedToken(ctx context.Context, input LoginInput, sessions []string) (*Token, error) {
+	logger := getLogger(input.Config)
+
+	cached, err := readCachedToken(getSSOCacheDir(input.Config), input.StartURL, sessions)
+	if err != nil || cached == nil || cached.RefreshToken == "" {
+		logger.Debug("No cached refresh token")
+		return nil, nil
+	}
+	if cached.ClientID == "" || cached.ClientSecret == "" || cached.Region != input.SSORegion ||
+		!time.Now().Before(cached.RegistrationExpiresAt) {
+		logger.Debug("No valid client registration for the cached refresh token")
+		return nil, nil
+	}
+
+	cfg, err := loadSDKConfig(ctx, input.SSORegion, input.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	logger.Debug("Refreshing SSO token")
+	resp, err := newOIDCClient(cfg).CreateToken(ctx, &ssooidc.CreateTokenInput{
+		ClientId:     aws.String(cached.ClientID),
+		ClientSecret: aws.String(cached.ClientSecret),
+		GrantType:    aws.String(grantTypeRefreshToken),
+		RefreshToken: aws.String(cached.RefreshToken),
+	})
+	if err != nil {
+		recordAPIError(input.Config, "CreateToken", err)
+		return nil, err
+	}
 
+	// The refresh token may be rotated
+	refreshToken := aws.ToString(resp.RefreshToken)
+	if refreshToken == "" {
+		refreshToken = cached.RefreshToken
+	}
+
+	return &Token{
+		AccessToken:           aws.ToString(resp.AccessToken),
+		ExpiresAt:             time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second),
+		RefreshToken:          refreshToken,
+		ClientID:              cached.ClientID,
+		ClientSecret:          cached.ClientSecret,
+		RegistrationTime:      cached.RegistrationTime,
+		RegistrationExpiresAt: cached.RegistrationExpiresAt,
+		Region:                input.SSORegion,
+		StartURL:              input.StartURL,
+		Scopes:                cached.Scopes,
+	}, nil
+}
+
+// oidcAPI is the part of the OIDC client used to log in
+type oidcAPI interface {
+	clientRegistrar
+	tokenCreator
+	StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error)
+}
+
+// newOIDCClient creates the OIDC client for the device flow; tests replace it
+var newOIDCClient = func(cfg aws.Config) oidcAPI {
+	return ssooidc.NewFromConfig(cfg)
+}
+
+// tokenCreator exchanges a device code for an access token
+type tokenCreator interface {
+	CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error)
+}
+
+// pollForToken polls CreateToken until the user approves the device
+// authorization. The first attempt is made right away, since users often
+// approve quickly; later attempts wait the polling interval plus a small random
+// jitter so that many clients don't poll in lockstep. A slow down response
+// increases the interval as described in RFC 8628. Network and server errors
+// are retried at the polling interval, up to maxTransientPollErrors in a row.
+func pollForToken(ctx context.Context, client tokenCreator, input *ssooidc.CreateTokenInput, interval, timeout time.Duration) (*ssooidc.CreateTokenOutput, error) {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	var wait time.Duration
+	transientErrors := 0
 	for {
-		select {
-		case <-authCtx.Done():
-			if authCtx.Err() == context.DeadlineExceeded {
-				return nil, fmt.Errorf("SSO authorization timed out after %v", timeout)
-			}
-			return nil, authCtx.Err()
-		case <-ticker.C:
-			tokenResp, err := oidcClient.CreateToken(authCtx, &ssooidc.CreateTokenInput{
-				ClientId:     registerResp.ClientId,
-				ClientSecret: registerResp.ClientSecret,
-				DeviceCode:   authResp.DeviceCode,
-				GrantType:    aws.String("urn:ietf:params:oauth:grant-type:device_code"),
-			})
+		if !sleepContext(ctx, wait) {
+			return nil, loginContextError(ctx, timeout)
+		}
 
-			if err != nil {
-				// Check if it's an authorization pending error
-				var authPendingErr *types.AuthorizationPendingException
-				var slowDownErr *types.SlowDownException
-
-				if errors.As(err, &authPendingErr) {
-					// Authorization is still pending, continue polling silently
-					continue
-				} else if errors.As(err, &slowDownErr) {
-					// Slow down the polling as requested by the server
-					time.Sleep(time.Duration(authResp.Interval) * time.Second)
-					continue
-				} else if strings.Contains(err.Error(), "AuthorizationPendingException") {
-					// Fallback string check for older SDK versions
-					continue
-				}
-				return nil, fmt.Errorf("failed to obtain access token: %w", err)
-			}
+		tokenResp, err := client.CreateToken(ctx, input)
+		if err == nil {
+			return tokenResp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, loginContextError(ctx, timeout)
+		}
 
-			// Success! Create token object
-			token := &Token{
-				AccessToken:      aws.ToString(tokenResp.AccessToken),
-				ExpiresAt:        time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
-				RefreshToken:     aws.ToString(tokenResp.RefreshToken),
-				ClientID:         aws.ToString(registerResp.ClientId),
-				ClientSecret:     aws.ToString(registerResp.ClientSecret),
-				RegistrationTime: time.Now(),
-				Region:           input.SSORegion,
-				StartURL:         input.StartURL,
+		switch ClassifyError(err) {
+		case ErrorKindAuthPending:
+			// Authorization is still pending, continue polling silently
+			transientErrors = 0
+		case ErrorKindSlowDown:
+			// Slow down the polling as requested by the server
+			interval += slowDownIncrement
+			transientErrors = 0
+		case ErrorKindNetworkError, ErrorKindServerError:
+			// The authorization is still valid, so a failed poll is retried
+			transientErrors++
+			if transientErrors > maxTransientPollErrors {
+				return nil, fmt.Errorf("failed to obtain access token after %d consecutive transient errors: %w", transientErrors, err)
 			}
+		case ErrorKindAccessDenied:
+			// The user denied the request, waiting longer won't help
+			return nil, fmt.Errorf("failed to obtain access token: the device authorization was denied, log in again to retry: %w", err)
+		default:
+			return nil, fmt.Errorf("failed to obtain access token: %w", err)
+		}
+		wait = interval + rand.N(maxPollJitter)
+	}
+}
 
-			return token, nil
+// sleepContext waits for d and reports whether ctx is still active
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// loginContextError explains why the device flow stopped waiting for the user
+func loginContextError(ctx context.Context, timeout time.Duration) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("SSO login timed out after %v without the device authorization being approved: %w", timeout.Round(time.Second), ctx.Err())
+	}
+	return ctx.Err()
+}
+
+// clientRegistration is an OIDC client registration used for the device flow
+type clientRegistration struct {
+	ClientID     string
+	ClientSecret string
+	ExpiresAt    time.Time
+	// Cached is true when the registration was read from the token cache
+	Cached bool
+}
+
+// clientRegistrar registers OIDC clients
+type clientRegistrar interface {
+	RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error)
+}
+
+// getClientRegistration returns the client registration cached with the token
+// for the start URL, registering a new client when there is none, it was made
+// in another region, or it expires within registrationExpiryWindow
+func getClientRegistration(ctx context.Context, client clientRegistrar, input LoginInput, sessions []string, forceNew bool) (*clientRegistration, error) {
+	logger := getLogger(input.Config)
+
+	if !forceNew {
+		cached, err := readCachedToken(getSSOCacheDir(input.Config), input.StartURL, sessions)
+		if err == nil && cached != nil && cached.ClientID != "" && cached.ClientSecret != "" &&
+			cached.Region == input.SSORegion &&
+			time.Until(cached.RegistrationExpiresAt) > registrationExpiryWindow {
+			logger.Debug("Reusing cached SSO client registration",
+				slog.Time("registration_expires_at", cached.RegistrationExpiresAt))
+			return &clientRegistration{
+				ClientID:     cached.ClientID,
+				ClientSecret: cached.ClientSecret,
+				ExpiresAt:    cached.RegistrationExpiresAt,
+				Cached:       true,
+			}, nil
 		}
 	}
+
+	logger.Debug("Registering SSO client")
+	resp, err := client.RegisterClient(ctx, &ssooidc.RegisterClientInput{
+		ClientName: aws.String(input.clientName()),
+		ClientType: aws.String(defaultClientType),
+	})
+	if err != nil {
+		recordAPIError(input.Config, "RegisterClient", err)
+		return nil, fmt.Errorf("failed to register SSO client: %w", err)
+	}
+
+	return &clientRegistration{
+		ClientID:     aws.ToString(resp.ClientId),
+		ClientSecret: aws.ToString(resp.ClientSecret),
+		ExpiresAt:    registrationExpiry(resp.ClientSecretExpiresAt, time.Now()),
+	}, nil
+}
+
+// registrationExpiry returns when a client registered at now expires, from the
+// ClientSecretExpiresAt of RegisterClient. The value is 0 when unset, which
+// means unknown rather than 1970, so the default lifetime is assumed.
+func registrationExpiry(clientSecretExpiresAt int64, now time.Time) time.Time {
+	if clientSecretExpiresAt <= 0 {
+		return now.Add(defaultRegistrationLifetime)
+	}
+	return time.Unix(clientSecretExpiresAt, 0)
+}
+
+// clientName returns the OIDC client name for the login: the input's, then
+// the config's, then defaultClientName
+func (input LoginInput) clientName() string {
+	if input.ClientName != "" {
+		return input.ClientName
+	}
+	if input.Config != nil && input.Config.ClientName != "" {
+		return input.Config.ClientName
+	}
+	return defaultClientName
 }
 
-// getTokenForOperation gets a token for an operation, optionally logging in
-func getTokenForOperation(ctx context.Context, startURL, ssoRegion string, login bool, ssoCache Cache) (*Token, error) {
-	// Try to get cached token
-	token, err := GetCachedToken(ssoCache, startURL)
-	if err == nil && token != nil {
-		return token, nil
+// getTokenForOperation gets a token for an operation, optionally logging in.
+// forceRefresh skips the cached token and always logs in.
+func getTokenForOperation(ctx context.Context, startURL, ssoRegion string, login, forceRefresh bool, ssoCache Cache, cfg *Config) (*Token, error) {
+	// Try to get cached token; without caches, only one of this process
+	if !forceRefresh {
+		token, err := getCachedToken(getSSOCacheDir(cfg), startURL, ssoRegion, ssoSessionsUsing(startURL))
+		if err == nil && token != nil && (!noCache(cfg) || cachedByThisProcess(token)) {
+			getMetrics(cfg).OnTokenCacheHit(startURL)
+			return token, nil
+		}
 	}
 
-	// If login is enabled, try to log in
-	if login {
+	// If login is enabled, try to log in; Login records the cache miss
+	if login || forceRefresh || noCache(cfg) {
 		output, err := Login(ctx, LoginInput{
-			StartURL:  startURL,
-			SSORegion: ssoRegion,
-			SSOCache:  ssoCache,
+			StartURL:     startURL,
+			SSORegion:    ssoRegion,
+			SSOCache:     ssoCache,
+			ForceRefresh: forceRefresh,
+			Config:       cfg,
 		})
 		if err != nil {
 			return nil, err
@@ -455,6 +1047,7 @@ func getTokenForOperation(ctx context.Context, startURL, ssoRegion string, login
 	}
 
 	// No token and login not enabled
+	getMetrics(cfg).OnTokenCacheMiss(startURL)
 	return nil, &AuthenticationNeededError{}
 }
 
@@ -477,7 +1070,12 @@ type ssoCredentialProvider struct {
 	roleName        string
 	ssoCache        Cache
 	credentialCache Cache
+	minValidity     time.Duration
 	config          *Config
+	// ssoSessions are the sso-sessions using the start URL, looked up once
+	ssoSessions []string
+	// factory shares its token and SSO client when set
+	factory *CredentialFactory
 }
 
 // Retrieve fetches credentials
@@ -501,11 +1099,12 @@ func (p *ssoCredentialProvider) Retrieve(ctx context.Context) (aws.Credentials,
 		retrieveCtx = ctx
 	}
 	// Check credential cache first
-	cacheKey := generateCredentialCacheKey(p.startURL, p.accountID, p.roleName)
+	cacheKey := CredentialCacheKey(p.startURL, p.accountID, p.roleName)
 	if p.credentialCache != nil {
 		logger.Debug("Checking credential cache")
-		cached, err := GetCachedCredentials(p.credentialCache, cacheKey)
+		cached, err := getCachedCredentials(p.credentialCache, cacheKey, p.minValidity)
 		if err == nil && cached != nil {
+			getMetrics(p.config).OnCredentialCacheHit(p.accountID, p.roleName)
 			logger.Info("Using cached credentials",
 				slog.Time("expires_at", cached.Expiration),
 				slog.Duration("expires_in", time.Until(cached.Expiration)))
@@ -515,76 +1114,138 @@ func (p *ssoCredentialProvider) Retrieve(ctx context.Context) (aws.Credentials,
 				SessionToken:    cached.SessionToken,
 				CanExpire:       true,
 				Expires:         cached.Expiration,
-				Source:          "SSO",
+				Source:          credentialSource(p.accountID, p.roleName),
 			}, nil
 		} else if err != nil {
 			logger.Debug("Failed to retrieve cached credentials", slog.Any("error", err))
 		} else {
 			logger.Debug("No cached credentials found")
 		}
+		getMetrics(p.config).OnCredentialCacheMiss(p.accountID, p.roleName)
 	}
 
-	// Get SSO token
-	logger.Debug("Retrieving SSO token")
-	token, err := GetCachedToken(p.ssoCache, p.startURL)
-	if err != nil || token == nil {
-		logger.Error("SSO token not available", slog.Any("error", err))
-		return aws.Credentials{}, &AuthenticationNeededError{}
+	// Concurrent retrievals of the same credentials from the same token cache
+	// share one API call
+	flightKey := getSSOCacheDir(p.config) + "\x00" + cacheKey
+	creds, err := credentialFlights.do(retrieveCtx, flightKey, func(ctx context.Context) (*CachedCredentials, error) {
+		return p.fetch(ctx, cacheKey)
+	})
+	if err != nil {
+		return aws.Credentials{}, err
 	}
-	logger.Debug("SSO token retrieved successfully")
 
-	// Create SSO client
-	logger.Debug("Creating SSO client")
-	cfg, err := config.LoadDefaultConfig(retrieveCtx, config.WithRegion(p.ssoRegion))
+	logger.Debug("Credential retrieval completed successfully")
+	return aws.Credentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		CanExpire:       true,
+		Expires:         creds.Expiration,
+		Source:          credentialSource(p.accountID, p.roleName),
+	}, nil
+}
+
+// fetch gets role credentials from the SSO API and caches them
+func (p *ssoCredentialProvider) fetch(ctx context.Context, cacheKey string) (*CachedCredentials, error) {
+	logger := getLogger(p.config)
+
+	token, client, err := p.session(ctx)
 	if err != nil {
-		logger.Error("Failed to load AWS config for SSO client", slog.Any("error", err))
-		return aws.Credentials{}, fmt.Errorf("failed to load config: %w", err)
+		return nil, err
 	}
 
-	client := sso.NewFromConfig(cfg)
-
 	// Get role credentials
 	logger.Debug("Calling SSO GetRoleCredentials API")
-	resp, err := client.GetRoleCredentials(retrieveCtx, &sso.GetRoleCredentialsInput{
-		AccessToken: aws.String(token.AccessToken),
-		AccountId:   aws.String(p.accountID),
-		RoleName:    aws.String(p.roleName),
-	})
+	creds, err := getRoleCredentials(ctx, client, token.AccessToken, p.accountID, p.roleName, p.config)
 	if err != nil {
+		recordAPIError(p.config, "GetRoleCredentials", err)
 		logger.Error("Failed to get role credentials from SSO", slog.Any("error", err))
-		return aws.Credentials{}, fmt.Errorf("failed to get role credentials: %w", err)
+		switch ClassifyError(err) {
+		case ErrorKindUnauthorized, ErrorKindExpiredToken:
+			return nil, &AuthenticationNeededError{Message: "SSO token was rejected, login required"}
+		}
+		return nil, fmt.Errorf("failed to get role credentials: %w", err)
 	}
 
-	creds := resp.RoleCredentials
-	expiration := time.Unix(creds.Expiration/1000, 0)
-
 	logger.Info("Role credentials retrieved successfully",
-		slog.Time("expires_at", expiration),
-		slog.Duration("expires_in", time.Until(expiration)))
+		slog.Time("expires_at", creds.Expiration),
+		slog.Duration("expires_in", time.Until(creds.Expiration)))
 
 	// Cache credentials
 	if p.credentialCache != nil {
 		logger.Debug("Caching role credentials")
-		cachedCreds := &CachedCredentials{
-			AccessKeyID:     aws.ToString(creds.AccessKeyId),
-			SecretAccessKey: aws.ToString(creds.SecretAccessKey),
-			SessionToken:    aws.ToString(creds.SessionToken),
-			Expiration:      expiration,
-		}
-		if err := PutCachedCredentials(p.credentialCache, cacheKey, cachedCreds); err != nil {
+		if err := PutCachedCredentials(p.credentialCache, cacheKey, creds); err != nil {
 			logger.Warn("Failed to cache credentials", slog.Any("error", err))
 		} else {
 			logger.Debug("Credentials cached successfully")
 		}
 	}
 
-	logger.Debug("Credential retrieval completed successfully")
-	return aws.Credentials{
+	return creds, nil
+}
+
+// credentialSource labels credentials with the account and role they were
+// issued for, so their provenance shows up in SDK logs
+func credentialSource(accountID, roleName string) string {
+	return "aws-sso-lib:" + accountID + "/" + roleName
+}
+
+// session returns the SSO token and client for a retrieval, shared with the
+// factory when the provider has one
+func (p *ssoCredentialProvider) session(ctx context.Context) (*Token, *sso.Client, error) {
+	logger := getLogger(p.config)
+
+	if p.factory != nil {
+		token, err := p.factory.currentToken()
+		if err != nil {
+			logger.Error("SSO token not available", slog.Any("error", err))
+			return nil, nil, err
+		}
+		return token, p.factory.client, nil
+	}
+
+	// Get SSO token
+	logger.Debug("Retrieving SSO token")
+	token, err := getCachedToken(getSSOCacheDir(p.config), p.startURL, p.ssoRegion, p.ssoSessions)
+	if err != nil || token == nil {
+		getMetrics(p.config).OnTokenCacheMiss(p.startURL)
+		logger.Error("SSO token not available", slog.Any("error", err))
+		return nil, nil, &AuthenticationNeededError{}
+	}
+	getMetrics(p.config).OnTokenCacheHit(p.startURL)
+	logger.Debug("SSO token retrieved successfully")
+
+	// Create SSO client
+	logger.Debug("Creating SSO client")
+	cfg, err := loadSDKConfig(ctx, p.ssoRegion, p.config)
+	if err != nil {
+		logger.Error("Failed to load AWS config for SSO client", slog.Any("error", err))
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return token, sso.NewFromConfig(cfg), nil
+}
+
+// getRoleCredentials calls the SSO GetRoleCredentials API with the given access token
+func getRoleCredentials(ctx context.Context, client *sso.Client, accessToken, accountID, roleName string, cfg *Config) (*CachedCredentials, error) {
+	ctx, span := startSpan(ctx, cfg, "GetRoleCredentials",
+		slog.String("account_id", accountID),
+		slog.String("role_name", roleName))
+	resp, err := client.GetRoleCredentials(ctx, &sso.GetRoleCredentialsInput{
+		AccessToken: aws.String(accessToken),
+		AccountId:   aws.String(accountID),
+		RoleName:    aws.String(roleName),
+	})
+	span.End(err)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := resp.RoleCredentials
+	return &CachedCredentials{
 		AccessKeyID:     aws.ToString(creds.AccessKeyId),
 		SecretAccessKey: aws.ToString(creds.SecretAccessKey),
 		SessionToken:    aws.ToString(creds.SessionToken),
-		CanExpire:       true,
-		Expires:         expiration,
-		Source:          "SSO",
+		Expiration:      time.Unix(creds.Expiration/1000, 0),
 	}, nil
 }
@@ -0,0 +1,926 @@
+package awsssolib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	ssooidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// stubRegistrar counts client registrations and records their names. With
+// noExpiry it leaves ClientSecretExpiresAt unset.
+type stubRegistrar struct {
+	calls    int
+	names    []string
+	noExpiry bool
+}
+
+func (s *stubRegistrar) RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error) {
+	s.calls++
+	s.names = append(s.names, aws.ToString(params.ClientName))
+	output := &ssooidc.RegisterClientOutput{
+		ClientId:     aws.String("new-client"),
+		ClientSecret: aws.String("new-secret"),
+	}
+	if !s.noExpiry {
+		output.ClientSecretExpiresAt = time.Now().Add(90 * 24 * time.Hour).Unix()
+	}
+	return output, nil
+}
+
+func TestGetClientRegistrationUnknownExpiry(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	input := LoginInput{StartURL: "https://test.awsapps.com/start", SSORegion: "us-east-1"}
+
+	registration, err := getClientRegistration(context.Background(), &stubRegistrar{noExpiry: true}, input, nil, true)
+	if err != nil {
+		t.Fatalf("getClientRegistration failed: %v", err)
+	}
+	if time.Until(registration.ExpiresAt) < 89*24*time.Hour {
+		t.Errorf("Expected an unset expiry to default to 90 days, got %s", registration.ExpiresAt)
+	}
+}
+
+func TestGetClientRegistration(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	startURL := "https://test.awsapps.com/start"
+	input := LoginInput{StartURL: startURL, SSORegion: "us-east-1"}
+	ctx := context.Background()
+
+	putToken := func(registrationExpiresAt time.Time) {
+		t.Helper()
+		err := PutCachedToken(nil, startURL, &Token{
+			AccessToken:           "expired-token",
+			ExpiresAt:             time.Now().Add(-time.Hour),
+			RefreshToken:          "refresh-token",
+			ClientID:              "cached-client",
+			ClientSecret:          "cached-secret",
+			RegistrationExpiresAt: registrationExpiresAt,
+			Region:                "us-east-1",
+		})
+		if err != nil {
+			t.Fatalf("PutCachedToken failed: %v", err)
+		}
+	}
+
+	// A valid registration is reused even though the token has expired
+	putToken(time.Now().Add(30 * 24 * time.Hour))
+
+	cached, err := readCachedToken(SSOCacheDir(), startURL, nil)
+	if err != nil || cached == nil {
+		t.Fatalf("readCachedToken failed: %v", err)
+	}
+	if cached.RefreshToken != "refresh-token" {
+		t.Errorf("Expected refresh token to be cached, got %q", cached.RefreshToken)
+	}
+
+	registrar := &stubRegistrar{}
+	registration, err := getClientRegistration(ctx, registrar, input, nil, false)
+	if err != nil {
+		t.Fatalf("getClientRegistration failed: %v", err)
+	}
+	if registration.ClientID != "cached-client" || !registration.Cached || registrar.calls != 0 {
+		t.Errorf("Expected cached registration to be reused, got %+v after %d calls", registration, registrar.calls)
+	}
+
+	// A registration in another region can't be used
+	registration, err = getClientRegistration(ctx, registrar, LoginInput{StartURL: startURL, SSORegion: "eu-west-1"}, nil, false)
+	if err != nil {
+		t.Fatalf("getClientRegistration failed: %v", err)
+	}
+	if registration.ClientID != "new-client" || registrar.calls != 1 {
+		t.Errorf("Expected a new registration for another region, got %+v", registration)
+	}
+
+	// An expired registration is replaced
+	putToken(time.Now().Add(-time.Hour))
+
+	registration, err = getClientRegistration(ctx, registrar, input, nil, false)
+	if err != nil {
+		t.Fatalf("getClientRegistration failed: %v", err)
+	}
+	if registration.ClientID != "new-client" || registration.Cached || registrar.calls != 2 {
+		t.Errorf("Expected a new registration, got %+v", registration)
+	}
+	if time.Until(registration.ExpiresAt) < 89*24*time.Hour {
+		t.Errorf("Expected registration expiry from the response, got %s", registration.ExpiresAt)
+	}
+
+	// forceNew skips a valid cached registration
+	putToken(time.Now().Add(30 * 24 * time.Hour))
+	if registration, _ := getClientRegistration(ctx, registrar, input, nil, true); registration.Cached {
+		t.Error("Expected forceNew to register a new client")
+	}
+}
+
+func TestLogoutWithConfigUsesLogger(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var buf bytes.Buffer
+	cfg := NewConfig(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), slog.LevelDebug)
+
+	if err := LogoutWithConfig(context.Background(), "https://test.awsapps.com/start", "us-east-1", nil, cfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "already logged out") {
+		t.Errorf("Expected logout to log through the configured logger, got %q", buf.String())
+	}
+}
+
+func TestLoginTimeout(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	start := time.Now()
+	_, err := Login(context.Background(), LoginInput{
+		StartURL:        "https://test.awsapps.com/start",
+		SSORegion:       "us-east-1",
+		Timeout:         time.Nanosecond,
+		UserAuthHandler: func(ctx context.Context, params AuthHandlerParams) error { return nil },
+	})
+	if err == nil {
+		t.Fatal("Expected the login to time out")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a clear timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the login to stop promptly, took %v", elapsed)
+	}
+}
+
+func TestLoginContextError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := loginContextError(ctx, time.Minute); !errors.Is(err, context.Canceled) || strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected cancellation to be reported as is, got %v", err)
+	}
+}
+
+// stubTokenCreator returns the queued errors before issuing a token
+type stubTokenCreator struct {
+	errs  []error
+	calls []time.Time
+}
+
+func (s *stubTokenCreator) CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error) {
+	s.calls = append(s.calls, time.Now())
+	if len(s.calls) <= len(s.errs) {
+		return nil, s.errs[len(s.calls)-1]
+	}
+	return &ssooidc.CreateTokenOutput{AccessToken: aws.String("token")}, nil
+}
+
+func TestGetClientRegistrationClientName(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	input := LoginInput{StartURL: "https://test.awsapps.com/start", SSORegion: "us-east-1"}
+	registrar := &stubRegistrar{}
+	ctx := context.Background()
+
+	if _, err := getClientRegistration(ctx, registrar, input, nil, true); err != nil {
+		t.Fatalf("getClientRegistration failed: %v", err)
+	}
+
+	input.Config = &Config{ClientName: "acme-deploy"}
+	if _, err := getClientRegistration(ctx, registrar, input, nil, true); err != nil {
+		t.Fatalf("getClientRegistration failed: %v", err)
+	}
+
+	input.ClientName = "acme-deploy-ci"
+	if _, err := getClientRegistration(ctx, registrar, input, nil, true); err != nil {
+		t.Fatalf("getClientRegistration failed: %v", err)
+	}
+
+	expected := []string{defaultClientName, "acme-deploy", "acme-deploy-ci"}
+	if strings.Join(registrar.names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected client names %v, got %v", expected, registrar.names)
+	}
+}
+
+func TestPollForTokenFirstAttemptIsImmediate(t *testing.T) {
+	client := &stubTokenCreator{}
+
+	start := time.Now()
+	resp, err := pollForToken(context.Background(), client, &ssooidc.CreateTokenInput{}, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatalf("pollForToken failed: %v", err)
+	}
+	if aws.ToString(resp.AccessToken) != "token" {
+		t.Errorf("Expected the token, got %+v", resp)
+	}
+	if elapsed := client.calls[0].Sub(start); elapsed > time.Second {
+		t.Errorf("Expected the first poll right away, waited %v", elapsed)
+	}
+}
+
+func TestPollForTokenPending(t *testing.T) {
+	client := &stubTokenCreator{errs: []error{&ssooidctypes.AuthorizationPendingException{}}}
+
+	if _, err := pollForToken(context.Background(), client, &ssooidc.CreateTokenInput{}, 10*time.Millisecond, time.Minute); err != nil {
+		t.Fatalf("pollForToken failed: %v", err)
+	}
+	if len(client.calls) != 2 {
+		t.Fatalf("Expected 2 polls, got %d", len(client.calls))
+	}
+	if gap := client.calls[1].Sub(client.calls[0]); gap < 10*time.Millisecond || gap > 10*time.Millisecond+maxPollJitter+time.Second {
+		t.Errorf("Expected the second poll after the interval plus jitter, waited %v", gap)
+	}
+}
+
+func TestPollForTokenErrors(t *testing.T) {
+	client := &stubTokenCreator{errs: []error{&ssooidctypes.AccessDeniedException{}}}
+	if _, err := pollForToken(context.Background(), client, &ssooidc.CreateTokenInput{}, time.Millisecond, time.Minute); err == nil || !strings.Contains(err.Error(), "failed to obtain access token") {
+		t.Errorf("Expected an access token error, got %v", err)
+	} else if !strings.Contains(err.Error(), "denied") || len(client.calls) != 1 {
+		t.Errorf("Expected a denied authorization to stop polling with a clear error, got %v after %d polls", err, len(client.calls))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client = &stubTokenCreator{errs: []error{&ssooidctypes.AuthorizationPendingException{}}}
+	if _, err := pollForToken(ctx, client, &ssooidc.CreateTokenInput{}, time.Hour, time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to stop polling, got %v", err)
+	}
+}
+
+func TestPollForTokenTransientErrors(t *testing.T) {
+	sendErr := &smithyhttp.RequestSendError{Err: errors.New("connection reset by peer")}
+
+	// A transient error followed by success returns the token
+	client := &stubTokenCreator{errs: []error{sendErr, &ssooidctypes.AuthorizationPendingException{}, sendErr}}
+	resp, err := pollForToken(context.Background(), client, &ssooidc.CreateTokenInput{}, time.Millisecond, time.Minute)
+	if err != nil {
+		t.Fatalf("pollForToken failed: %v", err)
+	}
+	if aws.ToString(resp.AccessToken) != "token" || len(client.calls) != 4 {
+		t.Errorf("Expected the token after 4 polls, got %+v after %d polls", resp, len(client.calls))
+	}
+
+	// Persistent errors stop polling after the bound
+	errs := make([]error, maxTransientPollErrors+1)
+	for i := range errs {
+		errs[i] = sendErr
+	}
+	client = &stubTokenCreator{errs: errs}
+	_, err = pollForToken(context.Background(), client, &ssooidc.CreateTokenInput{}, time.Millisecond, time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "consecutive transient errors") || !errors.Is(err, sendErr) {
+		t.Errorf("Expected a transient errors failure, got %v", err)
+	}
+	if len(client.calls) != maxTransientPollErrors+1 {
+		t.Errorf("Expected %d polls, got %d", maxTransientPollErrors+1, len(client.calls))
+	}
+}
+
+func TestWaitingReminder(t *testing.T) {
+	var buf bytes.Buffer
+	stop := startWaitingReminder(&buf, 10*time.Millisecond)
+	time.Sleep(35 * time.Millisecond)
+	stop()
+
+	reminders := strings.Count(buf.String(), "Still waiting for authorization")
+	if reminders < 2 {
+		t.Errorf("Expected periodic reminders, got %q", buf.String())
+	}
+
+	// Nothing is written once stopped
+	time.Sleep(20 * time.Millisecond)
+	if strings.Count(buf.String(), "Still waiting for authorization") != reminders {
+		t.Error("Expected no reminders after stopping")
+	}
+}
+
+// stubOIDC is an OIDC client for the device flow that never issues a token
+type stubOIDC struct {
+	stubRegistrar
+	stubTokenCreator
+}
+
+func (s *stubOIDC) StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error) {
+	return &ssooidc.StartDeviceAuthorizationOutput{
+		DeviceCode:      aws.String("device-code"),
+		UserCode:        aws.String("ABCD-EFGH"),
+		VerificationUri: aws.String("https://device.sso.us-east-1.amazonaws.com/"),
+		ExpiresIn:       600,
+		Interval:        1,
+	}, nil
+}
+
+func TestLoginCancelledByAuthHandler(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	client := &stubOIDC{}
+	original := newOIDCClient
+	newOIDCClient = func(cfg aws.Config) oidcAPI { return client }
+	t.Cleanup(func() { newOIDCClient = original })
+
+	_, err := Login(context.Background(), LoginInput{
+		StartURL:  "https://test.awsapps.com/start",
+		SSORegion: "us-east-1",
+		UserAuthHandler: func(ctx context.Context, params AuthHandlerParams) error {
+			return fmt.Errorf("dialog closed: %w", ErrLoginCancelled)
+		},
+	})
+	if err != ErrLoginCancelled {
+		t.Errorf("Expected ErrLoginCancelled unwrapped, got %v", err)
+	}
+	if len(client.stubTokenCreator.calls) != 0 {
+		t.Errorf("Expected no token polling after cancellation, got %d polls", len(client.stubTokenCreator.calls))
+	}
+}
+
+func TestLoginInteractiveSource(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	client := &stubOIDC{}
+	original := newOIDCClient
+	newOIDCClient = func(cfg aws.Config) oidcAPI { return client }
+	t.Cleanup(func() { newOIDCClient = original })
+
+	output, err := Login(context.Background(), LoginInput{
+		StartURL:        "https://test.awsapps.com/start",
+		SSORegion:       "us-east-1",
+		UserAuthHandler: func(ctx context.Context, params AuthHandlerParams) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if output.Source != LoginSourceInteractive || !output.Registered {
+		t.Errorf("Expected an interactive login with a new registration, got %+v", output)
+	}
+}
+
+func TestLoginRefreshesToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+
+	startURL := "https://test.awsapps.com/start"
+	putExpiredToken := func() {
+		t.Helper()
+		err := PutCachedToken(nil, startURL, &Token{
+			AccessToken:           "expired-token",
+			ExpiresAt:             time.Now().Add(-time.Hour),
+			RefreshToken:          "refresh-token",
+			ClientID:              "cached-client",
+			ClientSecret:          "cached-secret",
+			RegistrationExpiresAt: time.Now().Add(30 * 24 * time.Hour),
+			Region:                "us-east-1",
+		})
+		if err != nil {
+			t.Fatalf("PutCachedToken failed: %v", err)
+		}
+	}
+
+	client := &stubOIDC{}
+	original := newOIDCClient
+	newOIDCClient = func(cfg aws.Config) oidcAPI { return client }
+	t.Cleanup(func() { newOIDCClient = original })
+
+	input := LoginInput{
+		StartURL:        startURL,
+		SSORegion:       "us-east-1",
+		UserAuthHandler: func(ctx context.Context, params AuthHandlerParams) error { return nil },
+	}
+
+	// The refresh token renews the session without the device flow
+	putExpiredToken()
+	output, err := Login(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if output.Source != LoginSourceRefreshed || output.Token.AccessToken != "token" {
+		t.Errorf("Expected a refreshed token, got %+v", output)
+	}
+	if output.Token.RefreshToken != "refresh-token" || output.Token.ClientID != "cached-client" {
+		t.Errorf("Expected the refresh token and registration to be kept, got %+v", output.Token)
+	}
+	if cached, _ := readCachedToken(SSOCacheDir(), startURL, nil); cached == nil || cached.AccessToken != "token" {
+		t.Errorf("Expected the refreshed token to be cached, got %+v", cached)
+	}
+
+	// A rejected refresh token falls back to the device flow with the cached registration
+	putExpiredToken()
+	client.stubTokenCreator = stubTokenCreator{errs: []error{&ssooidctypes.InvalidGrantException{}}}
+	output, err = Login(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if output.Source != LoginSourceInteractive || output.Registered || client.stubRegistrar.calls != 0 {
+		t.Errorf("Expected an interactive login reusing the registration, got %+v", output)
+	}
+}
+
+func TestLoginRecoversFromCorruptCachedToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+
+	startURL := "https://test.awsapps.com/start"
+	path := ssoCacheFilePath(SSOCacheDir(), startURL)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"accessToken": "trunc`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := GetCachedToken(nil, startURL)
+	var corrupt *CorruptTokenCacheError
+	if !errors.As(err, &corrupt) || corrupt.Path != path || !strings.Contains(err.Error(), startURL) {
+		t.Fatalf("Expected a corrupt token error naming the start URL, got %v", err)
+	}
+
+	client := &stubOIDC{}
+	original := newOIDCClient
+	newOIDCClient = func(cfg aws.Config) oidcAPI { return client }
+	t.Cleanup(func() { newOIDCClient = original })
+
+	output, err := Login(context.Background(), LoginInput{
+		StartURL:        startURL,
+		SSORegion:       "us-east-1",
+		UserAuthHandler: func(ctx context.Context, params AuthHandlerParams) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if output.Source != LoginSourceInteractive {
+		t.Errorf("Expected an interactive login, got %+v", output)
+	}
+	if cached, err := readCachedToken(SSOCacheDir(), startURL, nil); err != nil || cached == nil || cached.AccessToken != "token" {
+		t.Errorf("Expected the corrupt token to be replaced, got %+v (%v)", cached, err)
+	}
+}
+
+func TestLoginSSORegionMismatch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+
+	startURL := "https://test.awsapps.com/start"
+	err := PutCachedToken(nil, startURL, &Token{
+		AccessToken: "eu-token",
+		ExpiresAt:   time.Now().Add(time.Hour),
+		Region:      "eu-west-1",
+	})
+	if err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+
+	// The region isn't checked when it isn't known
+	if token, err := getCachedToken(SSOCacheDir(), startURL, "", nil); err != nil || token == nil {
+		t.Fatalf("Expected the cached token without a region, got %+v (%v)", token, err)
+	}
+	if token, err := getCachedToken(SSOCacheDir(), startURL, "eu-west-1", nil); err != nil || token == nil {
+		t.Fatalf("Expected the cached token in its region, got %+v (%v)", token, err)
+	}
+	if token, err := getCachedToken(SSOCacheDir(), startURL, "us-east-1", nil); err != nil || token != nil {
+		t.Fatalf("Expected a cache miss in another region, got %+v (%v)", token, err)
+	}
+
+	client := &stubOIDC{}
+	original := newOIDCClient
+	newOIDCClient = func(cfg aws.Config) oidcAPI { return client }
+	t.Cleanup(func() { newOIDCClient = original })
+
+	output, err := Login(context.Background(), LoginInput{
+		StartURL:        startURL,
+		SSORegion:       "us-east-1",
+		UserAuthHandler: func(ctx context.Context, params AuthHandlerParams) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if output.Source != LoginSourceInteractive || output.Token.Region != "us-east-1" {
+		t.Errorf("Expected a new login in the requested region, got %+v", output)
+	}
+}
+
+func TestLoginNoCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+
+	startURL := "https://test.awsapps.com/start"
+	token := &Token{AccessToken: "earlier-token", ExpiresAt: time.Now().Add(time.Hour), Region: "us-east-1"}
+	if err := PutCachedToken(nil, startURL, token); err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+	// The token was cached by an earlier run
+	issuedTokens.Delete(token.AccessToken)
+
+	client := &stubOIDC{}
+	original := newOIDCClient
+	newOIDCClient = func(cfg aws.Config) oidcAPI { return client }
+	t.Cleanup(func() { newOIDCClient = original })
+
+	input := LoginInput{
+		StartURL:        startURL,
+		SSORegion:       "us-east-1",
+		UserAuthHandler: func(ctx context.Context, params AuthHandlerParams) error { return nil },
+		Config:          &Config{NoCache: true},
+	}
+	output, err := Login(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if output.Source != LoginSourceInteractive {
+		t.Errorf("Expected the earlier token to be bypassed, got %+v", output)
+	}
+
+	// The token of this process is reused by later operations
+	if err := PutCachedToken(nil, startURL, &Token{AccessToken: "new-token", ExpiresAt: time.Now().Add(time.Hour), Region: "us-east-1"}); err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+	output, err = Login(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if output.Source != LoginSourceCached || output.Token.AccessToken != "new-token" {
+		t.Errorf("Expected the token of this process to be reused, got %+v", output)
+	}
+
+	configInput := GetAWSConfigInput{CredentialCache: NewMemoryCache(), Config: input.Config}
+	if configInput.credentialCache() != nil {
+		t.Error("Expected the credential cache to be bypassed")
+	}
+}
+
+// newPortalServer serves the SSO ListAccounts and ListAccountRoles APIs for
+// accounts mapped to their roles, paginated by account ID and role order.
+// Accounts without roles can't be listed. Every request is counted in calls.
+func newPortalServer(t *testing.T, accounts map[string][]string, calls *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/assignment/accounts":
+			var ids []string
+			for id := range accounts {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+
+			var list []string
+			for _, id := range ids {
+				list = append(list, fmt.Sprintf(`{"accountId":%q,"accountName":"account-%s"}`, id, id))
+			}
+			list, next := portalPage(r, list)
+			fmt.Fprintf(w, `{"accountList":[%s]%s}`, strings.Join(list, ","), next)
+		case "/assignment/roles":
+			id := r.URL.Query().Get("account_id")
+			if len(accounts[id]) == 0 {
+				w.Header().Set("X-Amzn-Errortype", "ForbiddenException")
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"message":"No access"}`)
+				return
+			}
+			var list []string
+			for _, role := range accounts[id] {
+				list = append(list, fmt.Sprintf(`{"accountId":%q,"roleName":%q}`, id, role))
+			}
+			list, next := portalPage(r, list)
+			fmt.Fprintf(w, `{"roleList":[%s]%s}`, strings.Join(list, ","), next)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// portalPage returns the page of items a list request asks for, and the
+// nextToken member to add to the response when more items remain
+func portalPage(r *http.Request, items []string) ([]string, string) {
+	start, _ := strconv.Atoi(r.URL.Query().Get("next_token"))
+	end := len(items)
+	if size, err := strconv.Atoi(r.URL.Query().Get("max_result")); err == nil && start+size < end {
+		end = start + size
+	}
+	if end == len(items) {
+		return items[start:], ""
+	}
+	return items[start:end], fmt.Sprintf(`,"nextToken":"%d"`, end)
+}
+
+func TestListAccessibleAccountRoles(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	t.Setenv("AWS_ENDPOINT_URL_SSO", newPortalServer(t, map[string][]string{
+		"222222222222": {"ReadOnly", "Admin"},
+		"111111111111": {"Developer"},
+		"333333333333": nil,
+	}, &calls).URL)
+
+	groups, err := ListAccessibleAccountRoles(context.Background(), ListRolesInput{
+		StartURL:  startURL,
+		SSORegion: "us-east-1",
+	})
+	if err != nil {
+		t.Fatalf("ListAccessibleAccountRoles failed: %v", err)
+	}
+
+	var got []string
+	for _, group := range groups {
+		entry := group.Account.AccountName + ":"
+		for _, role := range group.Roles {
+			if role.AccountName != group.Account.AccountName {
+				t.Errorf("Role %s has account name %s, expected %s", role.RoleName, role.AccountName, group.Account.AccountName)
+			}
+			entry += " " + role.RoleName
+		}
+		got = append(got, entry)
+	}
+
+	want := []string{
+		"account-111111111111: Developer",
+		"account-222222222222: Admin ReadOnly",
+		"account-333333333333:",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestListAccountsForRole(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	t.Setenv("AWS_ENDPOINT_URL_SSO", newPortalServer(t, map[string][]string{
+		"111111111111": {"Developer"},
+		"222222222222": {"ReadOnly", "Admin"},
+		"333333333333": {"admin"},
+		"444444444444": nil,
+	}, &calls).URL)
+	ctx := context.Background()
+	input := ListRolesInput{StartURL: startURL, SSORegion: "us-east-1"}
+
+	accountIDs := func(roleName string, input ListRolesInput) string {
+		t.Helper()
+		accounts, err := ListAccountsForRole(ctx, input, roleName)
+		if err != nil {
+			t.Fatalf("ListAccountsForRole failed: %v", err)
+		}
+		var ids []string
+		for _, account := range accounts {
+			ids = append(ids, account.AccountID)
+		}
+		return strings.Join(ids, " ")
+	}
+
+	if got := accountIDs("Admin", input); got != "222222222222" {
+		t.Errorf("Expected the account with Admin, got %q", got)
+	}
+
+	input.IgnoreRoleCase = true
+	if got := accountIDs("ADMIN", input); got != "222222222222 333333333333" {
+		t.Errorf("Expected the accounts with admin in any case, got %q", got)
+	}
+
+	input.MaxResults = 1
+	if got := accountIDs("admin", input); got != "222222222222" {
+		t.Errorf("Expected the first matching account, got %q", got)
+	}
+
+	if got := accountIDs("Billing", ListRolesInput{StartURL: startURL, SSORegion: "us-east-1"}); got != "" {
+		t.Errorf("Expected no accounts, got %q", got)
+	}
+
+	if _, err := ListAccountsForRole(ctx, input, ""); err == nil {
+		t.Error("Expected an empty role name to be rejected")
+	}
+}
+
+func TestListMaxResults(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	var portalCalls atomic.Int32
+	t.Setenv("AWS_ENDPOINT_URL_SSO", newPortalServer(t, map[string][]string{
+		"111111111111": {"Admin", "Developer", "ReadOnly"},
+		"222222222222": {"Admin", "ReadOnly"},
+		"333333333333": {"Admin"},
+	}, &portalCalls).URL)
+	ctx := context.Background()
+
+	accounts, err := ListAvailableAccounts(ctx, ListAccountsInput{StartURL: startURL, SSORegion: "us-east-1", MaxResults: 2})
+	if err != nil {
+		t.Fatalf("ListAvailableAccounts failed: %v", err)
+	}
+	if len(accounts) != 2 || accounts[1].AccountID != "222222222222" {
+		t.Errorf("Expected the first 2 accounts, got %+v", accounts)
+	}
+
+	// The listing stops once the limit is reached
+	portalCalls.Store(0)
+	roles, err := ListAvailableRoles(ctx, ListRolesInput{StartURL: startURL, SSORegion: "us-east-1", MaxResults: 4})
+	if err != nil {
+		t.Fatalf("ListAvailableRoles failed: %v", err)
+	}
+	var got []string
+	for _, role := range roles {
+		got = append(got, role.AccountID+"/"+role.RoleName)
+	}
+	want := "111111111111/Admin 111111111111/Developer 111111111111/ReadOnly 222222222222/Admin"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, " "))
+	}
+	if n := portalCalls.Load(); n != 3 {
+		t.Errorf("Expected 1 account listing and 2 role listings, got %d calls", n)
+	}
+
+	groups, err := ListAccessibleAccountRoles(ctx, ListRolesInput{StartURL: startURL, SSORegion: "us-east-1", MaxResults: 1})
+	if err != nil {
+		t.Fatalf("ListAccessibleAccountRoles failed: %v", err)
+	}
+	if len(groups) != 1 || len(groups[0].Roles) != 3 {
+		t.Errorf("Expected one account with all its roles, got %+v", groups)
+	}
+}
+
+func TestListAvailableRolesExplicitAccountError(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	t.Setenv("AWS_ENDPOINT_URL_SSO", newPortalServer(t, map[string][]string{
+		"111111111111": {"Admin"},
+		"222222222222": nil,
+	}, new(atomic.Int32)).URL)
+	ctx := context.Background()
+
+	// Accounts asked for by ID fail the listing rather than look empty
+	input := ListRolesInput{StartURL: startURL, SSORegion: "us-east-1", AccountIDs: []string{"222222222222"}}
+	if roles, err := ListAvailableRoles(ctx, input); err == nil || !strings.Contains(err.Error(), "222222222222") {
+		t.Errorf("Expected the listing error of the account, got %+v (%v)", roles, err)
+	}
+	input.MaxResults = 1
+	if _, err := ListAvailableRoles(ctx, input); err == nil {
+		t.Error("Expected the listing error of the account with a limit")
+	}
+
+	// Sweeps skip the account
+	roles, err := ListAvailableRoles(ctx, ListRolesInput{StartURL: startURL, SSORegion: "us-east-1"})
+	if err != nil || len(roles) != 1 || roles[0].AccountID != "111111111111" {
+		t.Errorf("Expected the roles of the other account, got %+v (%v)", roles, err)
+	}
+}
+
+func TestListPageSize(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	var portalCalls atomic.Int32
+	t.Setenv("AWS_ENDPOINT_URL_SSO", newPortalServer(t, map[string][]string{
+		"111111111111": {"Admin"},
+		"222222222222": {"Admin"},
+		"333333333333": {"Admin"},
+	}, &portalCalls).URL)
+	ctx := context.Background()
+
+	// The API maximum is requested by default, so one page holds all accounts
+	input := ListAccountsInput{StartURL: startURL, SSORegion: "us-east-1"}
+	if accounts, err := ListAvailableAccounts(ctx, input); err != nil || len(accounts) != 3 {
+		t.Fatalf("Expected 3 accounts, got %+v (%v)", accounts, err)
+	}
+	if n := portalCalls.Load(); n != 1 {
+		t.Errorf("Expected 1 call, got %d", n)
+	}
+
+	portalCalls.Store(0)
+	input.Config = &Config{PageSize: 2}
+	if accounts, err := ListAvailableAccounts(ctx, input); err != nil || len(accounts) != 3 {
+		t.Fatalf("Expected 3 accounts, got %+v (%v)", accounts, err)
+	}
+	if n := portalCalls.Load(); n != 2 {
+		t.Errorf("Expected 2 calls with pages of 2, got %d", n)
+	}
+
+	var configErr *InvalidConfigError
+	input.Config = &Config{PageSize: 101}
+	if _, err := ListAvailableAccounts(ctx, input); !errors.As(err, &configErr) {
+		t.Errorf("Expected a page size over the API limit to be rejected, got %v", err)
+	}
+}
+
+func TestListRolesMaxConcurrency(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	accounts := map[string][]string{}
+	for i := 1; i <= 6; i++ {
+		accounts[strings.Repeat(strconv.Itoa(i), 12)] = []string{"Admin"}
+	}
+	portal := newPortalServer(t, accounts, &calls)
+
+	// Role listings are slowed down so concurrent ones overlap
+	var mu sync.Mutex
+	var inFlight, peak int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/assignment/roles" {
+			mu.Lock()
+			inFlight++
+			peak = max(peak, inFlight)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}
+		portal.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("AWS_ENDPOINT_URL_SSO", server.URL)
+
+	for _, maxConcurrency := range []int{1, 2} {
+		peak = 0
+		roles, err := ListAvailableRoles(context.Background(), ListRolesInput{StartURL: startURL, SSORegion: "us-east-1", MaxConcurrency: maxConcurrency})
+		if err != nil {
+			t.Fatalf("ListAvailableRoles failed: %v", err)
+		}
+		if len(roles) != 6 {
+			t.Errorf("Expected 6 roles, got %d", len(roles))
+		}
+		if peak > maxConcurrency {
+			t.Errorf("Expected at most %d concurrent role listings, got %d", maxConcurrency, peak)
+		}
+	}
+}
+
+func TestGetTokenForOperationForceRefresh(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+	// Keep the default auth handler from launching a browser
+	t.Setenv("PATH", t.TempDir())
+
+	startURL := "https://test.awsapps.com/start"
+	cached := &Token{AccessToken: "cached-token", ExpiresAt: time.Now().Add(time.Hour), Region: "us-east-1"}
+	if err := PutCachedToken(nil, startURL, cached); err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+
+	client := &stubOIDC{}
+	original := newOIDCClient
+	newOIDCClient = func(cfg aws.Config) oidcAPI { return client }
+	t.Cleanup(func() { newOIDCClient = original })
+
+	ctx := context.Background()
+	token, err := getTokenForOperation(ctx, startURL, "us-east-1", false, false, nil, nil)
+	if err != nil || token.AccessToken != "cached-token" {
+		t.Fatalf("Expected the cached token, got %+v (%v)", token, err)
+	}
+
+	token, err = getTokenForOperation(ctx, startURL, "us-east-1", false, true, nil, nil)
+	if err != nil {
+		t.Fatalf("getTokenForOperation failed: %v", err)
+	}
+	if token.AccessToken != "token" || len(client.stubTokenCreator.calls) != 1 {
+		t.Errorf("Expected a fresh token from the device flow, got %+v", token)
+	}
+}
+
+func TestPostLoginHook(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+
+	startURL := "https://test.awsapps.com/start"
+	cached := &Token{AccessToken: "cached-token", ExpiresAt: time.Now().Add(time.Hour), Region: "us-east-1"}
+	if err := PutCachedToken(nil, startURL, cached); err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+
+	var seen []*LoginOutput
+	hookErr := errors.New("webhook unavailable")
+	cfg := &Config{
+		Logger: slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+		PostLoginHook: func(ctx context.Context, output *LoginOutput) error {
+			seen = append(seen, output)
+			return hookErr
+		},
+	}
+	input := LoginInput{StartURL: startURL, SSORegion: "us-east-1", Config: cfg}
+
+	// Hook errors are only logged by default
+	output, err := Login(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if len(seen) != 1 || seen[0] != output {
+		t.Fatalf("Expected the hook to receive the login output, got %v", seen)
+	}
+	if output.Source != LoginSourceCached || output.StartURL != startURL {
+		t.Errorf("Expected a cached login for %s, got %s for %s", startURL, output.Source, output.StartURL)
+	}
+
+	cfg.StrictPostLoginHook = true
+	if _, err := Login(context.Background(), input); !errors.Is(err, hookErr) {
+		t.Errorf("Expected the hook error in strict mode, got %v", err)
+	}
+}
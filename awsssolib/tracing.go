@@ -0,0 +1,46 @@
+package awsssolib
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Tracer starts spans around SSO operations so they show up in distributed
+// traces. An adapter for OpenTelemetry or another tracing library can be set
+// on Config without this package depending on it.
+//
+// Spans are started for Login and for each GetRoleCredentials, ListAccounts
+// and ListAccountRoles API call. The context returned by Start is passed on to
+// the AWS SDK, so SDK-level instrumentation nests under the span.
+type Tracer interface {
+	// Start starts a span as a child of the span in ctx, if any, and returns a
+	// context carrying the new span
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	// End ends the span, marking it as failed when err is not nil
+	End(err error)
+}
+
+// NoopTracer is a Tracer that doesn't record spans
+type NoopTracer struct{}
+
+// Start returns ctx unchanged and a span that does nothing
+func (NoopTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+// noopSpan is the span returned by NoopTracer
+type noopSpan struct{}
+
+func (noopSpan) End(err error) {}
+
+// startSpan starts a span with the tracer of the config, or NoopTracer
+func startSpan(ctx context.Context, config *Config, name string, attrs ...slog.Attr) (context.Context, Span) {
+	if config != nil && config.Tracer != nil {
+		return config.Tracer.Start(ctx, name, attrs...)
+	}
+	return NoopTracer{}.Start(ctx, name, attrs...)
+}
@@ -0,0 +1,89 @@
+package awsssolib
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// spanKey is the context key of the current recordedSpan
+type spanKey struct{}
+
+// recordedSpan is a span started by recordingTracer
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]string
+	ended  bool
+	err    error
+}
+
+func (s *recordedSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+// recordingTracer records the spans it starts
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	span := &recordedSpan{name: name, attrs: make(map[string]string)}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		span.parent = parent.name
+	}
+	for _, attr := range attrs {
+		span.attrs[attr.Key] = attr.Value.String()
+	}
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func TestTracer(t *testing.T) {
+	var calls atomic.Int32
+	startURL := setupFactoryTest(t, &calls)
+	tracer := &recordingTracer{}
+	cfg := &Config{Tracer: tracer}
+
+	ctx, parent := tracer.Start(context.Background(), "request")
+
+	if _, err := Login(ctx, LoginInput{StartURL: startURL, SSORegion: "us-east-1", Config: cfg}); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	provider := &ssoCredentialProvider{
+		startURL:  startURL,
+		ssoRegion: "us-east-1",
+		accountID: "111111111111",
+		roleName:  "Developer",
+		config:    cfg,
+	}
+	if _, err := provider.Retrieve(ctx); err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+	parent.End(nil)
+
+	if len(tracer.spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(tracer.spans))
+	}
+
+	login := tracer.spans[1]
+	if login.name != "Login" || login.parent != "request" || login.attrs["start_url"] != startURL || !login.ended {
+		t.Errorf("Unexpected login span: %+v", login)
+	}
+
+	creds := tracer.spans[2]
+	if creds.name != "GetRoleCredentials" || creds.parent != "request" || !creds.ended || creds.err != nil {
+		t.Errorf("Unexpected credentials span: %+v", creds)
+	}
+	if creds.attrs["account_id"] != "111111111111" || creds.attrs["role_name"] != "Developer" {
+		t.Errorf("Unexpected credentials span attributes: %v", creds.attrs)
+	}
+}
@@ -2,7 +2,10 @@ package awsssolib
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/url"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -14,8 +17,27 @@ type SSOInstance struct {
 	Region         string
 	StartURLSource string
 	RegionSource   string
+	// AccountID and RoleName are defaults pinned by a project config file
+	AccountID string
+	RoleName  string
+	// ProfileTemplate is the default profile name template of a remote config
+	ProfileTemplate string
 }
 
+// Instance sources of SSOInstance.StartURLSource and RegionSource, describing
+// where a setting was found, in order of precedence. InstanceSourceEnvironment
+// is AWS_DEFAULT_SSO_START_URL or AWS_DEFAULT_SSO_REGION, and
+// InstanceSourceEnvironmentAlias their AWS_SSO_START_URL or AWS_SSO_REGION
+// alias, used when the former isn't set.
+const (
+	InstanceSourceProfile          = "profile"
+	InstanceSourceEnvironment      = "environment"
+	InstanceSourceEnvironmentAlias = "environment-alias"
+	InstanceSourceProject          = "project"
+	InstanceSourceRemote           = "remote"
+	InstanceSourceConfig           = "config"
+)
+
 // Token represents an SSO access token
 type Token struct {
 	AccessToken      string    `json:"accessToken"`
@@ -24,8 +46,12 @@ type Token struct {
 	ClientID         string    `json:"clientId,omitempty"`
 	ClientSecret     string    `json:"clientSecret,omitempty"`
 	RegistrationTime time.Time `json:"registrationTime,omitempty"`
-	Region           string    `json:"region,omitempty"`
-	StartURL         string    `json:"startUrl,omitempty"`
+	// RegistrationExpiresAt is when the client ID and secret expire
+	RegistrationExpiresAt time.Time `json:"registrationExpiresAt,omitempty"`
+	Region                string    `json:"region,omitempty"`
+	StartURL              string    `json:"startUrl,omitempty"`
+	// Scopes are the scopes granted to a token from LoginWithPKCE
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 // Account represents an AWS account accessible through SSO
@@ -33,6 +59,9 @@ type Account struct {
 	AccountID    string
 	AccountName  string
 	EmailAddress string
+	// Alias is an optional friendly name set by callers, since SSO doesn't
+	// return IAM account aliases
+	Alias string
 }
 
 // Role represents a role within an AWS account
@@ -42,27 +71,93 @@ type Role struct {
 	AccountName string
 }
 
+// AccountRoles is an account with the roles available in it
+type AccountRoles struct {
+	Account Account
+	Roles   []Role
+}
+
 // Config contains global configuration for the library
 type Config struct {
 	Logger   *slog.Logger
 	LogLevel slog.Level
+	// AppName is sent as the application ID in the user agent of SSO and
+	// OIDC requests so calls can be attributed in CloudTrail
+	AppName string
+	// ClientName is the name of the OIDC client registered for logins, shown
+	// to Identity Center admins and in CloudTrail (default: aws-sso-lib-go).
+	// It applies to new registrations; cached ones keep their name until
+	// they expire.
+	ClientName string
+	// Proxy routes all SSO and OIDC requests through this proxy, ignoring
+	// HTTPS_PROXY and NO_PROXY. When nil, the proxy environment variables apply.
+	Proxy *url.URL
+	// SSOCacheDir overrides the SSO token cache directory (default: SSOCacheDir,
+	// which honors AWS_SSO_CACHE_DIR). Token files keep their AWS CLI
+	// compatible names. NewTokenCache returns the token cache of the directory.
+	SSOCacheDir string
+	// Metrics receives cache, login and API error events (default: none)
+	Metrics Metrics
+	// Tracer starts spans around logins and SSO API calls (default: none)
+	Tracer Tracer
+	// PostLoginHook is called after every successful Login, including when a
+	// cached token is reused, for example to send an audit record to a SIEM.
+	// Its errors are logged and don't fail the login unless
+	// StrictPostLoginHook is set.
+	PostLoginHook func(ctx context.Context, output *LoginOutput) error
+	// StrictPostLoginHook makes Login fail when PostLoginHook returns an error
+	StrictPostLoginHook bool
+	// NoCache bypasses the token and credential caches. Tokens cached before
+	// this process obtained one are ignored, so the first login, or the first
+	// operation needing a token, logs in again as with ForceRefresh and
+	// prompts the user. Role credentials are fetched on each retrieval.
+	NoCache bool
+	// PageSize is the number of accounts or roles requested per
+	// ListAccounts and ListAccountRoles call, 1 to 100 (default: 100, the
+	// API maximum). Smaller pages mean more calls for large organizations.
+	PageSize int
 }
 
 // GetAWSConfigInput contains parameters for getting AWS SDK config
 type GetAWSConfigInput struct {
-	StartURL  string
+	StartURL string
+	// SSORegion is the region of the IAM Identity Center instance. Logins and
+	// role credential requests always go to it.
 	SSORegion string
 	AccountID string
 	RoleName  string
-	Region    string
-	Login     bool
+	// Region is the workload region the returned config targets. It may
+	// differ from SSORegion, e.g. SSO in us-east-1 and workloads in eu-west-1.
+	Region string
+	Login  bool
 	// Optional caches
 	SSOCache        Cache
 	CredentialCache Cache
+	// DisableCredentialCache keeps role credentials out of every cache, even
+	// when CredentialCache is set. Credentials are fetched on each retrieval.
+	DisableCredentialCache bool
+	// MinValidity makes cached credentials that expire within this duration
+	// be fetched again instead of returned, so callers don't get credentials
+	// about to expire (default: only expired credentials are fetched again)
+	MinValidity time.Duration
+	// RoleSessionName names the sessions of roles assumed through STS with
+	// the SSO credentials, for CloudTrail attribution. SSO GetRoleCredentials
+	// takes no session name, so the SSO role session itself is not affected,
+	// and as no STS step is made yet the name is only validated. It must match
+	// [\w+=,.@-]{2,64}. DefaultRoleSessionName provides a default.
+	RoleSessionName string
 	// Optional configuration
 	Config *Config
 }
 
+// credentialCache returns the credential cache to use, nil when disabled
+func (input GetAWSConfigInput) credentialCache() Cache {
+	if input.DisableCredentialCache || noCache(input.Config) {
+		return nil
+	}
+	return input.CredentialCache
+}
+
 // LoginInput contains parameters for SSO login
 type LoginInput struct {
 	StartURL       string
@@ -71,6 +166,20 @@ type LoginInput struct {
 	ExpiryWindow   time.Duration
 	DisableBrowser bool
 	Message        string
+	// Quiet turns off the reminders the default auth handler's login prints
+	// to a terminal while waiting for the user to approve the authorization
+	Quiet bool
+	// Preflight checks the start URL and SSO region before the device flow
+	Preflight bool
+	// Timeout caps the login, including the wait for the user to approve the
+	// device authorization (default: DefaultLoginTimeout). A sooner context
+	// deadline takes precedence.
+	Timeout time.Duration
+	// UseToken supplies a token obtained outside the library. A valid token is
+	// cached and returned without the device flow; an invalid one is an error.
+	UseToken *Token
+	// ClientName overrides Config.ClientName for this login
+	ClientName string
 	// Optional auth handler for custom auth flow
 	UserAuthHandler AuthHandler
 	// Optional cache
@@ -79,10 +188,110 @@ type LoginInput struct {
 	Config *Config
 }
 
+// LoginWithIAMInput contains parameters for LoginWithIAM
+type LoginWithIAMInput struct {
+	// StartURL is the SSO instance the token is cached for
+	StartURL  string
+	SSORegion string
+	// ClientID is the ARN of the Identity Center application with the JWT
+	// bearer grant configured
+	ClientID string
+	// Assertion is the JWT issued by a trusted token issuer
+	Assertion string
+	// Optional: scopes to request, all scopes of the application when empty
+	Scopes []string
+	// Optional configuration
+	Config *Config
+}
+
+// LoginWithPKCEInput contains parameters for LoginWithPKCE
+type LoginWithPKCEInput struct {
+	StartURL  string
+	SSORegion string
+	// Optional: scopes to request (default: sso:account:access)
+	Scopes []string
+	// Optional: port of the redirect server on 127.0.0.1 (default: any free port)
+	RedirectPort int
+	// DisableBrowser prints the authorization URL without opening a browser
+	DisableBrowser bool
+	// Timeout caps the login, including the wait for the user to approve the
+	// authorization (default: DefaultLoginTimeout). A sooner context deadline
+	// takes precedence.
+	Timeout time.Duration
+	// ClientName overrides Config.ClientName for this login
+	ClientName string
+	// Optional handler called with the authorization URL in
+	// VerificationURIComplete instead of opening the browser
+	UserAuthHandler AuthHandler
+	// Optional configuration
+	Config *Config
+}
+
 // LoginOutput contains the result of SSO login
 type LoginOutput struct {
 	Token     *Token
 	ExpiresAt time.Time
+	// StartURL is the SSO instance that was logged in to
+	StartURL string
+	// Source tells how the token was obtained
+	Source LoginSource
+	// Registered is true when a new OIDC client was registered for the login
+	Registered bool
+}
+
+// LoginSource describes how Login obtained its token
+type LoginSource string
+
+const (
+	// LoginSourceCached means a valid cached token was reused
+	LoginSourceCached LoginSource = "cached"
+	// LoginSourceRefreshed means the token was renewed without user interaction
+	LoginSourceRefreshed LoginSource = "refreshed"
+	// LoginSourceInteractive means the user completed the device authorization
+	LoginSourceInteractive LoginSource = "interactive"
+	// LoginSourceSupplied means the token was passed in LoginInput.UseToken
+	LoginSourceSupplied LoginSource = "supplied"
+	// LoginSourceIAM means the token was obtained by LoginWithIAM
+	LoginSourceIAM LoginSource = "iam"
+	// LoginSourcePKCE means the token was obtained by LoginWithPKCE
+	LoginSourcePKCE LoginSource = "pkce"
+)
+
+// TokenState describes a cached SSO token
+type TokenState string
+
+const (
+	// TokenStateMissing means no token is cached, the user never logged in or logged out
+	TokenStateMissing TokenState = "missing"
+	// TokenStateValid means the token can be used
+	TokenStateValid TokenState = "valid"
+	// TokenStateExpiring means the token expires within the expiry buffer and
+	// is no longer returned by GetCachedToken
+	TokenStateExpiring TokenState = "expiring"
+	// TokenStateExpired means the session has expired and a new login is needed
+	TokenStateExpired TokenState = "expired"
+)
+
+// TokenStatus is the result of InspectCachedToken
+type TokenStatus struct {
+	State TokenState
+	// Token is the cached token, also when it has expired; nil when missing
+	Token *Token
+	// ExpiresAt is the expiry of the cached token
+	ExpiresAt time.Time
+	// Path is the token cache file
+	Path string
+}
+
+// CachedSession is a token file of the SSO cache, as listed by
+// ListCachedSessions
+type CachedSession struct {
+	StartURL  string
+	Region    string
+	State     TokenState
+	ExpiresAt time.Time
+	// Path is the token cache file
+	Path string
 }
 
 // ListAccountsInput contains parameters for listing accounts
@@ -90,6 +299,11 @@ type ListAccountsInput struct {
 	StartURL  string
 	SSORegion string
 	Login     bool
+	// ForceRefresh logs in again even if a valid token is cached
+	ForceRefresh bool
+	// MaxResults stops listing once this many accounts are found. Accounts
+	// beyond the limit are omitted. Zero means no limit.
+	MaxResults int
 	// Optional cache
 	SSOCache Cache
 	// Optional configuration
@@ -102,6 +316,19 @@ type ListRolesInput struct {
 	SSORegion  string
 	AccountIDs []string // Optional: filter by account IDs
 	Login      bool
+	// ForceRefresh logs in again even if a valid token is cached
+	ForceRefresh bool
+	// MaxResults stops listing once this many roles are found, or accounts
+	// for ListAccessibleAccountRoles. Results beyond the limit are omitted,
+	// and roles are then listed one account at a time. Zero means no limit.
+	MaxResults int
+	// MaxConcurrency bounds the accounts whose roles are listed at once.
+	// Higher values list faster but are throttled sooner. Zero means
+	// DefaultMaxConcurrency.
+	MaxConcurrency int
+	// IgnoreRoleCase makes ListAccountsForRole match the role name ignoring
+	// case
+	IgnoreRoleCase bool
 	// Optional cache
 	SSOCache Cache
 	// Optional configuration
@@ -115,7 +342,8 @@ type Cache interface {
 	Delete(key string) error
 }
 
-// AuthHandler is called during the authentication flow
+// AuthHandler is called during the authentication flow, before polling for
+// the token. Return ErrLoginCancelled to abort the login.
 type AuthHandler func(ctx context.Context, params AuthHandlerParams) error
 
 // AuthHandlerParams contains parameters passed to the auth handler
@@ -151,6 +379,28 @@ func (e InvalidConfigError) Error() string {
 	return "invalid configuration: " + e.Message
 }
 
+// CorruptTokenCacheError means the cached SSO token file of a start URL can't
+// be parsed, for example because it was truncated. Login removes the file and
+// logs in again.
+type CorruptTokenCacheError struct {
+	StartURL string
+	Path     string
+	Err      error
+}
+
+func (e CorruptTokenCacheError) Error() string {
+	return fmt.Sprintf("cached SSO token for %s is corrupt; re-login required (%s): %v", e.StartURL, e.Path, e.Err)
+}
+
+func (e CorruptTokenCacheError) Unwrap() error {
+	return e.Err
+}
+
+// ErrLoginCancelled is returned by an AuthHandler to abort the login, for
+// example when the user dismisses a login dialog. Login returns it unwrapped
+// and stops without polling for the token.
+var ErrLoginCancelled = errors.New("SSO login cancelled")
+
 // DefaultConfig returns a default configuration with INFO level logging to stderr
 func DefaultConfig() *Config {
 	return &Config{
@@ -175,6 +425,11 @@ func getLogger(config *Config) *slog.Logger {
 	return slog.Default()
 }
 
+// noCache reports whether config bypasses the caches
+func noCache(config *Config) bool {
+	return config != nil && config.NoCache
+}
+
 // shouldLog returns true if the given level should be logged based on config
 func shouldLog(config *Config, level slog.Level) bool {
 	if config == nil {
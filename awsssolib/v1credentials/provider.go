@@ -0,0 +1,115 @@
+// Package v1credentials adapts the SSO credentials of awsssolib to the
+// credentials.Provider interface of aws-sdk-go (v1), for code that hasn't
+// moved to aws-sdk-go-v2 yet. It is a separate module so that only its
+// importers depend on aws-sdk-go.
+package v1credentials
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// ProviderName is the provider name of the credentials values
+const ProviderName = "AWSSSOLibProvider"
+
+// DefaultExpiryWindow is how long before their expiry credentials are
+// reported as expired, so requests aren't signed with credentials about to
+// expire
+const DefaultExpiryWindow = time.Minute
+
+// Provider is an aws-sdk-go credentials.Provider backed by an aws-sdk-go-v2
+// credentials provider. It also implements credentials.ProviderWithContext
+// and credentials.Expirer. It is safe for concurrent use.
+type Provider struct {
+	ctx      context.Context
+	provider aws.CredentialsProvider
+	// ExpiryWindow is how long before their expiry credentials are reported
+	// as expired (default: DefaultExpiryWindow when created with NewProvider)
+	ExpiryWindow time.Duration
+
+	mu        sync.Mutex
+	retrieved bool
+	canExpire bool
+	expires   time.Time
+}
+
+// V1CredentialsProvider returns an aws-sdk-go credentials provider for the
+// account and role of input, with the same logins, caching and refreshing as
+// the credentials of awsssolib.GetAWSConfig. ctx is used by Retrieve, as the
+// v1 interface takes no context; RetrieveWithContext uses its own.
+func V1CredentialsProvider(ctx context.Context, input awsssolib.GetAWSConfigInput) (*Provider, error) {
+	cfg, err := awsssolib.GetAWSConfig(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return NewProvider(ctx, cfg.Credentials), nil
+}
+
+// NewProvider returns an aws-sdk-go credentials provider retrieving its
+// credentials from an aws-sdk-go-v2 provider, using ctx for Retrieve
+func NewProvider(ctx context.Context, provider aws.CredentialsProvider) *Provider {
+	return &Provider{
+		ctx:          ctx,
+		provider:     provider,
+		ExpiryWindow: DefaultExpiryWindow,
+	}
+}
+
+// Retrieve retrieves the credentials with the context of the provider
+func (p *Provider) Retrieve() (credentials.Value, error) {
+	return p.RetrieveWithContext(p.ctx)
+}
+
+// RetrieveWithContext retrieves the credentials with ctx
+func (p *Provider) RetrieveWithContext(ctx credentials.Context) (credentials.Value, error) {
+	creds, err := p.provider.Retrieve(ctx)
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, fmt.Errorf("failed to retrieve SSO credentials: %w", err)
+	}
+
+	p.mu.Lock()
+	p.retrieved = true
+	p.canExpire = creds.CanExpire
+	p.expires = creds.Expires
+	p.mu.Unlock()
+
+	return credentials.Value{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		ProviderName:    ProviderName,
+	}, nil
+}
+
+// IsExpired reports whether the credentials must be retrieved again: they
+// never were, or they expire within the expiry window
+func (p *Provider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.retrieved {
+		return true
+	}
+	if !p.canExpire {
+		return false
+	}
+	return !time.Now().Add(p.ExpiryWindow).Before(p.expires)
+}
+
+// ExpiresAt returns the expiry of the last credentials retrieved, zero when
+// none were retrieved or they don't expire
+func (p *Provider) ExpiresAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.canExpire {
+		return time.Time{}
+	}
+	return p.expires
+}
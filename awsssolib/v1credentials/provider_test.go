@@ -0,0 +1,80 @@
+package v1credentials
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// The provider satisfies the v1 interfaces
+var (
+	_ credentials.ProviderWithContext = (*Provider)(nil)
+	_ credentials.Expirer             = (*Provider)(nil)
+)
+
+func TestProvider(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	calls := 0
+	provider := NewProvider(context.Background(), aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		calls++
+		return aws.Credentials{
+			AccessKeyID:     "AKIA",
+			SecretAccessKey: "secret",
+			SessionToken:    "session",
+			CanExpire:       true,
+			Expires:         expires,
+		}, nil
+	}))
+
+	if !provider.IsExpired() {
+		t.Error("Expected credentials that were never retrieved to be expired")
+	}
+
+	// The v1 credentials cache retrieves once until the provider reports expiry
+	creds := credentials.NewCredentials(provider)
+	for i := 0; i < 2; i++ {
+		value, err := creds.Get()
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if value.AccessKeyID != "AKIA" || value.SessionToken != "session" || value.ProviderName != ProviderName {
+			t.Errorf("Expected the SSO credentials, got %+v", value)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 retrieval, got %d", calls)
+	}
+	if provider.IsExpired() {
+		t.Error("Expected fresh credentials not to be expired")
+	}
+	if expiresAt, err := creds.ExpiresAt(); err != nil || !expiresAt.Equal(expires) {
+		t.Errorf("Expected the expiry %s, got %s (%v)", expires, expiresAt, err)
+	}
+
+	// Credentials are expired within the expiry window
+	provider.ExpiryWindow = 2 * time.Hour
+	if !provider.IsExpired() {
+		t.Error("Expected credentials within the expiry window to be expired")
+	}
+}
+
+func TestProviderErrors(t *testing.T) {
+	provider := NewProvider(context.Background(), aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, errors.New("token expired")
+	}))
+	if _, err := provider.Retrieve(); err == nil || !provider.IsExpired() {
+		t.Errorf("Expected a failed retrieval to leave the credentials expired, got %v", err)
+	}
+
+	// Invalid input is rejected before any request
+	_, err := V1CredentialsProvider(context.Background(), awsssolib.GetAWSConfigInput{StartURL: "not a url"})
+	var invalid *awsssolib.InvalidConfigError
+	if !errors.As(err, &invalid) {
+		t.Errorf("Expected an invalid configuration error, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// NewAccountsCommand creates the accounts command
+func NewAccountsCommand() *cobra.Command {
+	var login bool
+	var forceRefresh bool
+	var format string
+	var withRole string
+	var ignoreCase bool
+
+	cmd := &cobra.Command{
+		Use:   "accounts",
+		Short: "List available AWS SSO accounts",
+		Long: `List all accounts available through AWS SSO with their email addresses.
+
+Examples:
+  # List all available accounts
+  aws-sso-util accounts
+
+  # List accounts and login if needed
+  aws-sso-util accounts --login
+
+  # Log in again first, even with a valid cached token
+  aws-sso-util accounts --force-refresh
+
+  # Export an account inventory for a spreadsheet
+  aws-sso-util accounts --format csv > accounts.csv
+
+  # Only list the accounts in which you can assume a role
+  aws-sso-util accounts --with-role AdministratorAccess
+
+  # Match the role name in any case
+  aws-sso-util accounts --with-role administratoraccess --ignore-case`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			// Get SSO configuration
+			startURL, ssoRegion, err := resolveInstance(cmd, "")
+			if err != nil {
+				return err
+			}
+
+			// List accounts
+			var accounts []awsssolib.Account
+			if withRole != "" {
+				accounts, err = awsssolib.ListAccountsForRole(ctx, awsssolib.ListRolesInput{
+					StartURL:       startURL,
+					SSORegion:      ssoRegion,
+					Login:          login,
+					ForceRefresh:   forceRefresh,
+					IgnoreRoleCase: ignoreCase,
+					Config:         libConfig,
+				}, withRole)
+			} else {
+				accounts, err = awsssolib.ListAvailableAccounts(ctx, awsssolib.ListAccountsInput{
+					StartURL:     startURL,
+					SSORegion:    ssoRegion,
+					Login:        login,
+					ForceRefresh: forceRefresh,
+					Config:       libConfig,
+				})
+			}
+			if err != nil {
+				return fmt.Errorf("failed to list accounts: %w", err)
+			}
+
+			// Output results
+			switch format {
+			case "json":
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(accounts)
+			case "csv":
+				return awsssolib.WriteAccountsCSV(os.Stdout, accounts)
+			case "table":
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "ACCOUNT ID\tACCOUNT NAME\tEMAIL")
+				fmt.Fprintln(w, "----------\t------------\t-----")
+
+				for _, account := range accounts {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", account.AccountID, account.AccountName, account.EmailAddress)
+				}
+
+				return w.Flush()
+			default:
+				return usageErrorf("unsupported format %q: use table, json or csv", format)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&login, "login", false, "Login if needed")
+	cmd.Flags().BoolVar(&forceRefresh, "force-refresh", false, "Log in again even if a valid token is cached")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format (table, json, csv)")
+	cmd.Flags().StringVar(&withRole, "with-role", "", "Only list accounts in which this role can be assumed")
+	cmd.Flags().BoolVar(&ignoreCase, "ignore-case", false, "Match --with-role ignoring case")
+
+	return cmd
+}
@@ -1,8 +1,16 @@
 package commands
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
 	"github.com/spf13/cobra"
 )
 
@@ -17,12 +25,48 @@ These commands help with administrative tasks like looking up identifiers
 and managing assignments.`,
 	}
 
+	cmd.PersistentFlags().String("region", "", "Region of the Identity Center instance (default: SSO region)")
+	cmd.PersistentFlags().String("instance-arn", "", "Identity Center instance ARN (discovered if not set)")
+	cmd.PersistentFlags().String("profile", "", "AWS profile with SSO admin permissions")
+
 	cmd.AddCommand(newAdminLookupCommand())
 	cmd.AddCommand(newAdminAssignmentsCommand())
+	cmd.AddCommand(newAdminPermissionSetsCommand())
 
 	return cmd
 }
 
+// adminInputFromFlags builds the admin input from the admin command group flags
+func adminInputFromFlags(cmd *cobra.Command) (awsssolib.AdminInput, error) {
+	region, _ := cmd.Flags().GetString("region")
+	instanceARN, _ := cmd.Flags().GetString("instance-arn")
+	profile, _ := cmd.Flags().GetString("profile")
+
+	// Fall back to the SSO region, since admin APIs live in the instance region
+	if region == "" {
+		region, _ = cmd.Flags().GetString("sso-region")
+	}
+	if region == "" {
+		instance, err := awsssolib.FindInstance("")
+		if err != nil {
+			return awsssolib.AdminInput{}, usageErrorf("no region found. Please provide --region or --sso-region")
+		}
+		region = instance.Region
+	}
+
+	libConfig, err := newLibraryConfig(cmd)
+	if err != nil {
+		return awsssolib.AdminInput{}, err
+	}
+
+	return awsssolib.AdminInput{
+		Region:      region,
+		InstanceARN: instanceARN,
+		Profile:     profile,
+		Config:      libConfig,
+	}, nil
+}
+
 // newAdminLookupCommand creates the admin lookup command
 func newAdminLookupCommand() *cobra.Command {
 	cmd := &cobra.Command{
@@ -43,18 +87,235 @@ and CloudFormation templates.`,
 
 // newAdminAssignmentsCommand creates the admin assignments command
 func newAdminAssignmentsCommand() *cobra.Command {
+	var format string
+
 	cmd := &cobra.Command{
 		Use:   "assignments",
 		Short: "List SSO assignments",
 		Long: `List all SSO assignments.
 
 This command helps administrators review all permission set assignments
-across accounts.`,
+across accounts. Assignments can also be emitted as Terraform or
+CloudFormation resources to bring existing assignments under IaC.
+
+Examples:
+  # List assignments
+  aws-sso-util admin assignments --region us-east-1
+
+  # Generate Terraform aws_ssoadmin_account_assignment resources
+  aws-sso-util admin assignments --format terraform > assignments.tf
+
+  # Generate a CloudFormation template with AWS::SSO::Assignment resources
+  aws-sso-util admin assignments --format cloudformation > assignments.json`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// TODO: Implement admin assignments functionality
-			return fmt.Errorf("admin assignments functionality not yet implemented")
+			ctx := context.Background()
+
+			input, err := adminInputFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			assignments, err := awsssolib.ListAccountAssignments(ctx, input)
+			if err != nil {
+				return fmt.Errorf("failed to list assignments: %w", err)
+			}
+
+			switch format {
+			case "json":
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(assignments)
+			case "terraform":
+				return writeAssignmentsTerraform(os.Stdout, assignments)
+			case "cloudformation":
+				return writeAssignmentsCloudFormation(os.Stdout, assignments)
+			case "table":
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "ACCOUNT ID\tPERMISSION SET\tPRINCIPAL TYPE\tPRINCIPAL ID")
+				fmt.Fprintln(w, "----------\t--------------\t--------------\t------------")
+
+				for _, a := range assignments {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", a.AccountID, a.PermissionSetName, a.PrincipalType, a.PrincipalID)
+				}
+
+				return w.Flush()
+			default:
+				return usageErrorf("unsupported format: %s", format)
+			}
 		},
 	}
 
+	cmd.Flags().StringVar(&format, "format", "table", "Output format (table, json, terraform, cloudformation)")
+
 	return cmd
 }
+
+// newAdminPermissionSetsCommand creates the admin permission-sets command
+func newAdminPermissionSetsCommand() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "permission-sets",
+		Short: "List permission sets with their policies",
+		Long: `List the permission sets of the Identity Center instance.
+
+Each permission set is shown with its description, session duration, and
+attached managed and inline policies, which is useful for auditing.
+
+Examples:
+  # List permission sets
+  aws-sso-util admin permission-sets --region us-east-1
+
+  # Include the full policy details as JSON
+  aws-sso-util admin permission-sets --format json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			input, err := adminInputFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			permissionSets, err := awsssolib.ListPermissionSetDetails(ctx, input)
+			if err != nil {
+				return fmt.Errorf("failed to list permission sets: %w", err)
+			}
+
+			switch format {
+			case "json":
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(permissionSets)
+			case "table":
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "NAME\tSESSION DURATION\tMANAGED POLICIES\tINLINE POLICY\tDESCRIPTION")
+				fmt.Fprintln(w, "----\t----------------\t----------------\t-------------\t-----------")
+
+				for _, ps := range permissionSets {
+					inline := "no"
+					if ps.InlinePolicy != "" {
+						inline = "yes"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", ps.Name, ps.SessionDuration, len(ps.ManagedPolicies), inline, ps.Description)
+				}
+
+				return w.Flush()
+			default:
+				return usageErrorf("unsupported format: %s", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+// nonIdentifierChars matches characters not allowed in IaC resource names
+var nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// assignmentResourceName returns a readable, unique-per-assignment resource name
+func assignmentResourceName(a awsssolib.AccountAssignment) string {
+	principalID := a.PrincipalID
+	if len(principalID) > 8 {
+		principalID = principalID[:8]
+	}
+	return strings.Join([]string{a.PermissionSetName, a.AccountID, a.PrincipalType, principalID}, " ")
+}
+
+// uniqueNames returns a name for each assignment built with nameFn, suffixing duplicates after sep
+func uniqueNames(assignments []awsssolib.AccountAssignment, nameFn func(awsssolib.AccountAssignment) string, sep string) []string {
+	names := make([]string, len(assignments))
+	seen := make(map[string]int)
+	for i, a := range assignments {
+		name := nameFn(a)
+		seen[name]++
+		if seen[name] > 1 {
+			name = fmt.Sprintf("%s%s%d", name, sep, seen[name])
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// terraformName converts an assignment into a Terraform resource name
+func terraformName(a awsssolib.AccountAssignment) string {
+	name := strings.ToLower(nonIdentifierChars.ReplaceAllString(assignmentResourceName(a), "_"))
+	name = strings.Trim(name, "_")
+	// Terraform names must start with a letter or underscore
+	return "assignment_" + name
+}
+
+// cloudFormationName converts an assignment into a CloudFormation logical ID
+func cloudFormationName(a awsssolib.AccountAssignment) string {
+	var b strings.Builder
+	b.WriteString("Assignment")
+	for _, part := range nonIdentifierChars.Split(assignmentResourceName(a), -1) {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]))
+		b.WriteString(strings.ToLower(part[1:]))
+	}
+	return b.String()
+}
+
+// writeAssignmentsTerraform writes assignments as aws_ssoadmin_account_assignment resources
+func writeAssignmentsTerraform(w io.Writer, assignments []awsssolib.AccountAssignment) error {
+	names := uniqueNames(assignments, terraformName, "_")
+
+	for i, a := range assignments {
+		_, err := fmt.Fprintf(w, `resource "aws_ssoadmin_account_assignment" %q {
+  instance_arn       = %q
+  permission_set_arn = %q
+  principal_id       = %q
+  principal_type     = %q
+  target_id          = %q
+  target_type        = "AWS_ACCOUNT"
+}
+
+`, names[i], a.InstanceARN, a.PermissionSetARN, a.PrincipalID, a.PrincipalType, a.AccountID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cloudFormationResource is an AWS::SSO::Assignment resource in a CloudFormation template
+type cloudFormationResource struct {
+	Type       string            `json:"Type"`
+	Properties map[string]string `json:"Properties"`
+}
+
+// writeAssignmentsCloudFormation writes assignments as a CloudFormation template of AWS::SSO::Assignment resources
+func writeAssignmentsCloudFormation(w io.Writer, assignments []awsssolib.AccountAssignment) error {
+	// Logical IDs must be alphanumeric
+	names := uniqueNames(assignments, cloudFormationName, "")
+	resources := make(map[string]cloudFormationResource, len(assignments))
+
+	for i, a := range assignments {
+		resources[names[i]] = cloudFormationResource{
+			Type: "AWS::SSO::Assignment",
+			Properties: map[string]string{
+				"InstanceArn":      a.InstanceARN,
+				"PermissionSetArn": a.PermissionSetARN,
+				"PrincipalId":      a.PrincipalID,
+				"PrincipalType":    a.PrincipalType,
+				"TargetId":         a.AccountID,
+				"TargetType":       "AWS_ACCOUNT",
+			},
+		}
+	}
+
+	template := map[string]interface{}{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Description":              "IAM Identity Center account assignments",
+		"Resources":                resources,
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(template)
+}
@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+var testAssignments = []awsssolib.AccountAssignment{
+	{
+		InstanceARN:       "arn:aws:sso:::instance/ssoins-1234567890abcdef",
+		PermissionSetARN:  "arn:aws:sso:::permissionSet/ssoins-1234567890abcdef/ps-1234567890abcdef",
+		PermissionSetName: "AdministratorAccess",
+		PrincipalType:     "GROUP",
+		PrincipalID:       "9067b1e2c1-5f3b2a1c-1111-2222-3333-444455556666",
+		AccountID:         "123456789012",
+	},
+	{
+		InstanceARN:       "arn:aws:sso:::instance/ssoins-1234567890abcdef",
+		PermissionSetARN:  "arn:aws:sso:::permissionSet/ssoins-1234567890abcdef/ps-1234567890abcdef",
+		PermissionSetName: "AdministratorAccess",
+		PrincipalType:     "GROUP",
+		PrincipalID:       "9067b1e2c1-5f3b2a1c-aaaa-bbbb-cccc-ddddeeeeffff",
+		AccountID:         "123456789012",
+	},
+}
+
+func TestWriteAssignmentsTerraform(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeAssignmentsTerraform(&buf, testAssignments); err != nil {
+		t.Fatalf("writeAssignmentsTerraform failed: %v", err)
+	}
+	output := buf.String()
+
+	expected := []string{
+		`resource "aws_ssoadmin_account_assignment" "assignment_administratoraccess_123456789012_group_9067b1e2" {`,
+		`resource "aws_ssoadmin_account_assignment" "assignment_administratoraccess_123456789012_group_9067b1e2_2" {`,
+		`permission_set_arn = "arn:aws:sso:::permissionSet/ssoins-1234567890abcdef/ps-1234567890abcdef"`,
+		`principal_id       = "9067b1e2c1-5f3b2a1c-aaaa-bbbb-cccc-ddddeeeeffff"`,
+		`target_id          = "123456789012"`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(output, e) {
+			t.Errorf("Terraform output missing %q:\n%s", e, output)
+		}
+	}
+}
+
+func TestWriteAssignmentsCloudFormation(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeAssignmentsCloudFormation(&buf, testAssignments); err != nil {
+		t.Fatalf("writeAssignmentsCloudFormation failed: %v", err)
+	}
+
+	var template struct {
+		Resources map[string]cloudFormationResource
+	}
+	if err := json.Unmarshal(buf.Bytes(), &template); err != nil {
+		t.Fatalf("Invalid CloudFormation JSON: %v", err)
+	}
+
+	if len(template.Resources) != 2 {
+		t.Fatalf("Expected 2 resources, got %d", len(template.Resources))
+	}
+
+	resource, ok := template.Resources["AssignmentAdministratoraccess123456789012Group9067b1e2"]
+	if !ok {
+		t.Fatalf("Expected resource not found in %v", template.Resources)
+	}
+	if resource.Type != "AWS::SSO::Assignment" {
+		t.Errorf("Expected type AWS::SSO::Assignment, got %s", resource.Type)
+	}
+	if resource.Properties["TargetId"] != "123456789012" || resource.Properties["TargetType"] != "AWS_ACCOUNT" {
+		t.Errorf("Unexpected target properties: %v", resource.Properties)
+	}
+	if _, ok := template.Resources["AssignmentAdministratoraccess123456789012Group9067b1e22"]; !ok {
+		t.Errorf("Expected duplicate logical ID to get an alphanumeric suffix, got %v", template.Resources)
+	}
+}
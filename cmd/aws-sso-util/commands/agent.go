@@ -0,0 +1,185 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// NewAgentCommand creates the agent command
+func NewAgentCommand() *cobra.Command {
+	var profileName string
+	var accountID string
+	var roleName string
+	var region string
+	var port int
+	var login bool
+
+	cmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Serve SSO credentials to SDKs as a container credentials endpoint",
+		Long: `Serve SSO credentials to SDKs as a container credentials endpoint.
+
+The agent serves the credentials of an account and role over HTTP on
+127.0.0.1, in the format of the ECS container credentials provider, until it
+is interrupted. Any AWS SDK or tool started with the printed
+AWS_CONTAINER_CREDENTIALS_FULL_URI and AWS_CONTAINER_AUTHORIZATION_TOKEN gets
+SSO credentials without a profile. The credentials are refreshed as they
+expire, as long as the SSO session is valid.
+
+Requests must carry the authorization token, which is generated at startup.
+SDKs only accept loopback HTTP endpoints for these credentials, so the agent
+doesn't listen on other addresses or on a Unix socket. A /health endpoint
+answers without the token.
+
+Examples:
+  # Serve the credentials of a profile
+  aws-sso-util agent --profile prod
+
+  # Serve the credentials of an account and role on a fixed port
+  aws-sso-util agent --account 123456789012 --role ReadOnly --port 9911
+
+  # Then, in another shell, paste the printed exports and run any tool
+  aws s3 ls`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			var profileRegion string
+			if profileName != "" {
+				config, err := awsssolib.LoadConfigFile("")
+				if err != nil {
+					return fmt.Errorf("failed to load config: %w", err)
+				}
+				profile, err := config.ResolveProfile(profileName)
+				if err != nil {
+					return err
+				}
+				if accountID == "" {
+					accountID = profile.AccountID
+				}
+				if roleName == "" {
+					roleName = profile.RoleName
+				}
+				profileRegion = profile.Region
+			}
+			if accountID == "" || roleName == "" {
+				return usageErrorf("--account and --role (or --profile) are required")
+			}
+
+			startURL, ssoRegion, err := resolveInstance(cmd, profileName)
+			if err != nil {
+				return err
+			}
+
+			cfg, err := awsssolib.GetAWSConfig(ctx, awsssolib.GetAWSConfigInput{
+				StartURL:  startURL,
+				SSORegion: ssoRegion,
+				AccountID: accountID,
+				RoleName:  roleName,
+				Region: awsssolib.ResolveRegion(awsssolib.RegionSources{
+					Flag:      region,
+					Profile:   profileRegion,
+					SSORegion: ssoRegion,
+				}),
+				Login:  login,
+				Config: libConfig,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to get AWS config: %w", err)
+			}
+
+			// Fail at startup rather than on the first request
+			if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+				return fmt.Errorf("failed to get credentials: %w", err)
+			}
+
+			token, err := newAgentToken()
+			if err != nil {
+				return err
+			}
+			handler, err := awsssolib.NewContainerCredentialsHandler(cfg.Credentials, token)
+			if err != nil {
+				return err
+			}
+
+			listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+			if err != nil {
+				return fmt.Errorf("failed to listen: %w", err)
+			}
+
+			printAgentEnv(os.Stdout, "http://"+listener.Addr().String()+"/credentials", token)
+			fmt.Fprintf(os.Stderr, "Serving credentials for %s/%s, press Ctrl+C to stop\n", accountID, roleName)
+
+			return serveAgent(ctx, listener, handler)
+		},
+	}
+
+	cmd.Flags().StringVar(&profileName, "profile", "", "AWS profile to read the SSO configuration, account, role and region from")
+	cmd.Flags().StringVar(&accountID, "account", "", "AWS account ID")
+	cmd.Flags().StringVar(&roleName, "role", "", "Role name")
+	cmd.Flags().StringVar(&region, "region", "", "AWS region (default: profile region, AWS_REGION, AWS_DEFAULT_REGION, SSO region, then us-east-1)")
+	cmd.Flags().IntVar(&port, "port", 0, "Port to listen on at 127.0.0.1 (default: any free port)")
+	cmd.Flags().BoolVar(&login, "login", false, "Login if needed")
+
+	return cmd
+}
+
+// newAgentToken returns a random authorization token for the agent
+func newAgentToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate authorization token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// printAgentEnv writes the shell exports pointing SDKs at the agent
+func printAgentEnv(w io.Writer, uri, token string) {
+	fmt.Fprintf(w, "export AWS_CONTAINER_CREDENTIALS_FULL_URI=%s\n", shellQuote(uri))
+	fmt.Fprintf(w, "export AWS_CONTAINER_AUTHORIZATION_TOKEN=%s\n", shellQuote(token))
+}
+
+// serveAgent serves handler on listener until ctx is done, then shuts down
+func serveAgent(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
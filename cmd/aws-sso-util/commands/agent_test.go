@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPrintAgentEnv(t *testing.T) {
+	var buf bytes.Buffer
+	printAgentEnv(&buf, "http://127.0.0.1:9911/credentials", "abc123")
+
+	want := "export AWS_CONTAINER_CREDENTIALS_FULL_URI=http://127.0.0.1:9911/credentials\n" +
+		"export AWS_CONTAINER_AUTHORIZATION_TOKEN=abc123\n"
+	if buf.String() != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	token, err := newAgentToken()
+	if err != nil || len(token) != 64 {
+		t.Errorf("Expected a random 64 character token, got %q (%v)", token, err)
+	}
+	if other, _ := newAgentToken(); other == token {
+		t.Error("Expected a different token each time")
+	}
+}
+
+func TestServeAgent(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveAgent(ctx, listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+	}()
+
+	resp, err := http.Get("http://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("Expected the handler to answer, got %d", resp.StatusCode)
+	}
+
+	// Cancelling the context stops the server cleanly
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the server to stop")
+	}
+}
@@ -0,0 +1,275 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// NewCacheCommand creates the cache command group
+func NewCacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and clean the SSO token and credential caches",
+		Long: `Inspect and clean the SSO token and credential caches.
+
+These commands make no network calls.`,
+	}
+
+	cmd.AddCommand(newCacheStatusCommand())
+	cmd.AddCommand(newCachePurgeCommand())
+
+	return cmd
+}
+
+// cacheStatus is the state of the caches reported by cache status
+type cacheStatus struct {
+	SSOCacheDir string          `json:"ssoCacheDir"`
+	TokenFiles  int             `json:"tokenFiles"`
+	Sessions    []cachedSession `json:"sessions"`
+	Credentials credentialCache `json:"credentialCache"`
+}
+
+// cachedSession is a cached SSO token in the cache status
+type cachedSession struct {
+	StartURL  string               `json:"startUrl"`
+	Region    string               `json:"region"`
+	State     awsssolib.TokenState `json:"state"`
+	ExpiresAt time.Time            `json:"expiresAt"`
+	Path      string               `json:"path"`
+}
+
+// credentialCache summarizes the credential cache in the cache status
+type credentialCache struct {
+	Dir     string `json:"dir"`
+	Entries int    `json:"entries"`
+	Expired int    `json:"expired"`
+	// NextExpiry is the soonest expiry of the unexpired entries
+	NextExpiry *time.Time `json:"nextExpiry,omitempty"`
+}
+
+// newCacheStatusCommand creates the cache status command
+func newCacheStatusCommand() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the cached SSO sessions and credentials",
+		Long: `Show the cached SSO sessions and credentials.
+
+Reports the SSO cache directory, the number of token files in it, the start
+URLs with a cached token and whether the token is still valid, and the number
+of credential cache entries with the soonest expiry.
+
+Examples:
+  # Show the cache status
+  aws-sso-util cache status
+
+  # Show the cache status as JSON, e.g. for a support request
+  aws-sso-util cache status --format json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "table" && format != "json" {
+				return usageErrorf("unsupported format %q: use table or json", format)
+			}
+
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			status, err := readCacheStatus(awsssolib.SSOCacheDir(), awsssolib.CLICacheDir(), libConfig, time.Now())
+			if err != nil {
+				return err
+			}
+
+			if format == "json" {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(status)
+			}
+			return printCacheStatus(os.Stdout, status, time.Now())
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+// readCacheStatus reads the state of the SSO cache in ssoDir and the
+// credential cache in credentialDir at now
+func readCacheStatus(ssoDir, credentialDir string, cfg *awsssolib.Config, now time.Time) (*cacheStatus, error) {
+	status := &cacheStatus{SSOCacheDir: ssoDir, Sessions: []cachedSession{}}
+
+	// Every token file is a session; other files, such as the client
+	// registrations of the AWS CLI, aren't counted
+	sessions, err := awsssolib.ListCachedSessions(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cached sessions: %w", err)
+	}
+	status.TokenFiles = len(sessions)
+	for _, session := range sessions {
+		status.Sessions = append(status.Sessions, cachedSession{
+			StartURL:  session.StartURL,
+			Region:    session.Region,
+			State:     session.State,
+			ExpiresAt: session.ExpiresAt,
+			Path:      session.Path,
+		})
+	}
+
+	status.Credentials, err = readCredentialCache(credentialDir, now)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// readCredentialCache counts the entries of the credential cache in dir.
+// Entries may be in the AWS CLI format, with the expiry under Credentials, or
+// in this library's format; files without an expiry are skipped.
+func readCredentialCache(dir string, now time.Time) (credentialCache, error) {
+	cache := credentialCache{Dir: dir}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return cache, err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var entry struct {
+			Expiration  time.Time
+			Credentials struct {
+				Expiration time.Time
+			}
+		}
+		if json.Unmarshal(data, &entry) != nil {
+			continue
+		}
+		expiration := entry.Expiration
+		if expiration.IsZero() {
+			expiration = entry.Credentials.Expiration
+		}
+		if expiration.IsZero() {
+			continue
+		}
+
+		cache.Entries++
+		if !now.Before(expiration) {
+			cache.Expired++
+			continue
+		}
+		if cache.NextExpiry == nil || expiration.Before(*cache.NextExpiry) {
+			cache.NextExpiry = &expiration
+		}
+	}
+	return cache, nil
+}
+
+// printCacheStatus writes the cache status for people
+func printCacheStatus(w io.Writer, status *cacheStatus, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "SSO cache:\t%s\n", status.SSOCacheDir)
+	fmt.Fprintf(tw, "Token files:\t%d\n", status.TokenFiles)
+	fmt.Fprintf(tw, "Credential cache:\t%s\n", status.Credentials.Dir)
+
+	credentials := fmt.Sprintf("%d (%d expired)", status.Credentials.Entries, status.Credentials.Expired)
+	if next := status.Credentials.NextExpiry; next != nil {
+		credentials += fmt.Sprintf(", next expiry %s (in %s)",
+			next.Local().Format("2006-01-02 15:04:05"), next.Sub(now).Round(time.Second))
+	}
+	fmt.Fprintf(tw, "Credentials:\t%s\n", credentials)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	if len(status.Sessions) == 0 {
+		fmt.Fprintln(w, "No cached SSO sessions")
+		return nil
+	}
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "START URL\tREGION\tSTATE\tEXPIRES")
+	fmt.Fprintln(tw, "---------\t------\t-----\t-------")
+	for _, session := range status.Sessions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", session.StartURL, session.Region, session.State,
+			session.ExpiresAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	return tw.Flush()
+}
+
+// newCachePurgeCommand creates the cache purge command
+func newCachePurgeCommand() *cobra.Command {
+	var expired bool
+	var all bool
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Remove expired SSO tokens and cached credentials",
+		Long: `Remove expired SSO tokens and cached credentials.
+
+By default, or with --expired, token and credential cache files that have
+expired are removed. An expired token is kept while its refresh token can
+still renew it. With --all, every token and credential cache file is removed,
+which logs you out of all SSO sessions.
+
+Only files named like the AWS CLI cache files that hold a token or
+credentials are removed; other files in the cache directories are left alone.
+
+Examples:
+  # Show what would be removed
+  aws-sso-util cache purge --dry-run
+
+  # Remove expired cache files
+  aws-sso-util cache purge --expired
+
+  # Remove all cached tokens and credentials
+  aws-sso-util cache purge --all`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			purged, err := awsssolib.PurgeCache(awsssolib.PurgeCacheOptions{All: all, DryRun: dryRun})
+			if err != nil {
+				return fmt.Errorf("failed to purge cache: %w", err)
+			}
+
+			for _, path := range purged {
+				if dryRun {
+					fmt.Fprintf(os.Stderr, "Would remove %s\n", path)
+				} else {
+					fmt.Fprintf(os.Stderr, "Removed %s\n", path)
+				}
+			}
+
+			switch {
+			case len(purged) == 0:
+				fmt.Fprintln(os.Stderr, "Nothing to purge")
+			case dryRun:
+				fmt.Fprintf(os.Stderr, "\n%d cache files would be removed (dry run, nothing removed)\n", len(purged))
+			default:
+				fmt.Fprintf(os.Stderr, "\nRemoved %d cache files\n", len(purged))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&expired, "expired", false, "Remove expired cache files (default)")
+	cmd.Flags().BoolVar(&all, "all", false, "Remove all token and credential cache files")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be removed without removing anything")
+	cmd.MarkFlagsMutuallyExclusive("expired", "all")
+
+	return cmd
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+func TestCacheStatus(t *testing.T) {
+	ssoDir := t.TempDir()
+	credentialDir := t.TempDir()
+	t.Setenv("AWS_SSO_CACHE_DIR", ssoDir)
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	err := awsssolib.PutCachedToken(nil, "https://test.awsapps.com/start", &awsssolib.Token{
+		AccessToken: "token",
+		ExpiresAt:   now.Add(time.Hour),
+		Region:      "us-east-1",
+	})
+	if err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(ssoDir, "botocore-client-id-us-east-1.json"), []byte(`{"clientId":"id"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries of the AWS CLI and of this library, one expired
+	entries := map[string]string{
+		"cli.json":     `{"Credentials":{"Expiration":"` + now.Add(30*time.Minute).Format(time.RFC3339) + `"}}`,
+		"lib.json":     `{"Expiration":"` + now.Add(2*time.Hour).Format(time.RFC3339) + `"}`,
+		"expired.json": `{"Expiration":"` + now.Add(-time.Hour).Format(time.RFC3339) + `"}`,
+		"other.json":   `{"something":"else"}`,
+	}
+	for name, data := range entries {
+		if err := os.WriteFile(filepath.Join(credentialDir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status, err := readCacheStatus(ssoDir, credentialDir, nil, now)
+	if err != nil {
+		t.Fatalf("readCacheStatus failed: %v", err)
+	}
+	if status.TokenFiles != 1 || len(status.Sessions) != 1 {
+		t.Fatalf("Expected 1 token file and 1 session, got %+v", status)
+	}
+	if session := status.Sessions[0]; session.StartURL != "https://test.awsapps.com/start" || session.State != awsssolib.TokenStateValid {
+		t.Errorf("Expected a valid session, got %+v", session)
+	}
+	credentials := status.Credentials
+	if credentials.Entries != 3 || credentials.Expired != 1 || credentials.NextExpiry == nil || !credentials.NextExpiry.Equal(now.Add(30*time.Minute)) {
+		t.Errorf("Expected 3 entries, 1 expired, the next expiring in 30 minutes, got %+v", credentials)
+	}
+
+	var buf bytes.Buffer
+	if err := printCacheStatus(&buf, status, now); err != nil {
+		t.Fatalf("printCacheStatus failed: %v", err)
+	}
+	for _, want := range []string{"Token files:       1", "3 (1 expired), next expiry", "(in 30m0s)", "https://test.awsapps.com/start  us-east-1  valid"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, buf.String())
+		}
+	}
+}
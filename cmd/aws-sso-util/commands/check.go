@@ -3,6 +3,7 @@ package commands
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/adonmo/aws-sso-lib-go/awsssolib"
@@ -12,27 +13,38 @@ import (
 // NewCheckCommand creates the check command
 func NewCheckCommand() *cobra.Command {
 	var accountID string
-	var roleName string
+	var roleNames []string
 
 	cmd := &cobra.Command{
 		Use:   "check",
 		Short: "Check SSO configuration and access",
 		Long: `Check SSO configuration and validate access to specific accounts/roles.
 
-This command helps diagnose SSO configuration issues and verify access.
+This command helps diagnose SSO configuration issues and verify access. It
+also warns about profiles whose credential_process references a missing
+profile or an executable that is not on PATH.
 
 Examples:
   # Check SSO configuration
   aws-sso-util check
 
-  # Check access to specific account
+  # Check access to specific account and each of its roles
   aws-sso-util check --account 123456789012
 
-  # Check access to specific role
-  aws-sso-util check --account 123456789012 --role MyRole`,
+  # Check access to specific roles
+  aws-sso-util check --account 123456789012 --role MyRole --role ReadOnly
+
+With --account, the roles are checked by retrieving their credentials, which
+confirms access without using them. Without --role, every role of the account
+is checked. The command fails when a checked role can't be accessed.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
 
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
+
 			// Get SSO configuration
 			startURL, _ := cmd.Flags().GetString("start-url")
 			ssoRegion, _ := cmd.Flags().GetString("sso-region")
@@ -63,27 +75,42 @@ Examples:
 				fmt.Fprintf(os.Stderr, "  (configured via %s)\n", instance.StartURLSource)
 			}
 
+			// Check credential_process profiles
+			fmt.Fprintln(os.Stderr, "\nChecking profiles...")
+			checkProfiles()
+
 			// Check cached token
 			fmt.Fprintln(os.Stderr, "\nChecking authentication status...")
-			token, err := awsssolib.GetCachedToken(nil, startURL)
+			status, err := awsssolib.NewTokenCache(libConfig).InspectCachedToken(startURL)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "❌ Error checking token: %v\n", err)
-			} else if token == nil {
-				fmt.Fprintln(os.Stderr, "❌ Not logged in")
-				fmt.Fprintln(os.Stderr, "   Run: aws-sso-util login")
 			} else {
-				fmt.Fprintln(os.Stderr, "✓ Logged in")
-				fmt.Fprintf(os.Stderr, "  Token expires: %s\n", token.ExpiresAt.Format("2006-01-02 15:04:05"))
+				switch status.State {
+				case awsssolib.TokenStateMissing:
+					fmt.Fprintln(os.Stderr, "❌ Not logged in")
+					fmt.Fprintln(os.Stderr, "   Run: aws-sso-util login")
+				case awsssolib.TokenStateExpiring:
+					fmt.Fprintf(os.Stderr, "❌ Session expires at %s, too soon to be used\n", status.ExpiresAt.Local().Format("2006-01-02 15:04:05"))
+					fmt.Fprintln(os.Stderr, "   Run: aws-sso-util login")
+				case awsssolib.TokenStateExpired:
+					fmt.Fprintf(os.Stderr, "❌ Session expired at %s\n", status.ExpiresAt.Local().Format("2006-01-02 15:04:05"))
+					fmt.Fprintln(os.Stderr, "   Run: aws-sso-util login")
+				default:
+					fmt.Fprintln(os.Stderr, "✓ Logged in")
+					fmt.Fprintf(os.Stderr, "  Token expires: %s\n", status.ExpiresAt.Format("2006-01-02 15:04:05"))
+				}
 			}
 
 			// If logged in, check access
-			if token != nil {
+			accountFound := false
+			if status != nil && status.State == awsssolib.TokenStateValid {
 				fmt.Fprintln(os.Stderr, "\nChecking account access...")
 
 				// List accounts
 				accounts, err := awsssolib.ListAvailableAccounts(ctx, awsssolib.ListAccountsInput{
 					StartURL:  startURL,
 					SSORegion: ssoRegion,
+					Config:    libConfig,
 				})
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "❌ Failed to list accounts: %v\n", err)
@@ -92,42 +119,40 @@ Examples:
 
 					// Check specific account if provided
 					if accountID != "" {
-						found := false
-						for _, acc := range accounts {
-							if acc.AccountID == accountID {
-								found = true
-								fmt.Fprintf(os.Stderr, "✓ Access to account %s (%s)\n", accountID, acc.AccountName)
-								break
+						resolved, err := awsssolib.ResolveAccountID(accounts, accountID)
+						if err != nil {
+							fmt.Fprintf(os.Stderr, "❌ No access to account %s: %v\n", accountID, err)
+						} else {
+							accountID = resolved
+							accountFound = true
+							for _, acc := range accounts {
+								if acc.AccountID == accountID {
+									fmt.Fprintf(os.Stderr, "✓ Access to account %s (%s)\n", accountID, acc.AccountName)
+									break
+								}
 							}
 						}
-						if !found {
-							fmt.Fprintf(os.Stderr, "❌ No access to account %s\n", accountID)
-						}
 					}
 				}
 
-				// Check roles if account specified
-				if accountID != "" && roleName != "" {
+				// Check the requested roles, or all roles, of the account
+				if accountFound {
 					fmt.Fprintln(os.Stderr, "\nChecking role access...")
 
 					roles, err := awsssolib.ListAvailableRoles(ctx, awsssolib.ListRolesInput{
 						StartURL:   startURL,
 						SSORegion:  ssoRegion,
 						AccountIDs: []string{accountID},
+						Config:     libConfig,
 					})
 					if err != nil {
 						fmt.Fprintf(os.Stderr, "❌ Failed to list roles: %v\n", err)
 					} else {
-						found := false
-						for _, role := range roles {
-							if role.RoleName == roleName {
-								found = true
-								fmt.Fprintf(os.Stderr, "✓ Access to role %s in account %s\n", roleName, accountID)
-								break
-							}
-						}
-						if !found {
-							fmt.Fprintf(os.Stderr, "❌ No access to role %s in account %s\n", roleName, accountID)
+						failed := checkRoles(os.Stderr, roles, accountID, roleNames, func(roleName string) error {
+							return checkRoleCredentials(ctx, libConfig, startURL, ssoRegion, accountID, roleName)
+						})
+						if failed > 0 {
+							return fmt.Errorf("%d role checks failed", failed)
 						}
 					}
 				}
@@ -137,8 +162,90 @@ Examples:
 		},
 	}
 
-	cmd.Flags().StringVar(&accountID, "account", "", "Check access to specific account")
-	cmd.Flags().StringVar(&roleName, "role", "", "Check access to specific role (requires --account)")
+	cmd.Flags().StringVar(&accountID, "account", "", "Check access to specific account (or a unique prefix/suffix of its ID)")
+	cmd.Flags().StringArrayVar(&roleNames, "role", nil, "Check access to specific role, can be repeated (requires --account; default: all roles of the account)")
+
+	registerAccountRoleCompletion(cmd)
 
 	return cmd
 }
+
+// checkRoles writes the access status of the requested roles of an account,
+// or of all its roles when none are requested, and returns the number of
+// roles without access. Requested role names are matched case-insensitively,
+// and access is confirmed with verify.
+func checkRoles(w io.Writer, roles []awsssolib.Role, accountID string, requested []string, verify func(roleName string) error) int {
+	names := requested
+	if len(names) == 0 {
+		for _, role := range roles {
+			if role.AccountID == accountID {
+				names = append(names, role.RoleName)
+			}
+		}
+		if len(names) == 0 {
+			fmt.Fprintf(w, "❌ No roles in account %s\n", accountID)
+			return 1
+		}
+	}
+
+	failed := 0
+	for _, name := range names {
+		resolved, err := awsssolib.ResolveRoleName(roles, accountID, name)
+		if err == nil {
+			err = verify(resolved)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "❌ No access to role %s in account %s: %v\n", name, accountID, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(w, "✓ Access to role %s in account %s\n", resolved, accountID)
+	}
+	return failed
+}
+
+// checkRoleCredentials confirms access to a role by retrieving its
+// credentials, which are then discarded
+func checkRoleCredentials(ctx context.Context, libConfig *awsssolib.Config, startURL, ssoRegion, accountID, roleName string) error {
+	cfg, err := awsssolib.GetAWSConfig(ctx, awsssolib.GetAWSConfigInput{
+		StartURL:  startURL,
+		SSORegion: ssoRegion,
+		AccountID: accountID,
+		RoleName:  roleName,
+		Region:    awsssolib.ResolveRegion(awsssolib.RegionSources{SSORegion: ssoRegion}),
+		Config:    libConfig,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = cfg.Credentials.Retrieve(ctx)
+	return err
+}
+
+// checkProfiles warns about profiles with a broken credential_process
+func checkProfiles() {
+	config, err := awsssolib.LoadConfigFile("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load config: %v\n", err)
+		return
+	}
+
+	checked := 0
+	for _, name := range config.ListProfiles() {
+		profile := config.GetProfile(name)
+		if profile.CredProcess == "" {
+			continue
+		}
+		checked++
+
+		if err := profile.Validate(config); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "✓ Profile %s credential_process is valid\n", name)
+	}
+
+	if checked == 0 {
+		fmt.Fprintln(os.Stderr, "  No profiles use credential_process")
+	}
+}
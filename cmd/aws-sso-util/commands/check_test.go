@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+func TestCheckRoles(t *testing.T) {
+	roles := []awsssolib.Role{
+		{AccountID: "123456789012", RoleName: "Admin"},
+		{AccountID: "123456789012", RoleName: "ReadOnly"},
+		{AccountID: "210987654321", RoleName: "Billing"},
+	}
+	var verified []string
+	verify := func(roleName string) error {
+		verified = append(verified, roleName)
+		if roleName == "ReadOnly" {
+			return errors.New("ForbiddenException: No access")
+		}
+		return nil
+	}
+
+	// Without requested roles, every role of the account is checked
+	var buf bytes.Buffer
+	if failed := checkRoles(&buf, roles, "123456789012", nil, verify); failed != 1 {
+		t.Errorf("Expected 1 failure, got %d", failed)
+	}
+	if strings.Join(verified, ",") != "Admin,ReadOnly" {
+		t.Errorf("Expected the roles of the account to be verified, got %v", verified)
+	}
+	for _, want := range []string{"✓ Access to role Admin in account 123456789012", "❌ No access to role ReadOnly in account 123456789012: ForbiddenException"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, buf.String())
+		}
+	}
+
+	// Requested roles are matched ignoring case, unknown ones aren't verified
+	buf.Reset()
+	verified = nil
+	if failed := checkRoles(&buf, roles, "123456789012", []string{"admin", "Billing"}, verify); failed != 1 {
+		t.Errorf("Expected 1 failure, got %d", failed)
+	}
+	if strings.Join(verified, ",") != "Admin" {
+		t.Errorf("Expected only the known role to be verified, got %v", verified)
+	}
+	if !strings.Contains(buf.String(), "❌ No access to role Billing") {
+		t.Errorf("Expected the role of another account to be reported, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if failed := checkRoles(&buf, roles, "555555555555", nil, verify); failed != 1 || !strings.Contains(buf.String(), "No roles") {
+		t.Errorf("Expected an account without roles to be reported, got %d:\n%s", failed, buf.String())
+	}
+}
@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// completionTimeout bounds the SSO calls of a completion so a slow or
+	// missing login doesn't hang the shell
+	completionTimeout = 3 * time.Second
+
+	// completionCacheTTL is how long listed roles are reused for completions
+	completionCacheTTL = 10 * time.Minute
+)
+
+// registerAccountRoleCompletion completes the --account and --role flags of a
+// command with the accounts and roles available through SSO
+func registerAccountRoleCompletion(cmd *cobra.Command) {
+	cmd.RegisterFlagCompletionFunc("account", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		roles, err := completionRoles(cmd)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return accountCompletions(roles, toComplete), cobra.ShellCompDirectiveNoFileComp
+	})
+	cmd.RegisterFlagCompletionFunc("role", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		roles, err := completionRoles(cmd)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		accountID, _ := cmd.Flags().GetString("account")
+		return roleCompletions(roles, accountID, toComplete), cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// accountCompletions returns the account IDs starting with toComplete, with
+// the account names as descriptions
+func accountCompletions(roles []awsssolib.Role, toComplete string) []string {
+	var completions []string
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		if seen[role.AccountID] || !strings.HasPrefix(role.AccountID, toComplete) {
+			continue
+		}
+		seen[role.AccountID] = true
+		completions = append(completions, role.AccountID+"\t"+role.AccountName)
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+// roleCompletions returns the role names starting with toComplete, limited to
+// the account when one is given
+func roleCompletions(roles []awsssolib.Role, accountID, toComplete string) []string {
+	if accountID != "" {
+		var accounts []awsssolib.Account
+		for _, group := range awsssolib.GroupRolesByAccount(roles) {
+			accounts = append(accounts, group.Account)
+		}
+		if resolved, err := awsssolib.ResolveAccountID(accounts, accountID); err == nil {
+			accountID = resolved
+		} else {
+			accountID = ""
+		}
+	}
+
+	var completions []string
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		if accountID != "" && role.AccountID != accountID {
+			continue
+		}
+		if seen[role.RoleName] || !strings.HasPrefix(strings.ToLower(role.RoleName), strings.ToLower(toComplete)) {
+			continue
+		}
+		seen[role.RoleName] = true
+		completions = append(completions, role.RoleName)
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+// completionRoles returns the roles of the SSO instance of the command, from
+// the completion cache when it is fresh. It never logs in.
+func completionRoles(cmd *cobra.Command) ([]awsssolib.Role, error) {
+	profileName, _ := cmd.Flags().GetString("profile")
+	startURL, ssoRegion, err := findInstance(cmd, profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	path := completionCachePath(startURL)
+	if roles, err := readCompletionCache(path, time.Now()); err == nil {
+		return roles, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	roles, err := awsssolib.ListAvailableRoles(ctx, awsssolib.ListRolesInput{
+		StartURL:  startURL,
+		SSORegion: ssoRegion,
+		Config:    &awsssolib.Config{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	writeCompletionCache(path, roles)
+	return roles, nil
+}
+
+// completionCachePath returns the completion cache file of a start URL
+func completionCachePath(startURL string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "aws-sso-util", fmt.Sprintf("completion-%x.json", sha1.Sum([]byte(startURL))))
+}
+
+// readCompletionCache reads the roles cached at path unless they are older
+// than completionCacheTTL
+func readCompletionCache(path string, now time.Time) ([]awsssolib.Role, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if now.Sub(info.ModTime()) > completionCacheTTL {
+		return nil, fmt.Errorf("completion cache is stale")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []awsssolib.Role
+	if err := json.Unmarshal(data, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// writeCompletionCache caches roles at path. Failures only make later
+// completions slower, so they are ignored.
+func writeCompletionCache(path string, roles []awsssolib.Role) {
+	data, err := json.Marshal(roles)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+var completionTestRoles = []awsssolib.Role{
+	{AccountID: "123456789012", AccountName: "prod", RoleName: "Admin"},
+	{AccountID: "123456789012", AccountName: "prod", RoleName: "ReadOnly"},
+	{AccountID: "210987654321", AccountName: "dev", RoleName: "Developer"},
+	{AccountID: "210987654321", AccountName: "dev", RoleName: "Admin"},
+}
+
+func TestAccountCompletions(t *testing.T) {
+	got := accountCompletions(completionTestRoles, "")
+	want := []string{"123456789012\tprod", "210987654321\tdev"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	got = accountCompletions(completionTestRoles, "21")
+	if !reflect.DeepEqual(got, []string{"210987654321\tdev"}) {
+		t.Errorf("Expected only the dev account, got %q", got)
+	}
+}
+
+func TestRoleCompletions(t *testing.T) {
+	if got := roleCompletions(completionTestRoles, "", ""); !reflect.DeepEqual(got, []string{"Admin", "Developer", "ReadOnly"}) {
+		t.Errorf("Expected all role names once, got %q", got)
+	}
+	if got := roleCompletions(completionTestRoles, "9012", ""); !reflect.DeepEqual(got, []string{"Admin", "ReadOnly"}) {
+		t.Errorf("Expected the roles of the prod account, got %q", got)
+	}
+	if got := roleCompletions(completionTestRoles, "", "re"); !reflect.DeepEqual(got, []string{"ReadOnly"}) {
+		t.Errorf("Expected a case-insensitive prefix match, got %q", got)
+	}
+}
+
+func TestCompletionCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aws-sso-util", "completion.json")
+
+	if _, err := readCompletionCache(path, time.Now()); err == nil {
+		t.Error("Expected an error for a missing cache")
+	}
+
+	writeCompletionCache(path, completionTestRoles)
+	roles, err := readCompletionCache(path, time.Now())
+	if err != nil {
+		t.Fatalf("readCompletionCache failed: %v", err)
+	}
+	if !reflect.DeepEqual(roles, completionTestRoles) {
+		t.Errorf("Expected the cached roles, got %+v", roles)
+	}
+
+	if _, err := readCompletionCache(path, time.Now().Add(completionCacheTTL+time.Minute)); err == nil {
+		t.Error("Expected a stale cache to be ignored")
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a private cache file, got %v (%v)", info.Mode().Perm(), err)
+	}
+}
@@ -3,11 +3,16 @@ package commands
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/spf13/cobra"
 )
 
@@ -16,11 +21,20 @@ func NewConfigureCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "configure",
 		Short: "Configure AWS CLI profiles",
-		Long:  `Commands to configure AWS CLI profiles for SSO access.`,
+		Long: `Commands to configure AWS CLI profiles for SSO access.
+
+Before the config file is rewritten, the existing file is copied to a
+timestamped <file>.bak.<timestamp> backup. The most recent backups are kept.`,
 	}
 
+	cmd.PersistentFlags().Bool("no-backup", false, "Don't back up the config file before rewriting it")
+
 	cmd.AddCommand(newConfigureProfileCommand())
 	cmd.AddCommand(newConfigurePopulateCommand())
+	cmd.AddCommand(newConfigureResolveCommand())
+	cmd.AddCommand(newConfigureImportCommand())
+	cmd.AddCommand(newConfigurePruneCommand())
+	cmd.AddCommand(newConfigureWriteCredentialsCommand())
 
 	return cmd
 }
@@ -30,6 +44,8 @@ func newConfigureProfileCommand() *cobra.Command {
 	var region string
 	var outputFormat string
 	var credentialProcess bool
+	var accountID string
+	var verify bool
 
 	cmd := &cobra.Command{
 		Use:   "profile <profile-name>",
@@ -37,7 +53,9 @@ func newConfigureProfileCommand() *cobra.Command {
 		Long: `Configure a single AWS CLI profile for SSO access.
 
 This command will interactively prompt you to select an account and role
-from those available through your SSO access.
+from those available through your SSO access. In a terminal, type part of an
+account or role name to narrow the list, then enter the number of the role.
+When input is piped, the full numbered list is shown and one number is read.
 
 Examples:
   # Configure a profile interactively
@@ -47,28 +65,32 @@ Examples:
   aws-sso-util configure profile my-profile --region us-west-2
 
   # Add credential process support
-  aws-sso-util configure profile my-profile --credential-process`,
+  aws-sso-util configure profile my-profile --credential-process
+
+  # Only offer roles in one account, given a unique part of its ID
+  aws-sso-util configure profile my-profile --account 9012
+
+  # Check that the profile works by calling STS GetCallerIdentity with it
+  aws-sso-util configure profile my-profile --verify`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
+
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
 			profileName := args[0]
 
-			// Get SSO configuration
-			startURL, _ := cmd.Flags().GetString("start-url")
-			ssoRegion, _ := cmd.Flags().GetString("sso-region")
+			// Fail before prompting rather than write a broken profile
+			if err := awsssolib.ValidateOutputFormat(outputFormat); err != nil {
+				return err
+			}
 
-			// Try to find configuration if not provided
-			if startURL == "" || ssoRegion == "" {
-				instance, err := awsssolib.FindInstance("")
-				if err != nil {
-					return fmt.Errorf("no SSO configuration found. Please provide --start-url and --sso-region or set AWS_DEFAULT_SSO_START_URL and AWS_DEFAULT_SSO_REGION")
-				}
-				if startURL == "" {
-					startURL = instance.StartURL
-				}
-				if ssoRegion == "" {
-					ssoRegion = instance.Region
-				}
+			// Get SSO configuration
+			startURL, ssoRegion, err := resolveInstance(cmd, "")
+			if err != nil {
+				return err
 			}
 
 			// List available roles
@@ -77,41 +99,42 @@ Examples:
 				StartURL:  startURL,
 				SSORegion: ssoRegion,
 				Login:     true,
+				Config:    libConfig,
 			})
 			if err != nil {
 				return fmt.Errorf("failed to list roles: %w", err)
 			}
 
+			// Narrow the selection to one account
+			if accountID != "" {
+				roles, err = filterRolesByAccount(roles, accountID)
+				if err != nil {
+					return err
+				}
+			}
+
 			if len(roles) == 0 {
 				return fmt.Errorf("no roles available")
 			}
 
-			// Display available roles
-			fmt.Fprintln(os.Stderr, "\nAvailable roles:")
+			// Prompt for selection
+			items := make([]string, len(roles))
 			for i, role := range roles {
-				fmt.Fprintf(os.Stderr, "[%d] %s - %s (%s)\n", i+1, role.AccountID, role.AccountName, role.RoleName)
+				items[i] = fmt.Sprintf("%s - %s (%s)", role.AccountID, role.AccountName, role.RoleName)
 			}
-
-			// Prompt for selection
+			fmt.Fprintf(os.Stderr, "\n%d roles available\n", len(roles))
 			reader := bufio.NewReader(os.Stdin)
-			fmt.Fprint(os.Stderr, "\nSelect a role (enter number): ")
-			input, err := reader.ReadString('\n')
+			selection, err := newPicker(reader, os.Stderr).Pick("Select a role", items)
 			if err != nil {
 				return err
 			}
 
-			var selection int
-			_, err = fmt.Sscanf(strings.TrimSpace(input), "%d", &selection)
-			if err != nil || selection < 1 || selection > len(roles) {
-				return fmt.Errorf("invalid selection")
-			}
-
-			selectedRole := roles[selection-1]
+			selectedRole := roles[selection]
 
 			// If region not specified, prompt for it
 			if region == "" {
 				fmt.Fprint(os.Stderr, "AWS region (e.g., us-east-1): ")
-				input, err = reader.ReadString('\n')
+				input, err := reader.ReadString('\n')
 				if err != nil {
 					return err
 				}
@@ -142,12 +165,24 @@ Examples:
 
 			// Save profile
 			config.SetProfile(profile)
-			err = config.SaveConfigFile("")
+			noBackup, _ := cmd.Flags().GetBool("no-backup")
+			err = config.SaveConfigFileWithOptions("", awsssolib.SaveOptions{NoBackup: noBackup})
 			if err != nil {
 				return fmt.Errorf("failed to save config: %w", err)
 			}
 
 			fmt.Fprintf(os.Stderr, "\nProfile '%s' configured successfully!\n", profileName)
+
+			// A failed verification doesn't undo the profile, it only warns
+			if verify {
+				arn, err := verifyProfile(ctx, profile, libConfig)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: profile '%s' could not be verified: %v\n", profileName, err)
+				} else {
+					fmt.Fprintf(os.Stderr, "Verified profile '%s' as %s\n", profileName, arn)
+				}
+			}
+
 			fmt.Fprintf(os.Stderr, "You can now use: aws --profile %s <command>\n", profileName)
 
 			return nil
@@ -155,18 +190,72 @@ Examples:
 	}
 
 	cmd.Flags().StringVar(&region, "region", "", "AWS region for the profile")
-	cmd.Flags().StringVar(&outputFormat, "output", "json", "Output format (json, text, table)")
+	cmd.Flags().StringVar(&outputFormat, "output", "json", "Output format (json, yaml, yaml-stream, text, table)")
 	cmd.Flags().BoolVar(&credentialProcess, "credential-process", false, "Add credential process configuration")
+	cmd.Flags().StringVar(&accountID, "account", "", "Only offer roles in this account (or a unique prefix/suffix of its ID)")
+	cmd.Flags().BoolVar(&verify, "verify", false, "Check the profile by calling STS GetCallerIdentity with its credentials")
 
 	return cmd
 }
 
+// verifyProfile retrieves the credentials of an SSO profile and calls STS
+// GetCallerIdentity with them, returning the ARN of the caller
+func verifyProfile(ctx context.Context, profile *awsssolib.Profile, libConfig *awsssolib.Config) (string, error) {
+	cfg, err := awsssolib.GetAWSConfig(ctx, awsssolib.GetAWSConfigInput{
+		StartURL:  profile.StartURL,
+		SSORegion: profile.SSORegion,
+		AccountID: profile.AccountID,
+		RoleName:  profile.RoleName,
+		Region:    awsssolib.ResolveRegion(awsssolib.RegionSources{Profile: profile.Region, SSORegion: profile.SSORegion}),
+		Config:    libConfig,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(identity.Arn), nil
+}
+
+// filterRolesByAccount returns the roles of the account matching a full or partial account ID
+func filterRolesByAccount(roles []awsssolib.Role, query string) ([]awsssolib.Role, error) {
+	var accounts []awsssolib.Account
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		if !seen[role.AccountID] {
+			seen[role.AccountID] = true
+			accounts = append(accounts, awsssolib.Account{AccountID: role.AccountID, AccountName: role.AccountName})
+		}
+	}
+
+	accountID, err := awsssolib.ResolveAccountID(accounts, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []awsssolib.Role
+	for _, role := range roles {
+		if role.AccountID == accountID {
+			filtered = append(filtered, role)
+		}
+	}
+	return filtered, nil
+}
+
 // newConfigurePopulateCommand creates the configure populate command
 func newConfigurePopulateCommand() *cobra.Command {
 	var regions []string
 	var profileTemplate string
 	var credentialProcess bool
 	var force bool
+	var outputFile string
+	var preserveCase bool
+	var maxConcurrency int
+	var roleFilter awsssolib.RoleFilter
+	var regionMapFlags []string
 
 	cmd := &cobra.Command{
 		Use:   "populate",
@@ -187,30 +276,59 @@ Examples:
   aws-sso-util configure populate --regions us-east-1 --profile-template "{account_name}-{role_name}-{region}"
 
   # Force overwrite existing profiles
-  aws-sso-util configure populate --regions us-east-1 --force`,
+  aws-sso-util configure populate --regions us-east-1 --force
+
+  # Write generated profiles to a separate file
+  aws-sso-util configure populate --regions us-east-1 --output-file ~/.aws/sso-profiles
+
+  # List the roles of more accounts at once in a large organization
+  aws-sso-util configure populate --regions us-east-1 --max-concurrency 10
+
+  # Use EU regions for one account and --regions for the others
+  aws-sso-util configure populate --regions us-east-1 --region-map 123456789012=eu-west-1,eu-central-1
+
+  # Only create profiles for admin roles in production accounts
+  aws-sso-util configure populate --regions us-east-1 --role-filter '*AdminAccess' --account-filter 'prod-*'
+
+Generated profiles are marked with managed_by = aws-sso-util, so configure
+prune --all-managed can remove them again.
+
+Filters are globs matched ignoring case, or regular expressions when enclosed
+in slashes, such as '/^(Admin|ReadOnly)$/'. Account filters match the account
+ID or name. Filters can be repeated to allow several patterns.
+
+The AWS CLI does not support including other config files. When --output-file
+is used, point AWS_CONFIG_FILE at the generated file to use its profiles.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
 
-			if len(regions) == 0 {
-				return fmt.Errorf("at least one region must be specified with --regions")
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
 			}
 
-			// Get SSO configuration
-			startURL, _ := cmd.Flags().GetString("start-url")
-			ssoRegion, _ := cmd.Flags().GetString("sso-region")
+			regionMap, err := parseRegionMap(regionMapFlags)
+			if err != nil {
+				return err
+			}
+			if len(regions) == 0 && len(regionMap) == 0 {
+				return usageErrorf("at least one region must be specified with --regions or --region-map")
+			}
+			if err := validateMaxConcurrency(maxConcurrency); err != nil {
+				return err
+			}
+			// Check the filter patterns before any listing
+			if _, err := awsssolib.FilterRoles(nil, roleFilter); err != nil {
+				return err
+			}
 
-			// Try to find configuration if not provided
-			if startURL == "" || ssoRegion == "" {
-				instance, err := awsssolib.FindInstance("")
-				if err != nil {
-					return fmt.Errorf("no SSO configuration found. Please provide --start-url and --sso-region or set AWS_DEFAULT_SSO_START_URL and AWS_DEFAULT_SSO_REGION")
-				}
-				if startURL == "" {
-					startURL = instance.StartURL
-				}
-				if ssoRegion == "" {
-					ssoRegion = instance.Region
-				}
+			// Get SSO configuration
+			startURL, ssoRegion, err := resolveInstance(cmd, "")
+			if err != nil {
+				return err
+			}
+			if profileTemplate == "" {
+				profileTemplate = remoteProfileTemplate(ctx, startURL)
 			}
 
 			// List available accounts
@@ -219,6 +337,7 @@ Examples:
 				StartURL:  startURL,
 				SSORegion: ssoRegion,
 				Login:     true,
+				Config:    libConfig,
 			})
 			if err != nil {
 				return fmt.Errorf("failed to list accounts: %w", err)
@@ -227,15 +346,30 @@ Examples:
 			// List available roles
 			fmt.Fprintln(os.Stderr, "Fetching available roles...")
 			roles, err := awsssolib.ListAvailableRoles(ctx, awsssolib.ListRolesInput{
-				StartURL:  startURL,
-				SSORegion: ssoRegion,
+				StartURL:       startURL,
+				SSORegion:      ssoRegion,
+				MaxConcurrency: maxConcurrency,
+				Config:         libConfig,
 			})
 			if err != nil {
 				return fmt.Errorf("failed to list roles: %w", err)
 			}
 
+			roles, err = awsssolib.FilterRoles(roles, roleFilter)
+			if err != nil {
+				return err
+			}
+
+			// Generated profiles reference the output file so credential-process can find them
+			if outputFile != "" {
+				outputFile, err = filepath.Abs(outputFile)
+				if err != nil {
+					return fmt.Errorf("failed to resolve output file: %w", err)
+				}
+			}
+
 			// Load existing config
-			config, err := awsssolib.LoadConfigFile("")
+			config, err := awsssolib.LoadConfigFile(outputFile)
 			if err != nil {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
@@ -256,9 +390,16 @@ Examples:
 					continue
 				}
 
-				for _, region := range regions {
+				accountRegions := regions
+				if mapped, ok := regionMap[role.AccountID]; ok {
+					accountRegions = mapped
+				}
+
+				for _, region := range accountRegions {
 					// Generate profile name
-					profileName := awsssolib.GenerateProfileName(profileTemplate, account, &role, region)
+					profileName := awsssolib.GenerateProfileNameWithOptions(profileTemplate, account, &role, region, awsssolib.ProfileNameOptions{
+						PreserveCase: preserveCase,
+					})
 
 					// Check if profile exists
 					if existing := config.GetProfile(profileName); existing != nil && !force {
@@ -279,29 +420,159 @@ Examples:
 					// Add credential process if requested
 					if credentialProcess {
 						profile.CredProcess = fmt.Sprintf("aws-sso-util credential-process --profile %s", profileName)
+						if outputFile != "" {
+							profile.CredProcess += " --config-file " + shellQuote(outputFile)
+						}
 					}
 
+					// Let configure prune tell generated profiles from hand-written ones
+					markManaged(profile)
+
 					config.SetProfile(profile)
 					profilesCreated++
 				}
 			}
 
 			// Save config
-			err = config.SaveConfigFile("")
+			noBackup, _ := cmd.Flags().GetBool("no-backup")
+			err = config.SaveConfigFileWithOptions(outputFile, awsssolib.SaveOptions{NoBackup: noBackup})
 			if err != nil {
 				return fmt.Errorf("failed to save config: %w", err)
 			}
 
 			fmt.Fprintf(os.Stderr, "\nCreated %d profiles, skipped %d existing profiles\n", profilesCreated, profilesSkipped)
+			if outputFile != "" {
+				fmt.Fprintf(os.Stderr, "Profiles written to %s\n", outputFile)
+				fmt.Fprintf(os.Stderr, "The AWS CLI does not support config includes; use: export AWS_CONFIG_FILE=%s\n", outputFile)
+			}
 
 			return nil
 		},
 	}
 
 	cmd.Flags().StringSliceVar(&regions, "regions", []string{}, "AWS regions to create profiles for (comma-separated)")
-	cmd.Flags().StringVar(&profileTemplate, "profile-template", "", "Template for profile names (default: {account_name}.{role_name}.{region})")
+	cmd.Flags().StringVar(&profileTemplate, "profile-template", "", "Template for profile names (default: the remote config's template, or {account_name}.{role_name}.{region})")
 	cmd.Flags().BoolVar(&credentialProcess, "credential-process", true, "Add credential process configuration")
 	cmd.Flags().BoolVar(&force, "force", false, "Overwrite existing profiles")
+	cmd.Flags().BoolVar(&preserveCase, "preserve-case", false, "Keep the original letter case in generated profile names")
+	cmd.Flags().StringVar(&outputFile, "output-file", "", "Write generated profiles to this file instead of the main AWS config")
+	cmd.Flags().IntVar(&maxConcurrency, "max-concurrency", awsssolib.DefaultMaxConcurrency, "Number of accounts whose roles are listed at once")
+	cmd.Flags().StringArrayVar(&regionMapFlags, "region-map", nil, "Regions for one account instead of --regions, as ACCOUNT_ID=REGION[,REGION...] (can be specified multiple times)")
+	cmd.Flags().StringArrayVar(&roleFilter.Roles, "role-filter", nil, "Only create profiles for roles matching this glob or /regexp/ (can be specified multiple times)")
+	cmd.Flags().StringArrayVar(&roleFilter.Accounts, "account-filter", nil, "Only create profiles for accounts whose ID or name matches this glob or /regexp/ (can be specified multiple times)")
 
 	return cmd
 }
+
+// parseRegionMap parses --region-map values of the form
+// ACCOUNT_ID=REGION[,REGION...] into the regions of each account
+func parseRegionMap(values []string) (map[string][]string, error) {
+	regionMap := make(map[string][]string)
+	for _, value := range values {
+		accountID, list, ok := strings.Cut(value, "=")
+		if !ok || list == "" {
+			return nil, usageErrorf("invalid --region-map %q: use ACCOUNT_ID=REGION[,REGION...]", value)
+		}
+		accountID = strings.TrimSpace(accountID)
+		if err := awsssolib.ValidateAccountID(accountID); err != nil {
+			return nil, fmt.Errorf("invalid --region-map %q: %w", value, err)
+		}
+		accountID = strings.NewReplacer("-", "", " ", "").Replace(accountID)
+
+		for _, region := range strings.Split(list, ",") {
+			region = strings.TrimSpace(region)
+			if err := awsssolib.ValidateRegion(region); err != nil {
+				return nil, fmt.Errorf("invalid --region-map %q: %w", value, err)
+			}
+			regionMap[accountID] = append(regionMap[accountID], region)
+		}
+	}
+	return regionMap, nil
+}
+
+// resolvedProfile is the JSON output of configure resolve
+type resolvedProfile struct {
+	Profile    string `json:"profile"`
+	SSOSession string `json:"sso_session,omitempty"`
+	StartURL   string `json:"sso_start_url"`
+	SSORegion  string `json:"sso_region"`
+	AccountID  string `json:"sso_account_id"`
+	RoleName   string `json:"sso_role_name"`
+	Region     string `json:"region"`
+}
+
+// newConfigureResolveCommand creates the configure resolve command
+func newConfigureResolveCommand() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "resolve <profile-name>",
+		Short: "Show the SSO settings a profile resolves to",
+		Long: `Show the SSO settings a profile resolves to.
+
+The start URL and SSO region are taken from the profile's sso_session when
+it references an [sso-session] section.
+
+Examples:
+  # Show the SSO settings of a profile
+  aws-sso-util configure resolve my-profile
+
+  # Output as JSON
+  aws-sso-util configure resolve my-profile --format json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := awsssolib.LoadConfigFile("")
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			profile, err := config.ResolveProfile(args[0])
+			if err != nil {
+				return err
+			}
+
+			resolved := resolvedProfile{
+				Profile:    profile.Name,
+				SSOSession: profile.SSOSession,
+				StartURL:   profile.StartURL,
+				SSORegion:  profile.SSORegion,
+				AccountID:  profile.AccountID,
+				RoleName:   profile.RoleName,
+				Region:     profile.Region,
+			}
+
+			switch format {
+			case "json":
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(resolved)
+			case "text":
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintf(w, "Profile:\t%s\n", resolved.Profile)
+				if resolved.SSOSession != "" {
+					fmt.Fprintf(w, "SSO session:\t%s\n", resolved.SSOSession)
+				}
+				fmt.Fprintf(w, "SSO start URL:\t%s\n", valueOrUnset(resolved.StartURL))
+				fmt.Fprintf(w, "SSO region:\t%s\n", valueOrUnset(resolved.SSORegion))
+				fmt.Fprintf(w, "Account ID:\t%s\n", valueOrUnset(resolved.AccountID))
+				fmt.Fprintf(w, "Role name:\t%s\n", valueOrUnset(resolved.RoleName))
+				fmt.Fprintf(w, "Region:\t%s\n", valueOrUnset(resolved.Region))
+				return w.Flush()
+			default:
+				return usageErrorf("unsupported format %q: use text or json", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "Output format (text, json)")
+
+	return cmd
+}
+
+// valueOrUnset returns value, or a placeholder when it is empty
+func valueOrUnset(value string) string {
+	if value == "" {
+		return "(not set)"
+	}
+	return value
+}
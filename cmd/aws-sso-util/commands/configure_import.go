@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// legacyProfileKeys are written by the Python aws-sso-util and not used by this tool
+var legacyProfileKeys = []string{"sso_auto_populated", "sso_account_name"}
+
+// newConfigureImportCommand creates the configure import command
+func newConfigureImportCommand() *cobra.Command {
+	var credentialProcess bool
+	var dryRun bool
+	var configFile string
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import profiles created by the Python aws-sso-util",
+		Long: `Import profiles created by the Python aws-sso-util.
+
+Profiles written by the Python tool are recognized by its sso_auto_populated
+and sso_account_name keys. They are rewritten in this tool's format: those
+keys are removed and, unless disabled, the credential_process line is set to
+run this tool. Other profiles are left untouched.
+
+Examples:
+  # Show what would change
+  aws-sso-util configure import --dry-run
+
+  # Import the profiles of the main AWS config
+  aws-sso-util configure import
+
+  # Import a separate config file, without credential_process lines
+  aws-sso-util configure import --config-file ~/.aws/sso-profiles --credential-process=false`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Imported profiles reference the config file so credential-process can find them
+			if configFile != "" {
+				var err error
+				configFile, err = filepath.Abs(configFile)
+				if err != nil {
+					return fmt.Errorf("failed to resolve config file: %w", err)
+				}
+			}
+
+			config, err := awsssolib.LoadConfigFile(configFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			imported := 0
+			for _, name := range config.ListProfiles() {
+				profile := config.GetProfile(name)
+				if !isLegacyProfile(profile) {
+					continue
+				}
+
+				changes := importLegacyProfile(profile, credentialProcess, configFile)
+				imported++
+				if dryRun {
+					fmt.Fprintf(os.Stderr, "Would import profile %s: %s\n", name, strings.Join(changes, ", "))
+				} else {
+					fmt.Fprintf(os.Stderr, "Imported profile %s: %s\n", name, strings.Join(changes, ", "))
+				}
+			}
+
+			if imported == 0 {
+				fmt.Fprintln(os.Stderr, "No profiles created by the Python aws-sso-util found")
+				return nil
+			}
+			if dryRun {
+				fmt.Fprintf(os.Stderr, "\n%d profiles would be imported (dry run, nothing written)\n", imported)
+				return nil
+			}
+
+			noBackup, _ := cmd.Flags().GetBool("no-backup")
+			err = config.SaveConfigFileWithOptions(configFile, awsssolib.SaveOptions{NoBackup: noBackup})
+			if err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+
+			fmt.Fprintf(os.Stderr, "\nImported %d profiles\n", imported)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&credentialProcess, "credential-process", true, "Point the credential_process of imported profiles at this tool")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would change without writing the config")
+	cmd.Flags().StringVar(&configFile, "config-file", "", "Config file to import (default: the AWS config file)")
+
+	return cmd
+}
+
+// isLegacyProfile reports whether a profile was written by the Python aws-sso-util
+func isLegacyProfile(profile *awsssolib.Profile) bool {
+	for _, key := range legacyProfileKeys {
+		if _, ok := profile.Extra[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// importLegacyProfile rewrites a profile of the Python aws-sso-util in this
+// tool's format and describes the changes made
+func importLegacyProfile(profile *awsssolib.Profile, credentialProcess bool, configFile string) []string {
+	var changes []string
+
+	for _, key := range legacyProfileKeys {
+		if _, ok := profile.Extra[key]; ok {
+			delete(profile.Extra, key)
+			changes = append(changes, "removed "+key)
+		}
+	}
+	if len(profile.Extra) == 0 {
+		profile.Extra = nil
+	}
+
+	if credentialProcess {
+		command := fmt.Sprintf("aws-sso-util credential-process --profile %s", profile.Name)
+		if configFile != "" {
+			command += " --config-file " + shellQuote(configFile)
+		}
+		if profile.CredProcess != command {
+			profile.CredProcess = command
+			changes = append(changes, "set credential_process")
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+func TestImportLegacyProfiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "aws config")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "config")
+	legacy := `[profile prod.Admin]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = us-east-1
+sso_account_name = prod
+sso_account_id = 123456789012
+sso_role_name = Admin
+region = eu-west-1
+credential_process = aws-sso-util credential-process --profile prod.Admin
+sso_auto_populated = true
+cli_pager =
+
+[profile manual]
+region = us-east-1
+s3 =
+  max_concurrent_requests = 20
+`
+	if err := os.WriteFile(filename, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := awsssolib.LoadConfigFile(filename)
+	if err != nil {
+		t.Fatalf("LoadConfigFile failed: %v", err)
+	}
+	if isLegacyProfile(config.GetProfile("manual")) {
+		t.Error("Expected a hand-written profile not to be detected")
+	}
+
+	profile := config.GetProfile("prod.Admin")
+	if !isLegacyProfile(profile) {
+		t.Fatal("Expected the Python profile to be detected")
+	}
+	changes := importLegacyProfile(profile, true, filename)
+	if len(changes) != 3 {
+		t.Errorf("Expected 3 changes, got %q", changes)
+	}
+	if isLegacyProfile(profile) {
+		t.Error("Expected the imported profile not to be detected again")
+	}
+	if want := "aws-sso-util credential-process --profile prod.Admin --config-file '" + filename + "'"; profile.CredProcess != want {
+		t.Errorf("Expected credential_process %q, got %q", want, profile.CredProcess)
+	}
+
+	if err := config.SaveConfigFileWithOptions(filename, awsssolib.SaveOptions{NoBackup: true}); err != nil {
+		t.Fatalf("SaveConfigFile failed: %v", err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(data)
+	for _, want := range []string{"cli_pager =\n", "s3 =\n  max_concurrent_requests = 20\n", "sso_account_id = 123456789012\n"} {
+		if !strings.Contains(saved, want) {
+			t.Errorf("Expected %q to be kept, got:\n%s", want, saved)
+		}
+	}
+	if strings.Contains(saved, "sso_auto_populated") || strings.Contains(saved, "sso_account_name") {
+		t.Errorf("Expected the Python keys to be removed, got:\n%s", saved)
+	}
+}
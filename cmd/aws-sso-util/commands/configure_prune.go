@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// managedByKey marks the profiles written by configure populate, with
+// managedByValue as its value, so they can be told apart from hand-written ones
+const (
+	managedByKey   = "managed_by"
+	managedByValue = "aws-sso-util"
+)
+
+// newConfigurePruneCommand creates the configure prune command
+func newConfigurePruneCommand() *cobra.Command {
+	var allManaged bool
+	var dryRun bool
+	var configFile string
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove profiles created by configure populate",
+		Long: `Remove profiles created by configure populate.
+
+Profiles written by configure populate are marked with a managed_by key.
+With --all-managed, every profile carrying the marker is removed, so the
+profiles can be regenerated from scratch. Profiles without the marker, such as
+those written by hand or by configure profile, are left untouched.
+
+Examples:
+  # Show which profiles would be removed
+  aws-sso-util configure prune --all-managed --dry-run
+
+  # Remove all managed profiles of the main AWS config
+  aws-sso-util configure prune --all-managed
+
+  # Remove the managed profiles of a separate config file
+  aws-sso-util configure prune --all-managed --config-file ~/.aws/sso-profiles`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !allManaged {
+				return usageErrorf("nothing to prune: pass --all-managed to remove all profiles created by configure populate")
+			}
+
+			if configFile != "" {
+				var err error
+				configFile, err = filepath.Abs(configFile)
+				if err != nil {
+					return fmt.Errorf("failed to resolve config file: %w", err)
+				}
+			}
+
+			config, err := awsssolib.LoadConfigFile(configFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			removed := pruneManagedProfiles(config)
+			for _, name := range removed {
+				if dryRun {
+					fmt.Fprintf(os.Stderr, "Would remove profile %s\n", name)
+				} else {
+					fmt.Fprintf(os.Stderr, "Removed profile %s\n", name)
+				}
+			}
+
+			if len(removed) == 0 {
+				fmt.Fprintln(os.Stderr, "No managed profiles found")
+				return nil
+			}
+			if dryRun {
+				fmt.Fprintf(os.Stderr, "\n%d profiles would be removed (dry run, nothing written)\n", len(removed))
+				return nil
+			}
+
+			noBackup, _ := cmd.Flags().GetBool("no-backup")
+			err = config.SaveConfigFileWithOptions(configFile, awsssolib.SaveOptions{NoBackup: noBackup})
+			if err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+
+			fmt.Fprintf(os.Stderr, "\nRemoved %d profiles\n", len(removed))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&allManaged, "all-managed", false, "Remove every profile created by configure populate")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be removed without writing the config")
+	cmd.Flags().StringVar(&configFile, "config-file", "", "Config file to prune (default: the AWS config file)")
+
+	return cmd
+}
+
+// markManaged marks a profile as written by configure populate
+func markManaged(profile *awsssolib.Profile) {
+	if profile.Extra == nil {
+		profile.Extra = make(map[string]string)
+	}
+	profile.Extra[managedByKey] = managedByValue
+}
+
+// isManaged reports whether a profile was written by configure populate
+func isManaged(profile *awsssolib.Profile) bool {
+	return profile.Extra[managedByKey] == managedByValue
+}
+
+// pruneManagedProfiles removes the managed profiles of config and returns
+// their names, sorted. The default profile is kept: switch copies a managed
+// profile to it, and older versions kept the managed_by marker.
+func pruneManagedProfiles(config *awsssolib.ConfigFile) []string {
+	var removed []string
+	for _, name := range config.ListProfiles() {
+		if name != "default" && isManaged(config.GetProfile(name)) {
+			config.RemoveProfile(name)
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+func TestPruneManagedProfiles(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config")
+	config := awsssolib.NewConfigFile()
+	for _, name := range []string{"dev.Admin.us-east-1", "prod.Admin.us-east-1"} {
+		profile := &awsssolib.Profile{
+			Name:      name,
+			StartURL:  "https://corp.awsapps.com/start",
+			SSORegion: "us-east-1",
+			AccountID: "123456789012",
+			RoleName:  "Admin",
+			Region:    "us-east-1",
+		}
+		markManaged(profile)
+		config.SetProfile(profile)
+	}
+	config.SetProfile(&awsssolib.Profile{Name: "manual", Region: "eu-west-1", Extra: map[string]string{"cli_pager": ""}})
+	config.SetProfile(&awsssolib.Profile{Name: "other-tool", Region: "eu-west-1", Extra: map[string]string{managedByKey: "terraform"}})
+
+	// The marker survives a save and load
+	if err := config.SaveConfigFileWithOptions(filename, awsssolib.SaveOptions{NoBackup: true}); err != nil {
+		t.Fatalf("SaveConfigFile failed: %v", err)
+	}
+	config, err := awsssolib.LoadConfigFile(filename)
+	if err != nil {
+		t.Fatalf("LoadConfigFile failed: %v", err)
+	}
+
+	removed := pruneManagedProfiles(config)
+	if want := []string{"dev.Admin.us-east-1", "prod.Admin.us-east-1"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("Expected %v to be removed, got %v", want, removed)
+	}
+	if want := []string{"manual", "other-tool"}; !reflect.DeepEqual(config.ListProfiles(), want) {
+		t.Errorf("Expected %v to be kept, got %v", want, config.ListProfiles())
+	}
+
+	if err := config.SaveConfigFileWithOptions(filename, awsssolib.SaveOptions{NoBackup: true}); err != nil {
+		t.Fatalf("SaveConfigFile failed: %v", err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved := string(data); strings.Contains(saved, "Admin") || !strings.Contains(saved, "cli_pager =") {
+		t.Errorf("Expected only the managed profiles to be removed, got:\n%s", saved)
+	}
+}
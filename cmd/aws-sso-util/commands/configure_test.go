@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+func TestVerifyProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/federation/credentials":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"roleCredentials":{"accessKeyId":"AKIA","secretAccessKey":"secret","sessionToken":"session","expiration":%d}}`,
+				time.Now().Add(time.Hour).UnixMilli())
+		default:
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprint(w, `<GetCallerIdentityResponse><GetCallerIdentityResult>
+<Arn>arn:aws:sts::123456789012:assumed-role/Admin/jane</Arn><UserId>AROA:jane</UserId><Account>123456789012</Account>
+</GetCallerIdentityResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></GetCallerIdentityResponse>`)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL_SSO", server.URL)
+	t.Setenv("AWS_ENDPOINT_URL_STS", server.URL)
+
+	profile := &awsssolib.Profile{
+		Name:      "prod",
+		StartURL:  "https://corp.awsapps.com/start",
+		SSORegion: "us-east-1",
+		AccountID: "123456789012",
+		RoleName:  "Admin",
+		Region:    "eu-west-1",
+	}
+	ctx := context.Background()
+	libConfig := &awsssolib.Config{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	// Without a cached token the profile can't be verified, and no login is started
+	if _, err := verifyProfile(ctx, profile, libConfig); err == nil {
+		t.Error("Expected an error without a cached token")
+	}
+
+	token := &awsssolib.Token{AccessToken: "token", ExpiresAt: time.Now().UTC().Add(time.Hour), Region: "us-east-1"}
+	if err := awsssolib.PutCachedToken(nil, profile.StartURL, token); err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+
+	arn, err := verifyProfile(ctx, profile, libConfig)
+	if err != nil {
+		t.Fatalf("verifyProfile failed: %v", err)
+	}
+	if arn != "arn:aws:sts::123456789012:assumed-role/Admin/jane" {
+		t.Errorf("Expected the caller ARN, got %q", arn)
+	}
+
+	// A profile without a region is verified in the SSO region
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	profile.Region = ""
+	if _, err := verifyProfile(ctx, profile, libConfig); err != nil {
+		t.Errorf("Expected a profile without a region to be verified, got %v", err)
+	}
+
+	// A mistyped region is reported instead of surfacing later
+	profile.Region = "eu-west"
+	if _, err := verifyProfile(ctx, profile, libConfig); err == nil {
+		t.Error("Expected an error for an invalid region")
+	}
+}
+
+func TestParseRegionMap(t *testing.T) {
+	regionMap, err := parseRegionMap([]string{
+		"123456789012=eu-west-1,eu-central-1",
+		"2345-6789-0123 = us-west-2",
+	})
+	if err != nil {
+		t.Fatalf("parseRegionMap failed: %v", err)
+	}
+	want := map[string][]string{
+		"123456789012": {"eu-west-1", "eu-central-1"},
+		"234567890123": {"us-west-2"},
+	}
+	if !reflect.DeepEqual(regionMap, want) {
+		t.Errorf("Expected %v, got %v", want, regionMap)
+	}
+
+	for _, value := range []string{"123456789012", "123456789012=", "12345=eu-west-1", "123456789012=europe"} {
+		if _, err := parseRegionMap([]string{value}); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
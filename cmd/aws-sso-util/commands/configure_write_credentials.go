@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// newConfigureWriteCredentialsCommand creates the configure write-credentials command
+func newConfigureWriteCredentialsCommand() *cobra.Command {
+	var profileName string
+	var configFile string
+	var credentialsFile string
+	var login bool
+
+	cmd := &cobra.Command{
+		Use:   "write-credentials",
+		Short: "Write temporary SSO credentials to the AWS credentials file",
+		Long: `Write temporary SSO credentials to the AWS credentials file.
+
+Some tools only read static keys from ~/.aws/credentials. This command
+retrieves the role credentials of an SSO profile and writes them as
+aws_access_key_id, aws_secret_access_key and aws_session_token to the section
+of the same name in the credentials file, with their aws_session_expiration.
+The section is marked with a comment as temporary; settings such as region
+and the rest of the file, comments included, are kept as is.
+
+The credentials are temporary: they stop working when they expire, typically
+after an hour, and the command must be run again. Prefer credential_process
+for tools that support it.
+
+Examples:
+  # Write the credentials of a profile
+  aws-sso-util configure write-credentials --profile prod
+
+  # Log in first if needed
+  aws-sso-util configure write-credentials --profile prod --login
+
+  # Write to a separate credentials file
+  aws-sso-util configure write-credentials --profile prod --credentials-file ./credentials`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			if profileName == "" {
+				return usageErrorf("--profile is required")
+			}
+
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			config, err := awsssolib.LoadConfigFile(configFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			profile, err := config.ResolveProfile(profileName)
+			if err != nil {
+				return err
+			}
+			if profile.StartURL == "" || profile.SSORegion == "" || profile.AccountID == "" || profile.RoleName == "" {
+				return usageErrorf("profile %s is not an SSO profile with an account and role", profileName)
+			}
+
+			region := awsssolib.ResolveRegion(awsssolib.RegionSources{Profile: profile.Region, SSORegion: profile.SSORegion})
+			cfg, err := awsssolib.GetAWSConfig(ctx, awsssolib.GetAWSConfigInput{
+				StartURL:  profile.StartURL,
+				SSORegion: profile.SSORegion,
+				AccountID: profile.AccountID,
+				RoleName:  profile.RoleName,
+				Region:    region,
+				Login:     login,
+				Config:    libConfig,
+			})
+			if err != nil {
+				return err
+			}
+
+			creds, err := cfg.Credentials.Retrieve(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to retrieve credentials: %w", err)
+			}
+
+			filename := awsssolib.ResolveCredentialsFilePath(credentialsFile)
+			if err := awsssolib.WriteCredentials(filename, profileName, creds); err != nil {
+				return fmt.Errorf("failed to write credentials: %w", err)
+			}
+
+			fmt.Fprintf(os.Stderr, "Wrote credentials for profile %s to %s\n", profileName, filename)
+			if creds.CanExpire {
+				fmt.Fprintf(os.Stderr, "Warning: these credentials are temporary and expire at %s (in %s); run this command again to refresh them\n",
+					creds.Expires.Local().Format("2006-01-02 15:04:05"), time.Until(creds.Expires).Round(time.Minute))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&profileName, "profile", "", "SSO profile whose credentials to write")
+	cmd.Flags().StringVar(&configFile, "config-file", "", "AWS config file to read the profile from (default: AWS_CONFIG_FILE or ~/.aws/config)")
+	cmd.Flags().StringVar(&credentialsFile, "credentials-file", "", "Credentials file to write (default: AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials)")
+	cmd.Flags().BoolVar(&login, "login", false, "Login if needed")
+
+	return cmd
+}
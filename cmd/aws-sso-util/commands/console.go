@@ -49,5 +49,7 @@ Examples:
 	cmd.Flags().StringVar(&roleName, "role", "", "SSO role name")
 	cmd.Flags().StringVar(&service, "service", "", "AWS service to open (e.g., ec2, s3)")
 
+	registerAccountRoleCompletion(cmd)
+
 	return cmd
 }
@@ -3,10 +3,12 @@ package commands
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"os"
+	"time"
 
 	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/spf13/cobra"
 )
 
@@ -19,6 +21,11 @@ type CredentialProcessOutput struct {
 	Expiration      string `json:"Expiration,omitempty"`
 }
 
+// defaultMinValidity is the default --min-validity of credential-process. The
+// AWS CLI and SDKs refresh credentials expiring within 15 minutes, so cached
+// credentials closer to expiry would be refreshed right after being returned.
+const defaultMinValidity = 15 * time.Minute
+
 // NewCredentialProcessCommand creates the credential-process command
 func NewCredentialProcessCommand() *cobra.Command {
 	var profileName string
@@ -26,25 +33,36 @@ func NewCredentialProcessCommand() *cobra.Command {
 	var roleName string
 	var startURL string
 	var ssoRegion string
+	var configFile string
+	var minValidity time.Duration
 
 	cmd := &cobra.Command{
-		Use:    "credential-process",
-		Short:  "Output credentials in credential_process format",
-		Long:   `Output AWS credentials in the format expected by the credential_process configuration.`,
+		Use:   "credential-process",
+		Short: "Output credentials in credential_process format",
+		Long: `Output AWS credentials in the format expected by the credential_process configuration.
+
+Credentials are cached in the AWS CLI cache directory and reused until they
+are within --min-validity of expiring, when fresh credentials are fetched
+instead. The global --no-cache flag fetches them on every call.`,
 		Hidden: true, // Hide from main help as it's meant to be used by AWS CLI
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
 
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
+
 			// If profile is specified, load configuration from it
 			if profileName != "" {
-				config, err := awsssolib.LoadConfigFile("")
+				config, err := awsssolib.LoadConfigFile(configFile)
 				if err != nil {
 					return err
 				}
 
-				profile := config.GetProfile(profileName)
-				if profile == nil {
-					return fmt.Errorf("profile '%s' not found", profileName)
+				profile, err := config.ResolveProfile(profileName)
+				if err != nil {
+					return err
 				}
 
 				// Override with profile values
@@ -62,26 +80,31 @@ func NewCredentialProcessCommand() *cobra.Command {
 				}
 			}
 
+			if minValidity < 0 {
+				return usageErrorf("invalid --min-validity %s: must not be negative", minValidity)
+			}
+
 			// Validate required parameters
 			if startURL == "" || ssoRegion == "" || accountID == "" || roleName == "" {
-				return fmt.Errorf("missing required SSO configuration")
+				return usageErrorf("missing required SSO configuration")
 			}
 
-			// Get AWS config
-			cfg, err := awsssolib.GetAWSConfig(ctx, awsssolib.GetAWSConfigInput{
+			input := awsssolib.GetAWSConfigInput{
 				StartURL:  startURL,
 				SSORegion: ssoRegion,
 				AccountID: accountID,
 				RoleName:  roleName,
-				Region:    "us-east-1", // Region doesn't matter for credentials
-				Login:     false,       // Don't try to login interactively
-			})
-			if err != nil {
-				return err
+				Region:    awsssolib.ResolveRegion(awsssolib.RegionSources{SSORegion: ssoRegion}),
+				Login:     false, // Don't try to login interactively
+				// Reuse credentials across calls, but not when they are about to expire
+				CredentialCache: awsssolib.NewFileCache(awsssolib.CLICacheDir()),
+				MinValidity:     minValidity,
+				Config:          libConfig,
 			}
-
-			// Get credentials
-			creds, err := cfg.Credentials.Retrieve(ctx)
+			creds, err := retrieveCredentials(ctx, input, retrieveRoleCredentials,
+				func(ctx context.Context, roleName string) (string, error) {
+					return resolveRoleName(ctx, libConfig, startURL, ssoRegion, accountID, roleName, false)
+				})
 			if err != nil {
 				return err
 			}
@@ -96,7 +119,7 @@ func NewCredentialProcessCommand() *cobra.Command {
 
 			// Add expiration if available
 			if creds.CanExpire && !creds.Expires.IsZero() {
-				output.Expiration = creds.Expires.Format("2006-01-02T15:04:05Z")
+				output.Expiration = formatExpiration(creds.Expires)
 			}
 
 			// Output JSON
@@ -110,6 +133,54 @@ func NewCredentialProcessCommand() *cobra.Command {
 	cmd.Flags().StringVar(&roleName, "role", "", "SSO role name")
 	cmd.Flags().StringVar(&startURL, "start-url", "", "SSO start URL")
 	cmd.Flags().StringVar(&ssoRegion, "sso-region", "", "SSO region")
+	cmd.Flags().DurationVar(&minValidity, "min-validity", defaultMinValidity, "Fetch fresh credentials when the cached ones expire within this duration")
+	cmd.Flags().StringVar(&configFile, "config-file", "", "AWS config file to read the profile from (default: AWS_CONFIG_FILE or ~/.aws/config)")
+
+	registerAccountRoleCompletion(cmd)
 
 	return cmd
 }
+
+// retrieveCredentials retrieves the credentials of input's role. Only when
+// that fails is the role name matched case-insensitively against the roles of
+// the account with resolve, and the credentials of the canonical name
+// retrieved, so cached credentials need no SSO call.
+func retrieveCredentials(ctx context.Context, input awsssolib.GetAWSConfigInput,
+	retrieve func(context.Context, awsssolib.GetAWSConfigInput) (aws.Credentials, error),
+	resolve func(ctx context.Context, roleName string) (string, error)) (aws.Credentials, error) {
+	creds, err := retrieve(ctx, input)
+	var authErr *awsssolib.AuthenticationNeededError
+	if err == nil || errors.As(err, &authErr) {
+		return creds, err
+	}
+
+	resolved, resolveErr := resolve(ctx, input.RoleName)
+	if resolveErr != nil {
+		// A missing role explains the failure better than the SSO error
+		var configErr *awsssolib.InvalidConfigError
+		if errors.As(resolveErr, &configErr) {
+			return aws.Credentials{}, resolveErr
+		}
+		return aws.Credentials{}, err
+	}
+	if resolved == input.RoleName {
+		return aws.Credentials{}, err
+	}
+
+	input.RoleName = resolved
+	return retrieve(ctx, input)
+}
+
+// retrieveRoleCredentials retrieves the credentials of input's role
+func retrieveRoleCredentials(ctx context.Context, input awsssolib.GetAWSConfigInput) (aws.Credentials, error) {
+	cfg, err := awsssolib.GetAWSConfig(ctx, input)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	return cfg.Credentials.Retrieve(ctx)
+}
+
+// formatExpiration formats an expiration time as RFC3339 in UTC, as expected by credential_process consumers
+func formatExpiration(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
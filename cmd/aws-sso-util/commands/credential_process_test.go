@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestFormatExpiration(t *testing.T) {
+	loc := time.FixedZone("UTC+5:30", 5*60*60+30*60)
+
+	tests := []struct {
+		input    time.Time
+		expected string
+	}{
+		{time.Date(2024, 12, 19, 10, 30, 0, 0, time.UTC), "2024-12-19T10:30:00Z"},
+		{time.Date(2024, 12, 19, 16, 0, 0, 0, loc), "2024-12-19T10:30:00Z"},
+		{time.Date(2024, 12, 19, 10, 30, 0, 0, time.FixedZone("UTC-8", -8*60*60)), "2024-12-19T18:30:00Z"},
+	}
+
+	for _, tt := range tests {
+		result := formatExpiration(tt.input)
+		if result != tt.expected {
+			t.Errorf("Input %s: expected %s, got %s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestRetrieveCredentialsResolvesRoleOnFailure(t *testing.T) {
+	ctx := context.Background()
+	var tried []string
+	retrieve := func(ctx context.Context, input awsssolib.GetAWSConfigInput) (aws.Credentials, error) {
+		tried = append(tried, input.RoleName)
+		if input.RoleName != "Admin" {
+			return aws.Credentials{}, errors.New("ForbiddenException: No access")
+		}
+		return aws.Credentials{AccessKeyID: "AKID"}, nil
+	}
+	resolves := 0
+	resolve := func(ctx context.Context, roleName string) (string, error) {
+		resolves++
+		return "Admin", nil
+	}
+
+	// Cached or retrievable credentials need no role listing
+	creds, err := retrieveCredentials(ctx, awsssolib.GetAWSConfigInput{RoleName: "Admin"}, retrieve, resolve)
+	if err != nil || creds.AccessKeyID != "AKID" || resolves != 0 {
+		t.Errorf("Expected credentials without resolving, got %+v (%v) after %d resolves", creds, err, resolves)
+	}
+
+	tried = nil
+	creds, err = retrieveCredentials(ctx, awsssolib.GetAWSConfigInput{RoleName: "admin"}, retrieve, resolve)
+	if err != nil || creds.AccessKeyID != "AKID" || strings.Join(tried, ",") != "admin,Admin" {
+		t.Errorf("Expected a retry with the canonical name, got %+v (%v) after trying %v", creds, err, tried)
+	}
+
+	// A failing listing keeps the original error rather than "role not found"
+	failing := func(ctx context.Context, roleName string) (string, error) {
+		return "", errors.New("failed to list roles: ThrottlingException")
+	}
+	if _, err := retrieveCredentials(ctx, awsssolib.GetAWSConfigInput{RoleName: "admin"}, retrieve, failing); err == nil || !strings.Contains(err.Error(), "ForbiddenException") {
+		t.Errorf("Expected the retrieval error, got %v", err)
+	}
+
+	// Logins are needed whatever the role name
+	authNeeded := func(ctx context.Context, input awsssolib.GetAWSConfigInput) (aws.Credentials, error) {
+		return aws.Credentials{}, &awsssolib.AuthenticationNeededError{}
+	}
+	resolves = 0
+	var authErr *awsssolib.AuthenticationNeededError
+	if _, err := retrieveCredentials(ctx, awsssolib.GetAWSConfigInput{RoleName: "admin"}, authNeeded, resolve); !errors.As(err, &authErr) || resolves != 0 {
+		t.Errorf("Expected the authentication error without resolving, got %v", err)
+	}
+}
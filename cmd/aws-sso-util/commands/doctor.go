@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// maxClockSkew is the clock difference above which AWS rejects signed requests
+const maxClockSkew = 5 * time.Minute
+
+// doctorHTTPClient is used by the network probes of the doctor command
+var doctorHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// doctorResult is the outcome of a single doctor check
+type doctorResult struct {
+	ok     bool
+	detail string
+	hint   string
+}
+
+// NewDoctorCommand creates the doctor command
+func NewDoctorCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose common SSO setup problems",
+		Long: `Diagnose common SSO setup problems.
+
+The doctor command checks that:
+- the AWS config file is readable and writable
+- the SSO token cache directory has 0700 permissions
+- the local clock is in sync with AWS
+- the SSO start URL is reachable
+- the cached SSO token is valid
+- credential_process entries reference existing profiles and executables
+
+Each failed check is reported with a remediation hint and the command exits
+with a non-zero status.
+
+Examples:
+  # Run all checks
+  aws-sso-util doctor
+
+  # Check a specific SSO instance
+  aws-sso-util doctor --start-url https://my-sso.awsapps.com/start --sso-region us-east-1`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			startURL, _ := cmd.Flags().GetString("start-url")
+			ssoRegion, _ := cmd.Flags().GetString("sso-region")
+
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			failed := 0
+			report := func(name string, result doctorResult) {
+				if result.ok {
+					fmt.Fprintf(os.Stderr, "✓ %s: %s\n", name, result.detail)
+					return
+				}
+				failed++
+				fmt.Fprintf(os.Stderr, "❌ %s: %s\n", name, result.detail)
+				if result.hint != "" {
+					fmt.Fprintf(os.Stderr, "   %s\n", result.hint)
+				}
+			}
+
+			report("Config file", checkConfigFile(awsssolib.ResolveConfigFilePath("")))
+			report("SSO cache directory", checkCacheDir(awsssolib.SSOCacheDir()))
+			report("Profiles", checkCredentialProcesses())
+
+			if startURL == "" || ssoRegion == "" {
+				instance, err := awsssolib.FindInstance("")
+				if err != nil {
+					report("SSO configuration", doctorResult{
+						detail: "no SSO configuration found",
+						hint:   "Provide --start-url and --sso-region or set AWS_DEFAULT_SSO_START_URL and AWS_DEFAULT_SSO_REGION",
+					})
+					return fmt.Errorf("%d checks failed", failed)
+				}
+				if startURL == "" {
+					startURL = instance.StartURL
+				}
+				if ssoRegion == "" {
+					ssoRegion = instance.Region
+				}
+			}
+			report("SSO configuration", doctorResult{ok: true, detail: fmt.Sprintf("%s (%s)", startURL, ssoRegion)})
+
+			report("Clock", checkClockSkew(ssoRegion))
+			report("Start URL", checkStartURL(startURL))
+			report("SSO token", checkToken(awsssolib.NewTokenCache(libConfig), startURL))
+
+			if failed > 0 {
+				return fmt.Errorf("%d checks failed", failed)
+			}
+
+			fmt.Fprintln(os.Stderr, "\nAll checks passed")
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// checkConfigFile checks that the AWS config file is readable and writable
+func checkConfigFile(path string) doctorResult {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return doctorResult{ok: true, detail: fmt.Sprintf("%s does not exist yet", path)}
+	}
+	if err != nil {
+		return doctorResult{detail: err.Error(), hint: fmt.Sprintf("Check the permissions of %s", path)}
+	}
+	if info.IsDir() {
+		return doctorResult{detail: fmt.Sprintf("%s is a directory", path), hint: "Point AWS_CONFIG_FILE at a file"}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return doctorResult{detail: fmt.Sprintf("%s is not readable", path), hint: fmt.Sprintf("Run: chmod u+r %s", path)}
+	}
+	f.Close()
+
+	f, err = os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return doctorResult{detail: fmt.Sprintf("%s is not writable", path), hint: fmt.Sprintf("Run: chmod u+w %s", path)}
+	}
+	f.Close()
+
+	if _, err := awsssolib.LoadConfigFile(path); err != nil {
+		return doctorResult{detail: fmt.Sprintf("failed to parse %s: %v", path, err), hint: "Fix the syntax error in the config file"}
+	}
+
+	return doctorResult{ok: true, detail: path}
+}
+
+// checkCacheDir checks that the SSO token cache directory is private
+func checkCacheDir(dir string) doctorResult {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return doctorResult{ok: true, detail: fmt.Sprintf("%s does not exist yet", dir)}
+	}
+	if err != nil {
+		return doctorResult{detail: err.Error()}
+	}
+
+	if perm := info.Mode().Perm(); perm != 0700 {
+		return doctorResult{
+			detail: fmt.Sprintf("%s has permissions %#o, expected 0700", dir, perm),
+			hint:   fmt.Sprintf("Run: chmod 700 %s", dir),
+		}
+	}
+
+	return doctorResult{ok: true, detail: dir}
+}
+
+// checkCredentialProcesses validates the credential_process of every profile
+func checkCredentialProcesses() doctorResult {
+	config, err := awsssolib.LoadConfigFile("")
+	if err != nil {
+		return doctorResult{detail: fmt.Sprintf("failed to load config: %v", err)}
+	}
+
+	checked := 0
+	var problems []string
+	for _, name := range config.ListProfiles() {
+		profile := config.GetProfile(name)
+		if profile.CredProcess == "" {
+			continue
+		}
+		checked++
+		if err := profile.Validate(config); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return doctorResult{
+			detail: strings.Join(problems, "; "),
+			hint:   "Re-run aws-sso-util configure for the affected profiles and make sure aws-sso-util is on PATH",
+		}
+	}
+
+	return doctorResult{ok: true, detail: fmt.Sprintf("%d credential_process profiles are valid", checked)}
+}
+
+// checkClockSkew compares the local clock with the Date header of the SSO portal
+func checkClockSkew(ssoRegion string) doctorResult {
+	endpoint := fmt.Sprintf("https://portal.sso.%s.amazonaws.com", ssoRegion)
+
+	resp, err := doctorHTTPClient.Head(endpoint)
+	if err != nil {
+		return doctorResult{detail: fmt.Sprintf("failed to reach %s: %v", endpoint, err), hint: "Check the SSO region and your network connection"}
+	}
+	resp.Body.Close()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return doctorResult{ok: true, detail: "unable to determine server time"}
+	}
+
+	return clockSkewResult(time.Since(serverTime))
+}
+
+// clockSkewResult reports whether a clock difference is acceptable
+func clockSkewResult(skew time.Duration) doctorResult {
+	if skew < 0 {
+		skew = -skew
+	}
+	skew = skew.Round(time.Second)
+
+	if skew > maxClockSkew {
+		return doctorResult{
+			detail: fmt.Sprintf("local clock differs from AWS by %s", skew),
+			hint:   "Enable time synchronization (NTP) on this machine",
+		}
+	}
+
+	return doctorResult{ok: true, detail: fmt.Sprintf("in sync with AWS (skew %s)", skew)}
+}
+
+// checkStartURL checks that the SSO start URL responds
+func checkStartURL(startURL string) doctorResult {
+	if err := awsssolib.ValidateStartURL(startURL); err != nil {
+		return doctorResult{detail: err.Error(), hint: "Check the start URL in your config"}
+	}
+
+	if err := awsssolib.CheckStartURLReachable(context.Background(), startURL); err != nil {
+		return doctorResult{detail: err.Error(), hint: "Check the start URL and your network or proxy settings"}
+	}
+
+	return doctorResult{ok: true, detail: fmt.Sprintf("%s is reachable", startURL)}
+}
+
+// checkToken checks that a valid SSO token is cached in tokenCache
+func checkToken(tokenCache *awsssolib.TokenCache, startURL string) doctorResult {
+	status, err := tokenCache.InspectCachedToken(startURL)
+	if err != nil {
+		return doctorResult{detail: fmt.Sprintf("failed to read cached token: %v", err), hint: "Run: aws-sso-util login --force-refresh"}
+	}
+
+	switch status.State {
+	case awsssolib.TokenStateMissing:
+		return doctorResult{detail: "not logged in", hint: "Run: aws-sso-util login"}
+	case awsssolib.TokenStateExpiring:
+		return doctorResult{detail: fmt.Sprintf("session expires at %s, too soon to be used", status.ExpiresAt.Local().Format("2006-01-02 15:04:05")), hint: "Run: aws-sso-util login"}
+	case awsssolib.TokenStateExpired:
+		return doctorResult{detail: fmt.Sprintf("session expired at %s", status.ExpiresAt.Local().Format("2006-01-02 15:04:05")), hint: "Run: aws-sso-util login"}
+	}
+
+	return doctorResult{ok: true, detail: fmt.Sprintf("valid until %s", status.ExpiresAt.Format("2006-01-02 15:04:05"))}
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+func TestCheckCacheDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+
+	if result := checkCacheDir(dir); !result.ok {
+		t.Errorf("Expected missing cache dir to pass, got %s", result.detail)
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Failed to create cache dir: %v", err)
+	}
+	if result := checkCacheDir(dir); result.ok || result.hint == "" {
+		t.Errorf("Expected 0755 cache dir to fail with a hint, got %+v", result)
+	}
+
+	if err := os.Chmod(dir, 0700); err != nil {
+		t.Fatalf("Failed to chmod cache dir: %v", err)
+	}
+	if result := checkCacheDir(dir); !result.ok {
+		t.Errorf("Expected 0700 cache dir to pass, got %s", result.detail)
+	}
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+
+	if result := checkConfigFile(path); !result.ok {
+		t.Errorf("Expected missing config file to pass, got %s", result.detail)
+	}
+
+	if err := os.WriteFile(path, []byte("[profile dev]\nregion = us-east-1\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if result := checkConfigFile(path); !result.ok {
+		t.Errorf("Expected config file to pass, got %s", result.detail)
+	}
+
+	if result := checkConfigFile(filepath.Dir(path)); result.ok {
+		t.Error("Expected a directory to fail")
+	}
+}
+
+func TestClockSkewResult(t *testing.T) {
+	if result := clockSkewResult(30 * time.Second); !result.ok {
+		t.Errorf("Expected small skew to pass, got %s", result.detail)
+	}
+	if result := clockSkewResult(-10 * time.Minute); result.ok {
+		t.Error("Expected large negative skew to fail")
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_SSO_CACHE_DIR", "")
+	startURL := "https://test.awsapps.com/start"
+	tokenCache := awsssolib.NewTokenCache(nil)
+
+	if result := checkToken(tokenCache, startURL); result.ok || result.detail != "not logged in" {
+		t.Errorf("Expected not logged in, got %+v", result)
+	}
+
+	expired := &awsssolib.Token{AccessToken: "token", ExpiresAt: time.Now().UTC().Add(-time.Hour)}
+	if err := awsssolib.PutCachedToken(nil, startURL, expired); err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+	if result := checkToken(tokenCache, startURL); result.ok || !strings.HasPrefix(result.detail, "session expired") {
+		t.Errorf("Expected an expired session, got %+v", result)
+	}
+
+	expiring := &awsssolib.Token{AccessToken: "token", ExpiresAt: time.Now().UTC().Add(2 * time.Minute)}
+	if err := awsssolib.PutCachedToken(nil, startURL, expiring); err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+	if result := checkToken(tokenCache, startURL); result.ok || !strings.HasPrefix(result.detail, "session expires at") {
+		t.Errorf("Expected a session about to expire, got %+v", result)
+	}
+
+	valid := &awsssolib.Token{AccessToken: "token", ExpiresAt: time.Now().UTC().Add(time.Hour)}
+	if err := awsssolib.PutCachedToken(nil, startURL, valid); err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+	if result := checkToken(tokenCache, startURL); !result.ok {
+		t.Errorf("Expected a valid token, got %+v", result)
+	}
+}
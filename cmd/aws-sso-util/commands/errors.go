@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of failed commands
+const (
+	// ExitError is the exit code of failures without a more specific code
+	ExitError = 1
+	// ExitAuthNeeded means there is no valid SSO session: log in again
+	ExitAuthNeeded = 2
+	// ExitConfigError means the configuration or arguments are invalid
+	ExitConfigError = 3
+)
+
+// commandError is the JSON output of a failed command. Kind is an
+// awsssolib.ErrorKind name, or AuthenticationNeeded, InvalidConfig,
+// Cancelled or Timeout.
+type commandError struct {
+	Error string `json:"error"`
+	Kind  string `json:"kind"`
+}
+
+// ValidateErrorFormat checks the --error-format flag of cmd and, for JSON
+// errors, keeps cobra from printing the usage on failures
+func ValidateErrorFormat(cmd *cobra.Command) error {
+	format, _ := cmd.Flags().GetString("error-format")
+	switch format {
+	case "", "text":
+		return nil
+	case "json":
+		cmd.SilenceUsage = true
+		return nil
+	default:
+		return usageErrorf("invalid --error-format %q: use text or json", format)
+	}
+}
+
+// reportedError is the error of a command that has already reported it, such
+// as in its JSON output. It exits with the exit code of the error it wraps.
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reportedError) Unwrap() error {
+	return e.err
+}
+
+// WriteError writes the error of a failed command in format: as
+// {"error", "kind"} JSON for json, and as text otherwise. Errors the command
+// has already reported are not written again.
+func WriteError(w io.Writer, err error, format string) {
+	var reported *reportedError
+	if errors.As(err, &reported) {
+		return
+	}
+	if format == "json" {
+		json.NewEncoder(w).Encode(commandError{Error: err.Error(), Kind: errorKind(err)})
+		return
+	}
+	fmt.Fprintf(w, "Error: %v\n", err)
+}
+
+// ExitCode returns the exit code of a command failing with err
+func ExitCode(err error) int {
+	switch errorKind(err) {
+	case "AuthenticationNeeded", awsssolib.ErrorKindExpiredToken.String(), awsssolib.ErrorKindUnauthorized.String():
+		return ExitAuthNeeded
+	case "InvalidConfig":
+		return ExitConfigError
+	default:
+		return ExitError
+	}
+}
+
+// errorKind names the kind of a command error
+func errorKind(err error) string {
+	var authErr *awsssolib.AuthenticationNeededError
+	var configErr *awsssolib.InvalidConfigError
+	var usageErr *usageError
+	switch {
+	case errors.Is(err, awsssolib.ErrLoginCancelled):
+		return "Cancelled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Timeout"
+	case errors.As(err, &authErr):
+		return "AuthenticationNeeded"
+	case errors.As(err, &configErr), errors.As(err, &usageErr):
+		return "InvalidConfig"
+	default:
+		return awsssolib.ClassifyError(err).String()
+	}
+}
+
+// usageError is an invalid flag, argument or profile given to a command. Like
+// an awsssolib.InvalidConfigError it exits with ExitConfigError.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// usageErrorf returns a usageError formatted like fmt.Errorf, without wrapping
+func usageErrorf(format string, args ...any) error {
+	return &usageError{message: fmt.Sprintf(format, args...)}
+}
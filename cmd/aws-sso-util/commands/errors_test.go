@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/aws/smithy-go"
+	"github.com/spf13/cobra"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind string
+		code int
+	}{
+		{fmt.Errorf("failed to list accounts: %w", &awsssolib.AuthenticationNeededError{}), "AuthenticationNeeded", ExitAuthNeeded},
+		{&smithy.GenericAPIError{Code: "UnauthorizedException"}, "Unauthorized", ExitAuthNeeded},
+		{&awsssolib.InvalidConfigError{Message: "start URL cannot be empty"}, "InvalidConfig", ExitConfigError},
+		{usageErrorf("--profile is required"), "InvalidConfig", ExitConfigError},
+		{fmt.Errorf("login failed: %w", awsssolib.ErrLoginCancelled), "Cancelled", ExitError},
+		{context.DeadlineExceeded, "Timeout", ExitError},
+		{errors.New("boom"), "Unknown", ExitError},
+	}
+	for _, tt := range tests {
+		if kind := errorKind(tt.err); kind != tt.kind {
+			t.Errorf("errorKind(%v) = %s, expected %s", tt.err, kind, tt.kind)
+		}
+		if code := ExitCode(tt.err); code != tt.code {
+			t.Errorf("ExitCode(%v) = %d, expected %d", tt.err, code, tt.code)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	err := fmt.Errorf("failed to list accounts: %w", &awsssolib.AuthenticationNeededError{})
+
+	var buf bytes.Buffer
+	WriteError(&buf, err, "json")
+	want := `{"error":"failed to list accounts: authentication needed","kind":"AuthenticationNeeded"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Expected %s, got %s", want, buf.String())
+	}
+
+	buf.Reset()
+	WriteError(&buf, err, "text")
+	if want := "Error: failed to list accounts: authentication needed\n"; buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	// An error the command has already reported keeps its exit code only
+	reported := &reportedError{err: err}
+	buf.Reset()
+	WriteError(&buf, reported, "text")
+	if buf.Len() != 0 {
+		t.Errorf("Expected a reported error not to be written again, got %q", buf.String())
+	}
+	if code := ExitCode(reported); code != ExitAuthNeeded {
+		t.Errorf("Expected the exit code of the reported error, got %d", code)
+	}
+}
+
+func TestValidateErrorFormat(t *testing.T) {
+	for format, valid := range map[string]bool{"text": true, "json": true, "xml": false} {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("error-format", format, "")
+		err := ValidateErrorFormat(cmd)
+		if (err == nil) != valid {
+			t.Errorf("ValidateErrorFormat(%s) = %v", format, err)
+		}
+		if cmd.SilenceUsage != (format == "json") {
+			t.Errorf("Expected the usage to be silenced only for JSON errors, got %v for %s", cmd.SilenceUsage, format)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// NewExportAllCommand creates the export-all command
+func NewExportAllCommand() *cobra.Command {
+	var profileNames []string
+	var allProfiles bool
+	var login bool
+
+	cmd := &cobra.Command{
+		Use:   "export-all",
+		Short: "Export credentials for multiple profiles at once",
+		Long: `Export AWS credentials for several SSO profiles at once.
+
+Credentials are fetched concurrently using a single SSO token and written to
+stdout as a JSON object keyed by profile name. Profiles that fail are reported
+on stderr without aborting the others.
+
+Examples:
+  # Export credentials for two profiles
+  aws-sso-util export-all --profile dev --profile prod
+
+  # Export credentials for every SSO profile in the config
+  aws-sso-util export-all --all`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			if len(profileNames) == 0 && !allProfiles {
+				return usageErrorf("at least one --profile or --all must be specified")
+			}
+
+			config, err := awsssolib.LoadConfigFile("")
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			var profiles []*awsssolib.Profile
+			if allProfiles {
+				profiles = config.GetSSOProfiles()
+			} else {
+				for _, name := range profileNames {
+					profile, err := config.ResolveProfile(name)
+					if err != nil {
+						return err
+					}
+					profiles = append(profiles, profile)
+				}
+			}
+
+			// Build one input per profile
+			inputs := make([]awsssolib.GetAWSConfigInput, 0, len(profiles))
+			for _, profile := range profiles {
+				inputs = append(inputs, awsssolib.GetAWSConfigInput{
+					StartURL:  profile.StartURL,
+					SSORegion: profile.SSORegion,
+					AccountID: profile.AccountID,
+					RoleName:  profile.RoleName,
+					Region:    awsssolib.ResolveRegion(awsssolib.RegionSources{Profile: profile.Region, SSORegion: profile.SSORegion}),
+					Login:     login,
+					Config:    libConfig,
+				})
+			}
+
+			results, errs := awsssolib.GetMultipleCredentials(ctx, inputs)
+
+			// Collect output keyed by profile name
+			output := make(map[string]*awsssolib.CachedCredentials)
+			failed := 0
+			for i, profile := range profiles {
+				if errs[i] != nil {
+					fmt.Fprintf(os.Stderr, "Failed to get credentials for profile '%s': %v\n", profile.Name, errs[i])
+					failed++
+					continue
+				}
+				output[profile.Name] = results[awsssolib.CredentialsKey(profile.StartURL, profile.AccountID, profile.RoleName)]
+			}
+
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(output); err != nil {
+				return err
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("failed to get credentials for %d of %d profiles", failed, len(profiles))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&profileNames, "profile", []string{}, "AWS profile name (can be specified multiple times)")
+	cmd.Flags().BoolVar(&allProfiles, "all", false, "Export credentials for all SSO profiles in the config")
+	cmd.Flags().BoolVar(&login, "login", false, "Login if needed")
+
+	return cmd
+}
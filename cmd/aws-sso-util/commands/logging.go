@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// defaultLogLevel keeps normal CLI output free of library logs
+const defaultLogLevel = "warn"
+
+// newLibraryConfig builds the library configuration from the --log-level,
+// --log-format, --no-cache and --page-size flags. Logs are written to stderr.
+func newLibraryConfig(cmd *cobra.Command) (*awsssolib.Config, error) {
+	levelName, _ := cmd.Flags().GetString("log-level")
+	format, _ := cmd.Flags().GetString("log-format")
+
+	if levelName == "" {
+		levelName = defaultLogLevel
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(levelName)); err != nil {
+		return nil, usageErrorf("invalid --log-level %q: use debug, info, warn or error", levelName)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch format {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return nil, usageErrorf("invalid --log-format %q: use text or json", format)
+	}
+
+	config := awsssolib.NewConfig(slog.New(handler), level)
+	config.NoCache, _ = cmd.Flags().GetBool("no-cache")
+	config.PageSize, _ = cmd.Flags().GetInt("page-size")
+	return config, nil
+}
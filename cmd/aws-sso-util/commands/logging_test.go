@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func newLoggingTestCommand(level, format string) *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("log-level", defaultLogLevel, "")
+	cmd.Flags().String("log-format", "text", "")
+	cmd.Flags().Set("log-level", level)
+	cmd.Flags().Set("log-format", format)
+	return cmd
+}
+
+func TestNewLibraryConfig(t *testing.T) {
+	config, err := newLibraryConfig(newLoggingTestCommand("debug", "json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.LogLevel != slog.LevelDebug {
+		t.Errorf("Expected debug level, got %v", config.LogLevel)
+	}
+	if _, ok := config.Logger.Handler().(*slog.JSONHandler); !ok {
+		t.Errorf("Expected a JSON handler, got %T", config.Logger.Handler())
+	}
+
+	config, err = newLibraryConfig(newLoggingTestCommand("warn", "text"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Logger.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("Expected info logs to be disabled at warn level")
+	}
+
+	if _, err := newLibraryConfig(newLoggingTestCommand("verbose", "text")); err == nil {
+		t.Error("Expected an error for an invalid log level")
+	}
+	if _, err := newLibraryConfig(newLoggingTestCommand("info", "xml")); err == nil {
+		t.Error("Expected an error for an invalid log format")
+	}
+}
+
+func TestNewLibraryConfigNoCache(t *testing.T) {
+	cmd := newLoggingTestCommand("warn", "text")
+	cmd.Flags().Bool("no-cache", false, "")
+
+	config, err := newLibraryConfig(cmd)
+	if err != nil || config.NoCache {
+		t.Fatalf("Expected caches by default, got %+v (%v)", config, err)
+	}
+
+	cmd.Flags().Set("no-cache", "true")
+	config, err = newLibraryConfig(cmd)
+	if err != nil || !config.NoCache {
+		t.Errorf("Expected --no-cache to disable caches, got %+v (%v)", config, err)
+	}
+}
+
+func TestNewLibraryConfigPageSize(t *testing.T) {
+	cmd := newLoggingTestCommand("warn", "text")
+	cmd.Flags().Int("page-size", 0, "")
+	cmd.Flags().Set("page-size", "25")
+
+	config, err := newLibraryConfig(cmd)
+	if err != nil || config.PageSize != 25 {
+		t.Errorf("Expected a page size of 25, got %+v (%v)", config, err)
+	}
+}
@@ -2,9 +2,12 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log/slog"
+	"io"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/adonmo/aws-sso-lib-go/awsssolib"
 	"github.com/spf13/cobra"
@@ -15,6 +18,14 @@ func NewLoginCommand() *cobra.Command {
 	var forceRefresh bool
 	var disableBrowser bool
 	var verbose bool
+	var preflight bool
+	var profileName string
+	var timeout time.Duration
+	var jsonOutput bool
+	var minValidity time.Duration
+	var pkce bool
+	var redirectPort int
+	var scopes []string
 
 	cmd := &cobra.Command{
 		Use:   "login",
@@ -31,60 +42,124 @@ Examples:
   # Login with specific SSO instance
   aws-sso-util login --start-url https://my-sso.awsapps.com/start --sso-region us-east-1
 
+  # Login to the SSO instance of a profile
+  aws-sso-util login --profile prod
+
   # Force re-authentication
-  aws-sso-util login --force-refresh`,
+  aws-sso-util login --force-refresh
+
+  # Check the portal and SSO region before starting the device flow
+  aws-sso-util login --preflight
+
+  # Log in again unless the session lasts at least two more hours
+  aws-sso-util login --min-validity 2h
+
+  # Give up if the login isn't approved within two minutes
+  aws-sso-util login --timeout 2m
+
+  # Log in with the authorization code flow with PKCE instead of the device
+  # flow, in a browser on this machine, with scopes for a trusted application
+  aws-sso-util login --pkce --scope sso:account:access --scope codewhisperer:completions
+
+  # Print the outcome as JSON on stdout, e.g. to record the expiry in CI
+  aws-sso-util login --json`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
+			quiet := verbose || jsonOutput
 
-			// Setup logging based on verbose flag
-			var config *awsssolib.Config
+			// Setup logging; --verbose is a shortcut for --log-level debug
 			if verbose {
-				// Create debug logger for verbose mode
-				debugLogger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-					Level: slog.LevelDebug,
-				}))
-				config = awsssolib.NewConfig(debugLogger, slog.LevelDebug)
-			} else {
-				// Use default config (INFO level)
-				config = awsssolib.DefaultConfig()
+				_ = cmd.Flags().Set("log-level", "debug")
 			}
 
-			// Get SSO configuration
-			startURL, _ := cmd.Flags().GetString("start-url")
-			ssoRegion, _ := cmd.Flags().GetString("sso-region")
+			output, err := func() (*awsssolib.LoginOutput, error) {
+				config, err := newLibraryConfig(cmd)
+				if err != nil {
+					return nil, err
+				}
 
-			// Try to find configuration if not provided
-			if startURL == "" || ssoRegion == "" {
-				instance, err := awsssolib.FindInstance("")
+				// Get SSO configuration
+				startURL, ssoRegion, err := findInstance(cmd, profileName)
 				if err != nil {
-					return fmt.Errorf("no SSO configuration found. Please provide --start-url and --sso-region or set AWS_DEFAULT_SSO_START_URL and AWS_DEFAULT_SSO_REGION")
+					return nil, err
 				}
-				if startURL == "" {
-					startURL = instance.StartURL
+
+				// Log in again now rather than have the session expire mid-task
+				refresh := forceRefresh
+				if minValidity > 0 && !refresh {
+					refresh, err = awsssolib.NewTokenCache(config).NeedsLogin(startURL, minValidity)
+					if err != nil {
+						return nil, fmt.Errorf("failed to check cached token: %w", err)
+					}
 				}
-				if ssoRegion == "" {
-					ssoRegion = instance.Region
+
+				// Reuse a valid cached token unless a new login is forced
+				if pkce && !refresh {
+					refresh, err = awsssolib.NewTokenCache(config).NeedsLogin(startURL, 0)
+					if err != nil {
+						return nil, fmt.Errorf("failed to check cached token: %w", err)
+					}
 				}
-			}
 
-			// Perform login
-			if !verbose {
-				fmt.Fprintf(os.Stderr, "Logging in to %s...\n", startURL)
-			}
+				// Perform login
+				if !quiet {
+					fmt.Fprintf(os.Stderr, "Logging in to %s...\n", startURL)
+				}
 
-			output, err := awsssolib.Login(ctx, awsssolib.LoginInput{
-				StartURL:       startURL,
-				SSORegion:      ssoRegion,
-				ForceRefresh:   forceRefresh,
-				DisableBrowser: disableBrowser,
-				Config:         config,
-			})
+				if pkce && refresh {
+					output, err := awsssolib.LoginWithPKCE(ctx, awsssolib.LoginWithPKCEInput{
+						StartURL:       startURL,
+						SSORegion:      ssoRegion,
+						Scopes:         scopes,
+						RedirectPort:   redirectPort,
+						DisableBrowser: disableBrowser,
+						Timeout:        timeout,
+						Config:         config,
+					})
+					if err != nil {
+						return nil, fmt.Errorf("login failed: %w", err)
+					}
+					return output, nil
+				}
+
+				quietFlag, _ := cmd.Flags().GetBool("quiet")
+				output, err := awsssolib.Login(ctx, awsssolib.LoginInput{
+					StartURL:       startURL,
+					SSORegion:      ssoRegion,
+					ForceRefresh:   refresh,
+					DisableBrowser: disableBrowser,
+					Preflight:      preflight,
+					Timeout:        timeout,
+					Quiet:          quietFlag,
+					Config:         config,
+				})
+				if err != nil {
+					return nil, fmt.Errorf("login failed: %w", err)
+				}
+				return output, nil
+			}()
+
+			// Report failures as JSON too, and exit without the human error message
+			if jsonOutput {
+				if writeErr := writeLoginJSON(os.Stdout, output, err); writeErr != nil {
+					return writeErr
+				}
+				if err != nil {
+					cmd.SilenceUsage = true
+					return &reportedError{err: err}
+				}
+				return nil
+			}
 			if err != nil {
-				return fmt.Errorf("login failed: %w", err)
+				return err
 			}
 
-			if !verbose {
-				fmt.Fprintf(os.Stderr, "Successfully logged in!\n")
+			if !quiet {
+				if output.Source == awsssolib.LoginSourceCached {
+					fmt.Fprintf(os.Stderr, "Already logged in\n")
+				} else {
+					fmt.Fprintf(os.Stderr, "Successfully logged in!\n")
+				}
 				fmt.Fprintf(os.Stderr, "Token expires at: %s\n", output.ExpiresAt.Format("2006-01-02 15:04:05"))
 			}
 
@@ -94,49 +169,113 @@ Examples:
 
 	cmd.Flags().BoolVar(&forceRefresh, "force-refresh", false, "Force re-authentication even if valid token exists")
 	cmd.Flags().BoolVar(&disableBrowser, "disable-browser", false, "Disable automatic browser opening")
-	cmd.Flags().BoolVar(&verbose, "verbose", false, "Enable verbose debug logging")
+	cmd.Flags().BoolVar(&verbose, "verbose", false, "Enable verbose debug logging (same as --log-level debug)")
+	cmd.Flags().BoolVar(&preflight, "preflight", false, "Check the start URL and SSO region before logging in")
+	cmd.Flags().StringVar(&profileName, "profile", "", "AWS profile whose SSO instance to log in to")
+	cmd.Flags().DurationVar(&minValidity, "min-validity", 0, "Log in again if the cached token expires within this duration")
+	cmd.Flags().DurationVar(&timeout, "timeout", awsssolib.DefaultLoginTimeout, "Maximum time to wait for the login to complete")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the result, or the error, as JSON on stdout")
+	cmd.Flags().BoolVar(&pkce, "pkce", false, "Log in with the authorization code flow with PKCE in a browser on this machine")
+	cmd.Flags().IntVar(&redirectPort, "redirect-port", 0, "Port of the --pkce redirect server on 127.0.0.1 (default: any free port)")
+	cmd.Flags().StringArrayVar(&scopes, "scope", nil, "Scope to request with --pkce, can be repeated (default: sso:account:access)")
 
 	return cmd
 }
 
+// loginResult is the JSON output of a successful login
+type loginResult struct {
+	StartURL  string                `json:"startUrl"`
+	ExpiresAt time.Time             `json:"expiresAt"`
+	Source    awsssolib.LoginSource `json:"source"`
+}
+
+// writeLoginJSON writes the outcome of a login as JSON: the result when
+// loginErr is nil, and otherwise the error with its kind
+func writeLoginJSON(w io.Writer, output *awsssolib.LoginOutput, loginErr error) error {
+	encoder := json.NewEncoder(w)
+	if loginErr != nil {
+		return encoder.Encode(commandError{
+			Error: loginErr.Error(),
+			Kind:  errorKind(loginErr),
+		})
+	}
+	return encoder.Encode(loginResult{
+		StartURL:  output.StartURL,
+		ExpiresAt: output.ExpiresAt.UTC(),
+		Source:    output.Source,
+	})
+}
+
 // NewLogoutCommand creates the logout command
 func NewLogoutCommand() *cobra.Command {
+	var profileName string
+	var accountQuery string
+	var roleName string
+
 	cmd := &cobra.Command{
 		Use:   "logout",
 		Short: "Log out from AWS SSO",
 		Long: `Log out from AWS SSO and remove cached credentials.
 
+With --account and --role, only the cached credentials of that role are
+removed, for example after its policies changed, and the SSO session is kept.
+
 Examples:
   # Logout using environment variables or config
   aws-sso-util logout
 
   # Logout from specific SSO instance
-  aws-sso-util logout --start-url https://my-sso.awsapps.com/start --sso-region us-east-1`,
+  aws-sso-util logout --start-url https://my-sso.awsapps.com/start --sso-region us-east-1
+
+  # Logout from the SSO instance of a profile
+  aws-sso-util logout --profile prod
+
+  # Only remove the cached credentials of one role
+  aws-sso-util logout --account 123456789012 --role AdministratorAccess`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
 
+			if (accountQuery == "") != (roleName == "") {
+				return usageErrorf("--account and --role must be used together")
+			}
+
 			// Get SSO configuration
-			startURL, _ := cmd.Flags().GetString("start-url")
-			ssoRegion, _ := cmd.Flags().GetString("sso-region")
+			startURL, ssoRegion, err := findInstance(cmd, profileName)
+			if err != nil {
+				return err
+			}
 
-			// Try to find configuration if not provided
-			if startURL == "" || ssoRegion == "" {
-				instance, err := awsssolib.FindInstance("")
+			config, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			if accountQuery != "" {
+				accountID, err := resolveAccountID(ctx, config, startURL, ssoRegion, accountQuery, false)
+				if err != nil {
+					return err
+				}
+				sessionNames, err := ssoSessionNames(startURL)
 				if err != nil {
-					return fmt.Errorf("no SSO configuration found. Please provide --start-url and --sso-region or set AWS_DEFAULT_SSO_START_URL and AWS_DEFAULT_SSO_REGION")
+					return err
 				}
-				if startURL == "" {
-					startURL = instance.StartURL
+
+				removed, err := deleteRoleCredentials(awsssolib.CLICacheDir(), startURL, sessionNames, accountID, roleName)
+				if err != nil {
+					return fmt.Errorf("failed to remove cached credentials: %w", err)
 				}
-				if ssoRegion == "" {
-					ssoRegion = instance.Region
+				if removed == 0 {
+					fmt.Fprintf(os.Stderr, "No cached credentials for %s in %s\n", roleName, accountID)
+					return nil
 				}
+				fmt.Fprintf(os.Stderr, "Removed cached credentials for %s in %s; the SSO session is kept\n", roleName, accountID)
+				return nil
 			}
 
 			// Perform logout
 			fmt.Fprintf(os.Stderr, "Logging out from %s...\n", startURL)
 
-			err := awsssolib.Logout(ctx, startURL, ssoRegion, nil)
+			err = awsssolib.LogoutWithConfig(ctx, startURL, ssoRegion, nil, config)
 			if err != nil {
 				return fmt.Errorf("logout failed: %w", err)
 			}
@@ -147,5 +286,53 @@ Examples:
 		},
 	}
 
+	cmd.Flags().StringVar(&profileName, "profile", "", "AWS profile whose SSO instance to log out from")
+	cmd.Flags().StringVar(&accountQuery, "account", "", "Only remove the cached credentials of this account (or a unique prefix/suffix of its ID); requires --role")
+	cmd.Flags().StringVar(&roleName, "role", "", "Only remove the cached credentials of this role; requires --account")
+
 	return cmd
 }
+
+// ssoSessionNames returns the names of the sso-sessions of startURL in the
+// AWS config
+func ssoSessionNames(startURL string) ([]string, error) {
+	config, err := awsssolib.LoadConfigFile("")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range config.ListSSOSessions() {
+		if config.GetSSOSession(name).StartURL == startURL {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// deleteRoleCredentials removes the credentials of a role cached in dir by
+// credential-process, and by the AWS CLI through profiles with the start URL
+// or one of the sso-sessions in sessionNames. It returns the number of cache
+// entries removed.
+func deleteRoleCredentials(dir, startURL string, sessionNames []string, accountID, roleName string) (int, error) {
+	keys := []string{
+		awsssolib.CredentialCacheKey(startURL, accountID, roleName),
+		awsssolib.AWSCLICredentialCacheKey(startURL, "", accountID, roleName),
+	}
+	for _, name := range sessionNames {
+		keys = append(keys, awsssolib.AWSCLICredentialCacheKey(startURL, name, accountID, roleName))
+	}
+
+	removed := 0
+	for _, key := range keys {
+		err := os.Remove(filepath.Join(dir, key+".json"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+func TestWriteLoginJSON(t *testing.T) {
+	var buf bytes.Buffer
+	output := &awsssolib.LoginOutput{
+		StartURL:  "https://corp.awsapps.com/start",
+		ExpiresAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+		Source:    awsssolib.LoginSourceRefreshed,
+	}
+	if err := writeLoginJSON(&buf, output, nil); err != nil {
+		t.Fatalf("writeLoginJSON failed: %v", err)
+	}
+	want := `{"startUrl":"https://corp.awsapps.com/start","expiresAt":"2024-05-01T10:00:00Z","source":"refreshed"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Expected %s, got %s", want, buf.String())
+	}
+
+	buf.Reset()
+	loginErr := fmt.Errorf("login failed: %w", awsssolib.ErrLoginCancelled)
+	if err := writeLoginJSON(&buf, nil, loginErr); err != nil {
+		t.Fatalf("writeLoginJSON failed: %v", err)
+	}
+	want = `{"error":"login failed: SSO login cancelled","kind":"Cancelled"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Expected %s, got %s", want, buf.String())
+	}
+}
+
+func TestDeleteRoleCredentials(t *testing.T) {
+	dir := t.TempDir()
+	startURL := "https://my-sso.awsapps.com/start"
+	write := func(key string) string {
+		path := filepath.Join(dir, key+".json")
+		if err := os.WriteFile(path, []byte(`{"ProviderType":"sso"}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	library := write(awsssolib.CredentialCacheKey(startURL, "123456789012", "Admin"))
+	admin := write(awsssolib.AWSCLICredentialCacheKey(startURL, "", "123456789012", "Admin"))
+	adminSession := write(awsssolib.AWSCLICredentialCacheKey(startURL, "my-sso", "123456789012", "Admin"))
+	readOnly := write(awsssolib.AWSCLICredentialCacheKey(startURL, "", "123456789012", "ReadOnly"))
+	otherAccount := write(awsssolib.AWSCLICredentialCacheKey(startURL, "", "210987654321", "Admin"))
+
+	removed, err := deleteRoleCredentials(dir, startURL, []string{"my-sso"}, "123456789012", "Admin")
+	if err != nil || removed != 3 {
+		t.Fatalf("Expected 3 entries removed, got %d (%v)", removed, err)
+	}
+	for _, path := range []string{library, admin, adminSession} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	for _, path := range []string{readOnly, otherAccount} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept: %v", path, err)
+		}
+	}
+
+	if removed, err := deleteRoleCredentials(dir, startURL, nil, "123456789012", "Admin"); err != nil || removed != 0 {
+		t.Errorf("Expected nothing left to remove, got %d (%v)", removed, err)
+	}
+}
@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// myAccess is what the logged-in user can access, reported by my-access
+type myAccess struct {
+	StartURL string `json:"startUrl"`
+	// ExpiresAt is the expiry of the SSO session, nil if it isn't cached
+	ExpiresAt *time.Time      `json:"expiresAt,omitempty"`
+	Accounts  []accountAccess `json:"accounts"`
+}
+
+// accountAccess is an account with the roles the user can assume in it
+type accountAccess struct {
+	AccountID   string   `json:"accountId"`
+	AccountName string   `json:"accountName"`
+	Roles       []string `json:"roles"`
+}
+
+// NewMyAccessCommand creates the my-access command
+func NewMyAccessCommand() *cobra.Command {
+	var login bool
+	var forceRefresh bool
+	var format string
+	var maxConcurrency int
+
+	cmd := &cobra.Command{
+		Use:   "my-access",
+		Short: "Show the accounts and roles you can access right now",
+		Long: `Show the accounts and roles you can access right now.
+
+Lists each account you can access with the roles (permission sets) you can
+assume in it on one line, sorted by account name, and when your SSO session
+expires.
+
+Examples:
+  # Show what you can access
+  aws-sso-util my-access
+
+  # Log in first if needed
+  aws-sso-util my-access --login
+
+  # Output as JSON, e.g. for a script
+  aws-sso-util my-access --format json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			if format != "table" && format != "json" {
+				return usageErrorf("unsupported format %q: use table or json", format)
+			}
+			if err := validateMaxConcurrency(maxConcurrency); err != nil {
+				return err
+			}
+
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			startURL, ssoRegion, err := resolveInstance(cmd, "")
+			if err != nil {
+				return err
+			}
+
+			groups, err := awsssolib.ListAccessibleAccountRoles(ctx, awsssolib.ListRolesInput{
+				StartURL:       startURL,
+				SSORegion:      ssoRegion,
+				Login:          login,
+				ForceRefresh:   forceRefresh,
+				MaxConcurrency: maxConcurrency,
+				Config:         libConfig,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list access: %w", err)
+			}
+
+			access := newMyAccess(startURL, groups)
+			if status, err := awsssolib.NewTokenCache(libConfig).InspectCachedToken(startURL); err == nil && status.State != awsssolib.TokenStateMissing {
+				access.ExpiresAt = &status.ExpiresAt
+			}
+
+			if format == "json" {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(access)
+			}
+			return printMyAccess(os.Stdout, access, time.Now())
+		},
+	}
+
+	cmd.Flags().BoolVar(&login, "login", false, "Login if needed")
+	cmd.Flags().BoolVar(&forceRefresh, "force-refresh", false, "Log in again even if a valid token is cached")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format (table, json)")
+	cmd.Flags().IntVar(&maxConcurrency, "max-concurrency", awsssolib.DefaultMaxConcurrency, "Number of accounts whose roles are listed at once")
+
+	return cmd
+}
+
+// newMyAccess builds the access report of the accounts of a start URL
+func newMyAccess(startURL string, groups []awsssolib.AccountRoles) *myAccess {
+	access := &myAccess{StartURL: startURL, Accounts: []accountAccess{}}
+	for _, group := range groups {
+		account := accountAccess{
+			AccountID:   group.Account.AccountID,
+			AccountName: group.Account.AccountName,
+			Roles:       []string{},
+		}
+		for _, role := range group.Roles {
+			account.Roles = append(account.Roles, role.RoleName)
+		}
+		access.Accounts = append(access.Accounts, account)
+	}
+	return access
+}
+
+// printMyAccess writes the access report for people, one account per line
+func printMyAccess(w io.Writer, access *myAccess, now time.Time) error {
+	if access.ExpiresAt != nil {
+		fmt.Fprintf(w, "Session for %s expires %s (in %s)\n\n", access.StartURL,
+			access.ExpiresAt.Local().Format("2006-01-02 15:04:05"), access.ExpiresAt.Sub(now).Round(time.Minute))
+	} else {
+		fmt.Fprintf(w, "Session for %s\n\n", access.StartURL)
+	}
+
+	if len(access.Accounts) == 0 {
+		fmt.Fprintln(w, "No accessible accounts")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT NAME\tACCOUNT ID\tROLES")
+	fmt.Fprintln(tw, "------------\t----------\t-----")
+	for _, account := range access.Accounts {
+		roles := strings.Join(account.Roles, ", ")
+		if roles == "" {
+			roles = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", account.AccountName, account.AccountID, roles)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+func TestPrintMyAccess(t *testing.T) {
+	access := newMyAccess("https://my-sso.awsapps.com/start", []awsssolib.AccountRoles{
+		{
+			Account: awsssolib.Account{AccountID: "123456789012", AccountName: "Production"},
+			Roles:   []awsssolib.Role{{RoleName: "AdministratorAccess"}, {RoleName: "ReadOnly"}},
+		},
+		{Account: awsssolib.Account{AccountID: "210987654321", AccountName: "Sandbox"}},
+	})
+	if len(access.Accounts) != 2 || access.Accounts[1].Roles == nil {
+		t.Fatalf("Expected both accounts with non-nil roles, got %+v", access.Accounts)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(2 * time.Hour)
+	access.ExpiresAt = &expiresAt
+
+	var buf bytes.Buffer
+	if err := printMyAccess(&buf, access, now); err != nil {
+		t.Fatalf("printMyAccess failed: %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		"Session for https://my-sso.awsapps.com/start expires",
+		"(in 2h0m0s)",
+		"Production    123456789012  AdministratorAccess, ReadOnly",
+		"Sandbox       210987654321  -",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in:\n%s", want, output)
+		}
+	}
+
+	buf.Reset()
+	if err := printMyAccess(&buf, newMyAccess("https://my-sso.awsapps.com/start", nil), now); err != nil {
+		t.Fatalf("printMyAccess failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "No accessible accounts") || strings.Contains(buf.String(), "expires") {
+		t.Errorf("Expected no accounts and no expiry, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// NewPortalCommand creates the portal command
+func NewPortalCommand() *cobra.Command {
+	var disableBrowser bool
+	var profileName string
+
+	cmd := &cobra.Command{
+		Use:   "portal",
+		Short: "Open the AWS SSO access portal in the browser",
+		Long: `Open the AWS SSO access portal (the start URL) in your browser.
+
+Examples:
+  # Open the portal of the configured SSO instance
+  aws-sso-util portal
+
+  # Open the portal of a profile's SSO instance
+  aws-sso-util portal --profile prod
+
+  # Print the portal URL instead of opening it
+  aws-sso-util portal --disable-browser`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			startURL, _, err := findInstance(cmd, profileName)
+			if err != nil {
+				return err
+			}
+
+			if disableBrowser {
+				fmt.Println(startURL)
+				return nil
+			}
+
+			fmt.Fprintf(os.Stderr, "Opening %s\n", startURL)
+			if err := awsssolib.NewBrowserLauncher(false).OpenURL(startURL); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to open browser: %v\nOpen the following URL manually:\n", err)
+				fmt.Println(startURL)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&disableBrowser, "disable-browser", false, "Print the portal URL instead of opening it")
+	cmd.Flags().StringVar(&profileName, "profile", "", "AWS profile whose SSO instance to open")
+
+	return cmd
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// fuzzyPageSize is the number of matches the fuzzy picker shows at a time
+const fuzzyPageSize = 15
+
+// picker asks the user to choose one of several items
+type picker interface {
+	// Pick returns the index of the chosen item
+	Pick(prompt string, items []string) (int, error)
+}
+
+// newPicker returns a fuzzy picker when stdin is a terminal, and otherwise a
+// numbered list that reads one selection, so scripted input keeps working
+func newPicker(in *bufio.Reader, out io.Writer) picker {
+	if stdinIsTerminal() {
+		return &fuzzyPicker{in: in, out: out}
+	}
+	return &listPicker{in: in, out: out}
+}
+
+// stdinIsTerminal reports whether stdin is a terminal
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// listPicker shows all items numbered and reads the number of one
+type listPicker struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *listPicker) Pick(prompt string, items []string) (int, error) {
+	fmt.Fprintln(p.out)
+	for i, item := range items {
+		fmt.Fprintf(p.out, "[%d] %s\n", i+1, item)
+	}
+
+	fmt.Fprintf(p.out, "\n%s (enter number): ", prompt)
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		return 0, err
+	}
+
+	selection, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || selection < 1 || selection > len(items) {
+		return 0, fmt.Errorf("invalid selection")
+	}
+	return selection - 1, nil
+}
+
+// fuzzyPicker narrows the items with each line typed until one is chosen by
+// its number in the list shown, or by pressing enter when a single item matches
+type fuzzyPicker struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *fuzzyPicker) Pick(prompt string, items []string) (int, error) {
+	query := ""
+	for {
+		matches := fuzzyFilter(items, query)
+
+		fmt.Fprintln(p.out)
+		if len(matches) == 0 {
+			fmt.Fprintf(p.out, "No matches for %q\n", query)
+		}
+		for i, index := range matches {
+			if i == fuzzyPageSize {
+				fmt.Fprintf(p.out, "... and %d more, type to narrow the list\n", len(matches)-fuzzyPageSize)
+				break
+			}
+			fmt.Fprintf(p.out, "[%d] %s\n", i+1, items[index])
+		}
+
+		fmt.Fprintf(p.out, "\n%s (type to filter, number to select): ", prompt)
+		line, err := p.in.ReadString('\n')
+		if err != nil && line == "" {
+			return 0, err
+		}
+		line = strings.TrimSpace(line)
+
+		// Numbers in the list select, other numbers such as parts of account IDs filter
+		if selection, err := strconv.Atoi(line); err == nil && selection >= 1 && selection <= min(len(matches), fuzzyPageSize) {
+			return matches[selection-1], nil
+		}
+		if line == "" && len(matches) == 1 {
+			return matches[0], nil
+		}
+		query = line
+	}
+}
+
+// fuzzyFilter returns the indexes of the items matching every word of query.
+// A word matches when its letters appear in order in the item, ignoring case.
+func fuzzyFilter(items []string, query string) []int {
+	words := strings.Fields(strings.ToLower(query))
+
+	var matches []int
+	for i, item := range items {
+		item = strings.ToLower(item)
+		matched := true
+		for _, word := range words {
+			if !fuzzyMatch(item, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// fuzzyMatch reports whether the runes of word appear in order in text
+func fuzzyMatch(text, word string) bool {
+	for _, r := range word {
+		i := strings.IndexRune(text, r)
+		if i < 0 {
+			return false
+		}
+		text = text[i+len(string(r)):]
+	}
+	return true
+}
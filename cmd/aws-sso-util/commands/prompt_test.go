@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var pickerTestItems = []string{
+	"123456789012 - prod (Admin)",
+	"123456789012 - prod (ReadOnly)",
+	"210987654321 - dev (Developer)",
+	"210987654321 - dev (Admin)",
+}
+
+func TestFuzzyFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{0, 1, 2, 3}},
+		{"admin", []int{0, 3}},
+		{"dev adm", []int{3}},
+		{"prdro", []int{1}},
+		{"9012", []int{0, 1}},
+		{"staging", nil},
+	}
+	for _, tt := range tests {
+		if got := fuzzyFilter(pickerTestItems, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("fuzzyFilter(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyPicker(t *testing.T) {
+	pick := func(input string) (int, error) {
+		p := &fuzzyPicker{in: bufio.NewReader(strings.NewReader(input)), out: io.Discard}
+		return p.Pick("Select a role", pickerTestItems)
+	}
+
+	// Narrow the list, then choose by the number shown
+	if got, err := pick("admin\n2\n"); err != nil || got != 3 {
+		t.Errorf("Expected the dev Admin role, got %d (%v)", got, err)
+	}
+	// Enter picks the only match
+	if got, err := pick("developer\n\n"); err != nil || got != 2 {
+		t.Errorf("Expected the only match, got %d (%v)", got, err)
+	}
+	// Numbers beyond the list filter by account ID
+	if got, err := pick("4321\n1\n"); err != nil || got != 2 {
+		t.Errorf("Expected the first dev role, got %d (%v)", got, err)
+	}
+	if _, err := pick("admin\n"); err != io.EOF {
+		t.Errorf("Expected EOF without a selection, got %v", err)
+	}
+}
+
+func TestListPicker(t *testing.T) {
+	p := &listPicker{in: bufio.NewReader(strings.NewReader("3\n")), out: io.Discard}
+	if got, err := p.Pick("Select a role", pickerTestItems); err != nil || got != 2 {
+		t.Errorf("Expected the third item, got %d (%v)", got, err)
+	}
+
+	p = &listPicker{in: bufio.NewReader(strings.NewReader("5\n")), out: io.Discard}
+	if _, err := p.Pick("Select a role", pickerTestItems); err == nil {
+		t.Error("Expected an error for a selection out of range")
+	}
+}
@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// defaultExpiryWarning is how close to expiry a cached token triggers a warning
+const defaultExpiryWarning = 15 * time.Minute
+
+// resolveInstance resolves the SSO instance like findInstance and warns on
+// stderr when the cached token for it is about to expire
+func resolveInstance(cmd *cobra.Command, profileName string) (string, string, error) {
+	startURL, ssoRegion, err := findInstance(cmd, profileName)
+	if err != nil {
+		return "", "", err
+	}
+
+	warnTokenExpiry(cmd, startURL)
+	return startURL, ssoRegion, nil
+}
+
+// warnTokenExpiry prints a warning when the cached token expires within the
+// --expiry-warning threshold, unless --quiet is set
+func warnTokenExpiry(cmd *cobra.Command, startURL string) {
+	if quiet, _ := cmd.Flags().GetBool("quiet"); quiet {
+		return
+	}
+
+	threshold, err := cmd.Flags().GetDuration("expiry-warning")
+	if err != nil {
+		threshold = defaultExpiryWarning
+	}
+
+	cfg, err := newLibraryConfig(cmd)
+	if err != nil {
+		return
+	}
+
+	if message := cachedTokenExpiryWarning(cfg, startURL, threshold, time.Now()); message != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+	}
+}
+
+// cachedTokenExpiryWarning returns the warning for the cached token of
+// startURL in the cache directory of cfg, or "" when there is none. The token
+// is inspected rather than read with GetCachedToken, which hides a token in
+// the minutes before its expiry, when the warning matters most.
+func cachedTokenExpiryWarning(cfg *awsssolib.Config, startURL string, threshold time.Duration, now time.Time) string {
+	status, err := awsssolib.NewTokenCache(cfg).InspectCachedToken(startURL)
+	if err != nil || status.State == awsssolib.TokenStateMissing || status.State == awsssolib.TokenStateExpired {
+		return ""
+	}
+	return tokenExpiryWarning(status.ExpiresAt, threshold, now)
+}
+
+// tokenExpiryWarning returns the warning for a token expiring at expiresAt, or
+// "" when it is valid for longer than threshold
+func tokenExpiryWarning(expiresAt time.Time, threshold time.Duration, now time.Time) string {
+	remaining := expiresAt.Sub(now)
+	if threshold <= 0 || remaining > threshold {
+		return ""
+	}
+
+	minutes := int(remaining.Round(time.Minute) / time.Minute)
+	unit := "minutes"
+	if minutes == 1 {
+		unit = "minute"
+	}
+	return fmt.Sprintf("Your SSO session expires in %d %s; run aws-sso-util login to refresh", minutes, unit)
+}
+
+// findInstance returns the SSO start URL and region from the --start-url and
+// --sso-region flags, falling back to the named profile, the environment, a
+// .aws-sso project file or the AWS config. Flags take precedence over the profile.
+func findInstance(cmd *cobra.Command, profileName string) (string, string, error) {
+	startURL, _ := cmd.Flags().GetString("start-url")
+	ssoRegion, _ := cmd.Flags().GetString("sso-region")
+
+	if startURL == "" || ssoRegion == "" {
+		instance, err := awsssolib.FindInstance(profileName)
+		if err != nil {
+			var configErr *awsssolib.InvalidConfigError
+			var remoteErr awsssolib.RemoteConfigError
+			if profileName != "" || errors.As(err, &configErr) || errors.As(err, &remoteErr) {
+				return "", "", err
+			}
+			return "", "", usageErrorf("no SSO configuration found. Please provide --start-url and --sso-region or set AWS_DEFAULT_SSO_START_URL and AWS_DEFAULT_SSO_REGION")
+		}
+		if startURL == "" {
+			startURL = instance.StartURL
+		}
+		if ssoRegion == "" {
+			ssoRegion = instance.Region
+		}
+	}
+
+	return startURL, ssoRegion, nil
+}
+
+// remoteProfileTemplate returns the profile template of the remote config at
+// AWS_SSO_CONFIG_URL when it is for startURL, or "" otherwise
+func remoteProfileTemplate(ctx context.Context, startURL string) string {
+	configURL := os.Getenv(awsssolib.RemoteConfigURLEnvVar)
+	if configURL == "" {
+		return ""
+	}
+	instance, err := awsssolib.FindInstanceFromURL(ctx, configURL)
+	if err != nil || instance.StartURL != startURL {
+		return ""
+	}
+	return instance.ProfileTemplate
+}
+
+// resolveAccountID expands a partial --account value to a full account ID by
+// matching it against the accounts available through SSO. Full IDs are returned
+// without calling SSO.
+func resolveAccountID(ctx context.Context, cfg *awsssolib.Config, startURL, ssoRegion, query string, login bool) (string, error) {
+	if awsssolib.IsFullAccountID(query) {
+		return query, nil
+	}
+
+	accounts, err := awsssolib.ListAvailableAccounts(ctx, awsssolib.ListAccountsInput{
+		StartURL:  startURL,
+		SSORegion: ssoRegion,
+		Login:     login,
+		Config:    cfg,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	return awsssolib.ResolveAccountID(accounts, query)
+}
+
+// resolveRoleName returns the canonical name of a role in an account, matching
+// the --role value case-insensitively
+func resolveRoleName(ctx context.Context, cfg *awsssolib.Config, startURL, ssoRegion, accountID, query string, login bool) (string, error) {
+	roles, err := awsssolib.ListAvailableRoles(ctx, awsssolib.ListRolesInput{
+		StartURL:   startURL,
+		SSORegion:  ssoRegion,
+		AccountIDs: []string{accountID},
+		Login:      login,
+		Config:     cfg,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	return awsssolib.ResolveRoleName(roles, accountID, query)
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+func TestTokenExpiryWarning(t *testing.T) {
+	now := time.Date(2024, 12, 19, 12, 0, 0, 0, time.UTC)
+
+	if warning := tokenExpiryWarning(now.Add(time.Hour), 15*time.Minute, now); warning != "" {
+		t.Errorf("Expected no warning for a long-lived token, got %q", warning)
+	}
+
+	warning := tokenExpiryWarning(now.Add(12*time.Minute), 15*time.Minute, now)
+	if !strings.Contains(warning, "expires in 12 minutes") {
+		t.Errorf("Expected warning about 12 minutes, got %q", warning)
+	}
+
+	if warning := tokenExpiryWarning(now.Add(12*time.Minute), 0, now); warning != "" {
+		t.Errorf("Expected a zero threshold to disable the warning, got %q", warning)
+	}
+}
+
+func TestCachedTokenExpiryWarning(t *testing.T) {
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	startURL := "https://test.awsapps.com/start"
+	cfg := &awsssolib.Config{SSOCacheDir: t.TempDir()}
+	now := time.Now()
+
+	if warning := cachedTokenExpiryWarning(cfg, startURL, 15*time.Minute, now); warning != "" {
+		t.Errorf("Expected no warning without a cached token, got %q", warning)
+	}
+
+	// A token this close to its expiry isn't returned by GetCachedToken
+	token := &awsssolib.Token{AccessToken: "token", ExpiresAt: now.Add(3 * time.Minute)}
+	if err := awsssolib.NewTokenCache(cfg).PutCachedToken(startURL, token); err != nil {
+		t.Fatalf("PutCachedToken failed: %v", err)
+	}
+	warning := cachedTokenExpiryWarning(cfg, startURL, 15*time.Minute, now)
+	if !strings.Contains(warning, "expires in 3 minutes") {
+		t.Errorf("Expected a warning about 3 minutes, got %q", warning)
+	}
+}
@@ -14,7 +14,10 @@ import (
 func NewRolesCommand() *cobra.Command {
 	var accountIDs []string
 	var login bool
+	var forceRefresh bool
 	var format string
+	var groupByAccount bool
+	var maxConcurrency int
 
 	cmd := &cobra.Command{
 		Use:   "roles",
@@ -33,35 +36,47 @@ Examples:
   # List roles and login if needed
   aws-sso-util roles --login
 
+  # Log in again first, even with a valid cached token
+  aws-sso-util roles --force-refresh
+
   # Output in different formats
-  aws-sso-util roles --format json`,
+  aws-sso-util roles --format json
+
+  # Group roles under their accounts, sorted by account name
+  aws-sso-util roles --group-by-account
+
+  # Export roles for a spreadsheet
+  aws-sso-util roles --format csv > roles.csv
+
+  # List the roles of more accounts at once in a large organization
+  aws-sso-util roles --max-concurrency 10`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
 
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			if err := validateMaxConcurrency(maxConcurrency); err != nil {
+				return err
+			}
+
 			// Get SSO configuration
-			startURL, _ := cmd.Flags().GetString("start-url")
-			ssoRegion, _ := cmd.Flags().GetString("sso-region")
-
-			// Try to find configuration if not provided
-			if startURL == "" || ssoRegion == "" {
-				instance, err := awsssolib.FindInstance("")
-				if err != nil {
-					return fmt.Errorf("no SSO configuration found. Please provide --start-url and --sso-region or set AWS_DEFAULT_SSO_START_URL and AWS_DEFAULT_SSO_REGION")
-				}
-				if startURL == "" {
-					startURL = instance.StartURL
-				}
-				if ssoRegion == "" {
-					ssoRegion = instance.Region
-				}
+			startURL, ssoRegion, err := resolveInstance(cmd, "")
+			if err != nil {
+				return err
 			}
 
 			// List roles
 			roles, err := awsssolib.ListAvailableRoles(ctx, awsssolib.ListRolesInput{
-				StartURL:   startURL,
-				SSORegion:  ssoRegion,
-				AccountIDs: accountIDs,
-				Login:      login,
+				StartURL:       startURL,
+				SSORegion:      ssoRegion,
+				AccountIDs:     accountIDs,
+				Login:          login,
+				ForceRefresh:   forceRefresh,
+				MaxConcurrency: maxConcurrency,
+				Config:         libConfig,
 			})
 			if err != nil {
 				return fmt.Errorf("failed to list roles: %w", err)
@@ -72,7 +87,14 @@ Examples:
 			case "json":
 				// TODO: Implement JSON output
 				return fmt.Errorf("JSON output not yet implemented")
+			case "csv":
+				return awsssolib.WriteRolesCSV(os.Stdout, roles)
 			default:
+				if groupByAccount {
+					printRolesByAccount(roles)
+					return nil
+				}
+
 				// Table output
 				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 				fmt.Fprintln(w, "ACCOUNT ID\tACCOUNT NAME\tROLE NAME")
@@ -91,7 +113,31 @@ Examples:
 
 	cmd.Flags().StringSliceVar(&accountIDs, "account", []string{}, "Filter by account ID (can be specified multiple times)")
 	cmd.Flags().BoolVar(&login, "login", false, "Login if needed")
-	cmd.Flags().StringVar(&format, "format", "table", "Output format (table, json)")
+	cmd.Flags().BoolVar(&forceRefresh, "force-refresh", false, "Log in again even if a valid token is cached")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format (table, json, csv)")
+	cmd.Flags().BoolVar(&groupByAccount, "group-by-account", false, "Group table output by account, sorted by account name")
+	cmd.Flags().IntVar(&maxConcurrency, "max-concurrency", awsssolib.DefaultMaxConcurrency, "Number of accounts whose roles are listed at once")
 
 	return cmd
 }
+
+// validateMaxConcurrency checks the value of --max-concurrency
+func validateMaxConcurrency(maxConcurrency int) error {
+	if maxConcurrency < 1 {
+		return usageErrorf("invalid --max-concurrency %d: must be at least 1", maxConcurrency)
+	}
+	return nil
+}
+
+// printRolesByAccount prints roles under account headers
+func printRolesByAccount(roles []awsssolib.Role) {
+	for i, group := range awsssolib.GroupRolesByAccount(roles) {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%s)\n", group.Account.AccountName, group.Account.AccountID)
+		for _, role := range group.Roles {
+			fmt.Printf("  %s\n", role.RoleName)
+		}
+	}
+}
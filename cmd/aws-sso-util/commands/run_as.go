@@ -2,21 +2,30 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"syscall"
+	"strings"
+	"time"
 
 	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/spf13/cobra"
 )
 
 // NewRunAsCommand creates the run-as command
 func NewRunAsCommand() *cobra.Command {
+	var profileName string
 	var accountID string
 	var roleName string
 	var region string
 	var login bool
+	var duration time.Duration
+	var execMode bool
+	var noExec bool
+	var dryRun bool
 
 	cmd := &cobra.Command{
 		Use:   "run-as -- <command> [args...]",
@@ -30,54 +39,140 @@ Examples:
   # Run AWS CLI command
   aws-sso-util run-as --account 123456789012 --role MyRole -- aws s3 ls
 
+  # Use the SSO settings of a configured profile
+  aws-sso-util run-as --profile prod -- aws s3 ls
+
+  # Use the account and role pinned by a .aws-sso file in the project
+  aws-sso-util run-as -- terraform plan
+
   # Run with specific region
   aws-sso-util run-as --account 123456789012 --role MyRole --region us-west-2 -- aws ec2 describe-instances
 
   # Run any command that uses AWS credentials
-  aws-sso-util run-as --account 123456789012 --role MyRole -- terraform plan`,
+  aws-sso-util run-as --account 123456789012 --role MyRole -- terraform plan
+
+  # Use a unique prefix or suffix of the account ID
+  aws-sso-util run-as --account 9012 --role MyRole -- aws sts get-caller-identity
+
+  # Warn if the credentials won't last for a two-hour job
+  aws-sso-util run-as --account 123456789012 --role MyRole --duration 2h -- ./long-job.sh
+
+  # Keep aws-sso-util running as the parent of the command
+  aws-sso-util run-as --account 123456789012 --role MyRole --no-exec -- terraform apply
+
+  # Show the account, role, region, command and environment without running it
+  aws-sso-util run-as --profile prod --dry-run -- terraform plan
+
+The credential expiry is printed to stderr before the command starts. The
+credential lifetime is set by the session duration of the permission set.
+
+On Unix, run-as replaces itself with the command by default (--exec). With
+--no-exec, and always on Windows, the command runs as a child process that
+receives forwarded signals.`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
 
-			// Validate required flags
-			if accountID == "" || roleName == "" {
-				return fmt.Errorf("--account and --role are required")
+			libConfig, err := newLibraryConfig(cmd)
+			if err != nil {
+				return err
 			}
 
 			// Get SSO configuration
 			startURL, _ := cmd.Flags().GetString("start-url")
 			ssoRegion, _ := cmd.Flags().GetString("sso-region")
+			var profileRegion string
+
+			// Fill in values from the profile; flags take precedence
+			if profileName != "" {
+				config, err := awsssolib.LoadConfigFile("")
+				if err != nil {
+					return fmt.Errorf("failed to load config: %w", err)
+				}
+
+				profile, err := config.ResolveProfile(profileName)
+				if err != nil {
+					return err
+				}
+
+				if startURL == "" {
+					startURL = profile.StartURL
+				}
+				if ssoRegion == "" {
+					ssoRegion = profile.SSORegion
+				}
+				if accountID == "" {
+					accountID = profile.AccountID
+				}
+				if roleName == "" {
+					roleName = profile.RoleName
+				}
+				profileRegion = profile.Region
+			}
+
+			// Fall back to the account and role pinned by a .aws-sso project file
+			if profileName == "" && (accountID == "" || roleName == "") {
+				project, err := awsssolib.FindProjectConfig("")
+				if err != nil {
+					return err
+				}
+				if project != nil {
+					if accountID == "" {
+						accountID = project.AccountID
+					}
+					if roleName == "" {
+						roleName = project.RoleName
+					}
+				}
+			}
+
+			// Validate required flags
+			if accountID == "" || roleName == "" {
+				return usageErrorf("--account and --role (or --profile, or a .aws-sso file) are required")
+			}
 
 			// Try to find configuration if not provided
 			if startURL == "" || ssoRegion == "" {
-				instance, err := awsssolib.FindInstance("")
+				instanceURL, instanceRegion, err := findInstance(cmd, profileName)
 				if err != nil {
-					return fmt.Errorf("no SSO configuration found. Please provide --start-url and --sso-region or set AWS_DEFAULT_SSO_START_URL and AWS_DEFAULT_SSO_REGION")
+					return err
 				}
 				if startURL == "" {
-					startURL = instance.StartURL
+					startURL = instanceURL
 				}
 				if ssoRegion == "" {
-					ssoRegion = instance.Region
+					ssoRegion = instanceRegion
 				}
 			}
+			warnTokenExpiry(cmd, startURL)
 
-			// Default region if not specified
-			if region == "" {
-				region = os.Getenv("AWS_DEFAULT_REGION")
-				if region == "" {
-					region = "us-east-1"
-				}
+			// Expand a partial account ID
+			resolvedAccountID, err := resolveAccountID(ctx, libConfig, startURL, ssoRegion, accountID, login)
+			if err != nil {
+				return err
+			}
+
+			// Match the role name case-insensitively
+			resolvedRoleName, err := resolveRoleName(ctx, libConfig, startURL, ssoRegion, resolvedAccountID, roleName, login)
+			if err != nil {
+				return err
 			}
 
+			region = awsssolib.ResolveRegion(awsssolib.RegionSources{
+				Flag:      region,
+				Profile:   profileRegion,
+				SSORegion: ssoRegion,
+			})
+
 			// Get AWS config
 			cfg, err := awsssolib.GetAWSConfig(ctx, awsssolib.GetAWSConfigInput{
 				StartURL:  startURL,
 				SSORegion: ssoRegion,
-				AccountID: accountID,
-				RoleName:  roleName,
+				AccountID: resolvedAccountID,
+				RoleName:  resolvedRoleName,
 				Region:    region,
 				Login:     login,
+				Config:    libConfig,
 			})
 			if err != nil {
 				return fmt.Errorf("failed to get AWS config: %w", err)
@@ -89,47 +184,153 @@ Examples:
 				return fmt.Errorf("failed to get credentials: %w", err)
 			}
 
+			if creds.CanExpire && !creds.Expires.IsZero() {
+				fmt.Fprintf(os.Stderr, "Credentials expire at %s (in %s)\n",
+					creds.Expires.Local().Format("2006-01-02 15:04:05"), time.Until(creds.Expires).Round(time.Second))
+				if warning := credentialExpiryWarning(creds.Expires, duration, time.Now()); warning != "" {
+					fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+				}
+			}
+
+			if dryRun {
+				printRunAsDryRun(os.Stdout, resolvedAccountID, resolvedRoleName, region, args,
+					credentialEnv(nil, creds, region, resolvedAccountID, resolvedRoleName))
+				return nil
+			}
+
 			// Set up environment
-			env := os.Environ()
-			env = setEnv(env, "AWS_ACCESS_KEY_ID", creds.AccessKeyID)
-			env = setEnv(env, "AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey)
-			env = setEnv(env, "AWS_SESSION_TOKEN", creds.SessionToken)
-			env = setEnv(env, "AWS_DEFAULT_REGION", region)
-			env = setEnv(env, "AWS_REGION", region)
-
-			// Execute command
-			command := args[0]
-			commandArgs := args[1:]
-
-			execCmd := exec.Command(command, commandArgs...)
-			execCmd.Env = env
-			execCmd.Stdin = os.Stdin
-			execCmd.Stdout = os.Stdout
-			execCmd.Stderr = os.Stderr
-
-			err = execCmd.Run()
-			if err != nil {
-				// Try to get the exit code
-				if exitErr, ok := err.(*exec.ExitError); ok {
-					if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-						os.Exit(status.ExitStatus())
-					}
+			env := credentialEnv(os.Environ(), creds, region, resolvedAccountID, resolvedRoleName)
+
+			if noExec {
+				execMode = false
+			}
+			if execMode {
+				if !execSupported {
+					return fmt.Errorf("--exec is not supported on this platform, use --no-exec")
 				}
+				return execCommand(args[0], args[1:], env)
+			}
+
+			// Execute command, forwarding signals and propagating its exit code
+			exitCode, err := runCommand(args[0], args[1:], env)
+			if err != nil {
 				return err
 			}
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
 
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&accountID, "account", "", "AWS account ID")
-	cmd.Flags().StringVar(&roleName, "role", "", "SSO role name")
-	cmd.Flags().StringVar(&region, "region", "", "AWS region")
+	cmd.Flags().StringVar(&profileName, "profile", "", "AWS profile to read the SSO configuration, account, role and region from")
+	cmd.Flags().StringVar(&accountID, "account", "", "AWS account ID (or a unique prefix/suffix of it)")
+	cmd.Flags().StringVar(&roleName, "role", "", "SSO role name (case-insensitive)")
+	cmd.Flags().StringVar(&region, "region", "", "AWS region (default: profile region, AWS_REGION, AWS_DEFAULT_REGION, SSO region, then us-east-1)")
 	cmd.Flags().BoolVar(&login, "login", true, "Login if needed")
+	cmd.Flags().DurationVar(&duration, "duration", 0, "Expected run time of the command; warns if the credentials expire sooner")
+	cmd.Flags().BoolVar(&execMode, "exec", execSupported, "Replace aws-sso-util with the command (Unix only)")
+	cmd.Flags().BoolVar(&noExec, "no-exec", false, "Run the command as a child process instead of replacing aws-sso-util")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the account, role, region, command and environment (secrets redacted) without running the command")
+
+	registerAccountRoleCompletion(cmd)
 
 	return cmd
 }
 
+// minCredentialLifetime is the remaining lifetime below which run-as warns
+// even without --duration
+const minCredentialLifetime = 15 * time.Minute
+
+// credentialExpiryWarning returns a warning when credentials expiring at
+// expires won't last for the expected duration of the command
+func credentialExpiryWarning(expires time.Time, duration time.Duration, now time.Time) string {
+	remaining := expires.Sub(now)
+
+	if duration > 0 && remaining < duration {
+		return fmt.Sprintf("credentials expire in %s, before the expected duration of %s; the permission set's session duration limits the credential lifetime",
+			remaining.Round(time.Second), duration)
+	}
+	if duration == 0 && remaining < minCredentialLifetime {
+		return fmt.Sprintf("credentials expire in %s", remaining.Round(time.Second))
+	}
+
+	return ""
+}
+
+// credentialEnv adds the credentials, region and SSO identity to an environment
+func credentialEnv(env []string, creds aws.Credentials, region, accountID, roleName string) []string {
+	env = setEnv(env, "AWS_ACCESS_KEY_ID", creds.AccessKeyID)
+	env = setEnv(env, "AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey)
+	env = setEnv(env, "AWS_SESSION_TOKEN", creds.SessionToken)
+	if creds.CanExpire && !creds.Expires.IsZero() {
+		env = setEnv(env, "AWS_CREDENTIAL_EXPIRATION", formatExpiration(creds.Expires))
+	}
+	env = setEnv(env, "AWS_DEFAULT_REGION", region)
+	env = setEnv(env, "AWS_REGION", region)
+	env = setEnv(env, "AWS_SSO_ACCOUNT_ID", accountID)
+	env = setEnv(env, "AWS_SSO_ROLE_NAME", roleName)
+	return env
+}
+
+// redactedEnvVars are the credential variables whose values run-as --dry-run hides
+var redactedEnvVars = map[string]bool{
+	"AWS_SECRET_ACCESS_KEY": true,
+	"AWS_SESSION_TOKEN":     true,
+}
+
+// printRunAsDryRun describes what run-as would run: the resolved identity and
+// region, the command and the variables added to its environment, with the
+// secret key and session token redacted
+func printRunAsDryRun(w io.Writer, accountID, roleName, region string, args []string, env []string) {
+	fmt.Fprintf(w, "Account: %s\n", accountID)
+	fmt.Fprintf(w, "Role:    %s\n", roleName)
+	fmt.Fprintf(w, "Region:  %s\n", region)
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	fmt.Fprintf(w, "Command: %s\n", strings.Join(quoted, " "))
+
+	fmt.Fprintln(w, "Environment:")
+	for _, e := range env {
+		key, value, _ := strings.Cut(e, "=")
+		if redactedEnvVars[key] {
+			value = "<redacted>"
+		}
+		fmt.Fprintf(w, "  %s=%s\n", key, value)
+	}
+}
+
+// shellQuote quotes an argument for a POSIX shell when it holds spaces or
+// quotes
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// commandExitCode returns the exit code to propagate for the error of a
+// finished command, on any platform. A command killed by a signal has no exit
+// code and reports 1. Errors other than a non-zero exit, such as a command
+// that couldn't be started, are returned.
+func commandExitCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, err
+	}
+	if code := exitErr.ExitCode(); code >= 0 {
+		return code, nil
+	}
+	return 1, nil
+}
+
 // setEnv sets or updates an environment variable in the env slice
 func setEnv(env []string, key, value string) []string {
 	prefix := key + "="
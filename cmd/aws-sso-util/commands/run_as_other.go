@@ -0,0 +1,36 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package commands
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// execSupported reports whether run-as can replace itself with the command
+const execSupported = false
+
+// runCommand runs a command and returns the exit code to propagate.
+//
+// Console interrupts reach the child directly, so run-as ignores them and waits
+// for the child to exit.
+func runCommand(name string, args []string, env []string) (int, error) {
+	execCmd := exec.Command(name, args...)
+	execCmd.Env = env
+	execCmd.Stdin = os.Stdin
+	execCmd.Stdout = os.Stdout
+	execCmd.Stderr = os.Stderr
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+
+	return commandExitCode(execCmd.Run())
+}
+
+// execCommand is not supported on this platform
+func execCommand(name string, args []string, env []string) error {
+	return errors.New("--exec is not supported on this platform")
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestCredentialExpiryWarning(t *testing.T) {
+	now := time.Date(2024, 12, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		expires  time.Time
+		duration time.Duration
+		warn     bool
+	}{
+		{"long lifetime", now.Add(time.Hour), 0, false},
+		{"short lifetime", now.Add(5 * time.Minute), 0, true},
+		{"outlasts duration", now.Add(time.Hour), 30 * time.Minute, false},
+		{"expires before duration", now.Add(time.Hour), 2 * time.Hour, true},
+	}
+
+	for _, tt := range tests {
+		warning := credentialExpiryWarning(tt.expires, tt.duration, now)
+		if (warning != "") != tt.warn {
+			t.Errorf("%s: expected warning=%v, got %q", tt.name, tt.warn, warning)
+		}
+	}
+}
+
+func TestCredentialEnv(t *testing.T) {
+	expires := time.Date(2024, 12, 19, 13, 0, 0, 0, time.FixedZone("IST", 5*3600+1800))
+	creds := aws.Credentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		CanExpire:       true,
+		Expires:         expires,
+	}
+
+	env := credentialEnv([]string{"PATH=/usr/bin", "AWS_REGION=eu-west-1"}, creds, "us-west-2", "123456789012", "Admin")
+
+	values := make(map[string]string)
+	for _, e := range env {
+		if i := strings.Index(e, "="); i >= 0 {
+			values[e[:i]] = e[i+1:]
+		}
+	}
+
+	expected := map[string]string{
+		"PATH":                      "/usr/bin",
+		"AWS_ACCESS_KEY_ID":         "AKIAEXAMPLE",
+		"AWS_CREDENTIAL_EXPIRATION": "2024-12-19T07:30:00Z",
+		"AWS_REGION":                "us-west-2",
+		"AWS_SSO_ACCOUNT_ID":        "123456789012",
+		"AWS_SSO_ROLE_NAME":         "Admin",
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected %s=%s, got %s", key, value, values[key])
+		}
+	}
+}
+
+// TestRunAsHelperProcess is the child of the exit code tests. It exits with
+// the code in RUN_AS_HELPER_EXIT_CODE and does nothing otherwise.
+func TestRunAsHelperProcess(t *testing.T) {
+	value := os.Getenv("RUN_AS_HELPER_EXIT_CODE")
+	if value == "" {
+		return
+	}
+	code, _ := strconv.Atoi(value)
+	os.Exit(code)
+}
+
+// helperCommand returns the name and arguments running the test binary as a
+// child exiting with code, with the environment to pass
+func helperCommand(code int) (string, []string, []string) {
+	env := append(os.Environ(), "RUN_AS_HELPER_EXIT_CODE="+strconv.Itoa(code))
+	return os.Args[0], []string{"-test.run=^TestRunAsHelperProcess$"}, env
+}
+
+func TestCommandExitCode(t *testing.T) {
+	for _, want := range []int{0, 3} {
+		name, args, env := helperCommand(want)
+		child := exec.Command(name, args...)
+		child.Env = env
+		code, err := commandExitCode(child.Run())
+		if err != nil {
+			t.Fatalf("commandExitCode failed: %v", err)
+		}
+		if code != want {
+			t.Errorf("Expected exit code %d, got %d", want, code)
+		}
+	}
+
+	startErr := errors.New("executable file not found")
+	if _, err := commandExitCode(startErr); err != startErr {
+		t.Errorf("Expected the start error back, got %v", err)
+	}
+}
+
+func TestRunCommandPropagatesExitCode(t *testing.T) {
+	name, args, env := helperCommand(7)
+	code, err := runCommand(name, args, env)
+	if err != nil {
+		t.Fatalf("runCommand failed: %v", err)
+	}
+	if code != 7 {
+		t.Errorf("Expected exit code 7, got %d", code)
+	}
+}
+
+func TestPrintRunAsDryRun(t *testing.T) {
+	creds := aws.Credentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "super-secret",
+		SessionToken:    "session-token",
+	}
+	env := credentialEnv(nil, creds, "us-west-2", "123456789012", "Admin")
+
+	var buf bytes.Buffer
+	printRunAsDryRun(&buf, "123456789012", "Admin", "us-west-2", []string{"aws", "s3", "ls", "my bucket", "it's"}, env)
+	out := buf.String()
+
+	for _, want := range []string{
+		"Account: 123456789012\n",
+		"Role:    Admin\n",
+		"Region:  us-west-2\n",
+		`Command: aws s3 ls 'my bucket' 'it'\''s'` + "\n",
+		"  AWS_ACCESS_KEY_ID=AKIAEXAMPLE\n",
+		"  AWS_SECRET_ACCESS_KEY=<redacted>\n",
+		"  AWS_SESSION_TOKEN=<redacted>\n",
+		"  AWS_REGION=us-west-2\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "super-secret") || strings.Contains(out, "session-token") {
+		t.Errorf("Expected the secrets to be redacted, got:\n%s", out)
+	}
+}
@@ -0,0 +1,133 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package commands
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// execSupported reports whether run-as can replace itself with the command
+const execSupported = true
+
+// forwardedSignals are relayed from run-as to the child's process group
+var forwardedSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+}
+
+// runCommand runs a command in its own process group and returns the exit code
+// to propagate.
+//
+// Signals sent to run-as are forwarded to the child's process group. When
+// run-as owns the terminal, the child's group becomes the foreground group so
+// it reads terminal input and receives Ctrl-C and Ctrl-Z directly. If the child
+// is stopped, run-as stops as well and resumes the child when it is continued.
+func runCommand(name string, args []string, env []string) (int, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return 0, err
+	}
+
+	tty := int(os.Stdin.Fd())
+	foreground := isForeground(tty)
+
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if foreground {
+		attr.Foreground = true
+		attr.Ctty = tty
+	}
+
+	sigs := make(chan os.Signal, 8)
+	signal.Notify(sigs, forwardedSignals...)
+	defer signal.Stop(sigs)
+
+	process, err := os.StartProcess(path, append([]string{name}, args...), &os.ProcAttr{
+		Env:   env,
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr},
+		Sys:   attr,
+	})
+	if err != nil {
+		return 0, err
+	}
+	pgid := process.Pid
+
+	if foreground {
+		defer setForeground(tty, syscall.Getpgrp())
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				_ = syscall.Kill(-pgid, sig.(syscall.Signal))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		var status syscall.WaitStatus
+		if _, err := syscall.Wait4(process.Pid, &status, syscall.WUNTRACED, nil); err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return 0, err
+		}
+
+		switch {
+		case status.Exited():
+			return status.ExitStatus(), nil
+		case status.Signaled():
+			return 128 + int(status.Signal()), nil
+		case status.Stopped():
+			// Suspend run-as too so the shell sees the job as stopped
+			if foreground {
+				setForeground(tty, syscall.Getpgrp())
+			}
+			_ = syscall.Kill(syscall.Getpid(), syscall.SIGSTOP)
+
+			// Continued: hand the terminal back and resume the child
+			if foreground {
+				setForeground(tty, pgid)
+			}
+			_ = syscall.Kill(-pgid, syscall.SIGCONT)
+		}
+	}
+}
+
+// isForeground reports whether fd is a terminal whose foreground process group is ours
+func isForeground(fd int) bool {
+	var pgrp int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp)))
+	return errno == 0 && int(pgrp) == syscall.Getpgrp()
+}
+
+// setForeground makes pgrp the foreground process group of the terminal fd
+func setForeground(fd int, pgrp int) {
+	// A background process changing the foreground group receives SIGTTOU
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	p := int32(pgrp)
+	_, _, _ = syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&p)))
+}
+
+// execCommand replaces the run-as process with the command
+func execCommand(name string, args []string, env []string) error {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, append([]string{name}, args...), env)
+}
@@ -0,0 +1,29 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package commands
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRunCommandExitCode(t *testing.T) {
+	tests := []struct {
+		script   string
+		expected int
+	}{
+		{"exit 0", 0},
+		{"exit 3", 3},
+		{"kill -TERM $$", 128 + 15},
+	}
+
+	for _, tt := range tests {
+		code, err := runCommand("sh", []string{"-c", tt.script}, os.Environ())
+		if err != nil {
+			t.Fatalf("%s: runCommand failed: %v", tt.script, err)
+		}
+		if code != tt.expected {
+			t.Errorf("%s: expected exit code %d, got %d", tt.script, tt.expected, code)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+	"github.com/spf13/cobra"
+)
+
+// NewSwitchCommand creates the switch command
+func NewSwitchCommand() *cobra.Command {
+	var export bool
+	var noBackup bool
+
+	cmd := &cobra.Command{
+		Use:   "switch <profile>",
+		Short: "Make an SSO profile the default profile",
+		Long: `Make an SSO profile the default profile.
+
+The settings of the profile are copied into the [default] section of the AWS
+config file, so AWS tools use its account and role without --profile. With
+--export, the config file is left alone and an export command for AWS_PROFILE
+is printed instead, to switch only the current shell.
+
+Examples:
+  # Use the prod profile by default
+  aws-sso-util switch prod
+
+  # Switch only the current shell
+  eval "$(aws-sso-util switch prod --export)"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profileName := args[0]
+
+			config, err := awsssolib.LoadConfigFile("")
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if export {
+				if _, err := ssoProfile(config, profileName); err != nil {
+					return err
+				}
+				fmt.Printf("export AWS_PROFILE=%s\n", profileName)
+				return nil
+			}
+
+			profile, err := switchDefaultProfile(config, profileName)
+			if err != nil {
+				return err
+			}
+
+			err = config.SaveConfigFileWithOptions("", awsssolib.SaveOptions{NoBackup: noBackup})
+			if err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+
+			fmt.Fprintf(os.Stderr, "Default profile now uses %s as %s (from profile %s)\n",
+				profile.AccountID, profile.RoleName, profileName)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&export, "export", false, "Print an AWS_PROFILE export command instead of changing the config file")
+	cmd.Flags().BoolVar(&noBackup, "no-backup", false, "Don't back up the config file before writing it")
+
+	return cmd
+}
+
+// ssoProfile returns a profile, resolved through its sso_session, after
+// checking that it is a complete SSO profile
+func ssoProfile(config *awsssolib.ConfigFile, name string) (*awsssolib.Profile, error) {
+	if config.GetProfile(name) == nil {
+		return nil, usageErrorf("profile '%s' not found", name)
+	}
+
+	resolved, err := config.ResolveProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	if resolved.StartURL == "" || resolved.SSORegion == "" || resolved.AccountID == "" || resolved.RoleName == "" {
+		return nil, usageErrorf("profile '%s' is not an SSO profile: it needs a start URL, SSO region, account and role", name)
+	}
+
+	return resolved, nil
+}
+
+// switchDefaultProfile copies an SSO profile into the default profile and
+// returns the resolved profile
+func switchDefaultProfile(config *awsssolib.ConfigFile, name string) (*awsssolib.Profile, error) {
+	if name == "default" {
+		return nil, usageErrorf("profile 'default' is already the default profile")
+	}
+
+	resolved, err := ssoProfile(config, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy the profile as written so an sso_session reference is kept. The
+	// default profile is the user's, not managed by configure populate.
+	defaultProfile := *config.GetProfile(name)
+	defaultProfile.Name = "default"
+	defaultProfile.Extra = make(map[string]string, len(defaultProfile.Extra))
+	for key, value := range config.GetProfile(name).Extra {
+		if key != managedByKey {
+			defaultProfile.Extra[key] = value
+		}
+	}
+	config.SetProfile(&defaultProfile)
+
+	return resolved, nil
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/adonmo/aws-sso-lib-go/awsssolib"
+)
+
+func TestSwitchDefaultProfile(t *testing.T) {
+	config := awsssolib.NewConfigFile()
+	config.SetSSOSession(&awsssolib.SSOSession{
+		Name:      "corp",
+		StartURL:  "https://corp.awsapps.com/start",
+		SSORegion: "us-east-1",
+	})
+	config.SetProfile(&awsssolib.Profile{
+		Name:       "prod",
+		SSOSession: "corp",
+		AccountID:  "123456789012",
+		RoleName:   "Admin",
+		Region:     "eu-west-1",
+		Extra:      map[string]string{managedByKey: managedByValue, "output": "json"},
+	})
+	config.SetProfile(&awsssolib.Profile{Name: "static", Region: "us-east-1"})
+
+	profile, err := switchDefaultProfile(config, "prod")
+	if err != nil {
+		t.Fatalf("switchDefaultProfile failed: %v", err)
+	}
+	if profile.StartURL != "https://corp.awsapps.com/start" {
+		t.Errorf("Expected the resolved start URL, got %q", profile.StartURL)
+	}
+
+	defaultProfile := config.GetProfile("default")
+	if defaultProfile == nil {
+		t.Fatal("Expected a default profile")
+	}
+	if defaultProfile.SSOSession != "corp" || defaultProfile.AccountID != "123456789012" ||
+		defaultProfile.RoleName != "Admin" || defaultProfile.Region != "eu-west-1" {
+		t.Errorf("Expected the prod settings in the default profile, got %+v", defaultProfile)
+	}
+	if config.GetProfile("prod").Name != "prod" || !isManaged(config.GetProfile("prod")) {
+		t.Error("Expected the prod profile to be unchanged")
+	}
+	if isManaged(defaultProfile) || defaultProfile.Extra["output"] != "json" {
+		t.Errorf("Expected the default profile to keep its settings but not the managed marker, got %+v", defaultProfile.Extra)
+	}
+	if removed := pruneManagedProfiles(config); len(removed) != 1 || removed[0] != "prod" {
+		t.Errorf("Expected only prod to be pruned, got %v", removed)
+	}
+	if config.GetProfile("default") == nil {
+		t.Error("Expected the default profile to survive pruning")
+	}
+
+	for _, name := range []string{"missing", "static", "default"} {
+		if _, err := switchDefaultProfile(config, name); err == nil {
+			t.Errorf("Expected an error switching to %s", name)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// NewVersionCommand creates the version command for the given release version
+func NewVersionCommand(version string) *cobra.Command {
+	var verbose bool
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show the version of aws-sso-util",
+		Long: `Show the version of aws-sso-util.
+
+With --verbose, the Go version, commit, build date and platform the binary
+was built with are shown too. Include them in bug reports.
+
+Examples:
+  # Show the version, like --version
+  aws-sso-util version
+
+  # Show the build details for a bug report
+  aws-sso-util version --verbose`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !verbose {
+				fmt.Fprintln(os.Stdout, version)
+				return nil
+			}
+
+			info, _ := debug.ReadBuildInfo()
+			return writeVersionDetails(os.Stdout, version, info)
+		},
+	}
+
+	cmd.Flags().BoolVar(&verbose, "verbose", false, "Show the Go version, commit, build date and platform")
+
+	return cmd
+}
+
+// writeVersionDetails writes the version with the build details of info,
+// which is nil when the binary carries no build information
+func writeVersionDetails(w io.Writer, version string, info *debug.BuildInfo) error {
+	goVersion := runtime.Version()
+	commit := "unknown"
+	buildDate := "unknown"
+
+	if info != nil {
+		// Binaries installed with go install carry the module version
+		if version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		}
+		goVersion = info.GoVersion
+
+		modified := false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				commit = setting.Value
+			case "vcs.time":
+				buildDate = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if modified && commit != "unknown" {
+			commit += " (modified)"
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Version:\t%s\n", version)
+	fmt.Fprintf(tw, "Go version:\t%s\n", goVersion)
+	fmt.Fprintf(tw, "Commit:\t%s\n", commit)
+	fmt.Fprintf(tw, "Build date:\t%s\n", buildDate)
+	fmt.Fprintf(tw, "Platform:\t%s/%s\n", runtime.GOOS, runtime.GOARCH)
+	return tw.Flush()
+}
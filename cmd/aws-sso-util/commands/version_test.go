@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"bytes"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestWriteVersionDetails(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeVersionDetails(&buf, "1.2.3", &debug.BuildInfo{
+		GoVersion: "go1.22.5",
+		Main:      debug.Module{Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2024-05-01T10:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("writeVersionDetails failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"Version:     1.2.3",
+		"Go version:  go1.22.5",
+		"Commit:      abc123 (modified)",
+		"Build date:  2024-05-01T10:00:00Z",
+		"Platform:    " + runtime.GOOS + "/" + runtime.GOARCH,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, buf.String())
+		}
+	}
+
+	// Without build information the details are unknown, and go install
+	// builds report their module version
+	buf.Reset()
+	if err := writeVersionDetails(&buf, "dev", nil); err != nil {
+		t.Fatalf("writeVersionDetails failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Commit:      unknown") || !strings.Contains(buf.String(), "Version:     dev") {
+		t.Errorf("Expected unknown build details, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeVersionDetails(&buf, "dev", &debug.BuildInfo{Main: debug.Module{Version: "v0.4.0"}}); err != nil {
+		t.Fatalf("writeVersionDetails failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Version:     v0.4.0") {
+		t.Errorf("Expected the module version, got:\n%s", buf.String())
+	}
+}
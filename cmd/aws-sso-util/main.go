@@ -1,8 +1,8 @@
 package main
 
 import (
-	"fmt"
 	"os"
+	"time"
 
 	"github.com/adonmo/aws-sso-lib-go/cmd/aws-sso-util/commands"
 	"github.com/spf13/cobra"
@@ -22,31 +22,57 @@ It provides utilities for:
 - Listing available accounts and roles
 - Running commands with specific credentials
 - Opening AWS Console in browser
-- Admin functions for SSO management`,
+- Admin functions for SSO management
+
+Commands exit with status 1 on failure, 2 when a login is needed and 3 for
+invalid configuration. With --error-format json, the error is written to
+stderr as {"error": "...", "kind": "..."}.`,
 		Version: version,
+		// Errors are written by main in the --error-format
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return commands.ValidateErrorFormat(cmd)
+		},
 	}
 
 	// Global flags
 	rootCmd.PersistentFlags().String("start-url", "", "AWS SSO start URL")
 	rootCmd.PersistentFlags().String("sso-region", "", "AWS SSO region")
+	rootCmd.PersistentFlags().Bool("quiet", false, "Suppress warnings and reminders, such as an expiring SSO session")
+	rootCmd.PersistentFlags().Duration("expiry-warning", 15*time.Minute, "Warn when the SSO session expires within this duration")
+	rootCmd.PersistentFlags().String("log-level", "warn", "Log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().String("log-format", "text", "Log format (text, json)")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Bypass the token and credential caches; logs in again, interactively")
+	rootCmd.PersistentFlags().Int("page-size", 0, "Accounts or roles requested per SSO list call, 1-100 (default 100)")
+	rootCmd.PersistentFlags().String("error-format", "text", "Error format on failure (text, json)")
 
 	// Add commands
 	rootCmd.AddCommand(commands.NewConfigureCommand())
 	rootCmd.AddCommand(commands.NewLoginCommand())
 	rootCmd.AddCommand(commands.NewLogoutCommand())
+	rootCmd.AddCommand(commands.NewAccountsCommand())
 	rootCmd.AddCommand(commands.NewRolesCommand())
+	rootCmd.AddCommand(commands.NewMyAccessCommand())
+	rootCmd.AddCommand(commands.NewSwitchCommand())
 	rootCmd.AddCommand(commands.NewRunAsCommand())
 	rootCmd.AddCommand(commands.NewConsoleCommand())
+	rootCmd.AddCommand(commands.NewPortalCommand())
 	rootCmd.AddCommand(commands.NewCheckCommand())
+	rootCmd.AddCommand(commands.NewDoctorCommand())
 	rootCmd.AddCommand(commands.NewAdminCommand())
 	rootCmd.AddCommand(commands.NewCredentialProcessCommand())
+	rootCmd.AddCommand(commands.NewExportAllCommand())
+	rootCmd.AddCommand(commands.NewCacheCommand())
+	rootCmd.AddCommand(commands.NewAgentCommand())
+	rootCmd.AddCommand(commands.NewVersionCommand(version))
 
 	// Set version template
 	rootCmd.SetVersionTemplate(`{{printf "%s\n" .Version}}`)
 
 	// Execute
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		errorFormat, _ := rootCmd.PersistentFlags().GetString("error-format")
+		commands.WriteError(os.Stderr, err, errorFormat)
+		os.Exit(commands.ExitCode(err))
 	}
 }
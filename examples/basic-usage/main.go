@@ -71,8 +71,9 @@ func listAccessExample(ctx context.Context, startURL, ssoRegion string) {
 
 	// List first 5 roles
 	roles, err := awsssolib.ListAvailableRoles(ctx, awsssolib.ListRolesInput{
-		StartURL:  startURL,
-		SSORegion: ssoRegion,
+		StartURL:   startURL,
+		SSORegion:  ssoRegion,
+		MaxResults: 5,
 	})
 	if err != nil {
 		log.Printf("Failed to list roles: %v", err)
@@ -80,14 +81,8 @@ func listAccessExample(ctx context.Context, startURL, ssoRegion string) {
 	}
 
 	fmt.Printf("\nAvailable Roles (first 5):\n")
-	count := 0
 	for _, role := range roles {
 		fmt.Printf("  - %s in %s (%s)\n", role.RoleName, role.AccountName, role.AccountID)
-		count++
-		if count >= 5 {
-			fmt.Printf("  ... and more\n")
-			break
-		}
 	}
 }
 
@@ -98,7 +93,7 @@ func awsSDKExample(ctx context.Context, startURL, ssoRegion, accountID, roleName
 		SSORegion: ssoRegion,
 		AccountID: accountID,
 		RoleName:  roleName,
-		Region:    "us-east-1",
+		Region:    awsssolib.ResolveRegion(awsssolib.RegionSources{SSORegion: ssoRegion}),
 		Login:     true,
 	})
 	if err != nil {